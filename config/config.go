@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -20,13 +22,32 @@ const (
 	ProviderPostmark  Provider = "postmark"
 	ProviderSparkPost Provider = "sparkpost"
 	ProviderPostal    Provider = "postal"
+	ProviderWebhook   Provider = "webhook"
+	ProviderSES       Provider = "ses"
+	ProviderSendmail  Provider = "sendmail"
 )
 
 // Config represents the application configuration
 type Config struct {
 	Providers       map[string]*ProviderConfig `yaml:"providers"`
 	DefaultProvider string                     `yaml:"default_provider,omitempty"`
-	Limits          *Limits                    `yaml:"limits,omitempty"`
+	// FailoverChains are ordered provider lists selectable like a provider.
+	// Sending tries each provider in turn until one succeeds.
+	FailoverChains map[string]*FailoverChain `yaml:"failover_chains,omitempty"`
+	// Signatures are named signatures referenced by providers and identities
+	Signatures map[string]*Signature `yaml:"signatures,omitempty"`
+	Limits     *Limits               `yaml:"limits,omitempty"`
+	// SendChecks configures the checks run on an email before it is sent
+	SendChecks *SendChecks `yaml:"send_checks,omitempty"`
+	// UndoSendSeconds delays sending so a message can still be cancelled.
+	// Default: 0 (send right away)
+	UndoSendSeconds int `yaml:"undo_send_seconds,omitempty"`
+	// DeliveryEvents enables the local server that receives delivery
+	// events from provider webhooks
+	DeliveryEvents *DeliveryEvents `yaml:"delivery_events,omitempty"`
+	// PGPKeyring is an armored or binary file of OpenPGP public keys to
+	// encrypt messages to, in addition to the keys stored with contacts
+	PGPKeyring string `yaml:"pgp_keyring,omitempty"`
 	// DateFormat is the layout used for the {{date}} system variable.
 	// Uses Go time layout syntax. Default: "02.01.2006" (DD.MM.YYYY).
 	DateFormat string `yaml:"date_format,omitempty"`
@@ -38,6 +59,81 @@ type Limits struct {
 	MaxHistoryEntries   int `yaml:"max_history_entries,omitempty"`    // Default: 100
 	MaxBodyLength       int `yaml:"max_body_length,omitempty"`        // Default: 10000
 	MaxEmailsPerField   int `yaml:"max_emails_per_field,omitempty"`   // Default: 500
+	// Copies of sent attachments kept for history and resends
+	MaxSnapshotStorageMB  int `yaml:"max_snapshot_storage_mb,omitempty"` // Default: 500
+	SnapshotRetentionDays int `yaml:"snapshot_retention_days,omitempty"` // Default: 90
+}
+
+// CheckLevel is how a failed send check is handled
+type CheckLevel string
+
+const (
+	CheckWarn  CheckLevel = "warn"  // Ask for confirmation before sending
+	CheckBlock CheckLevel = "block" // Refuse to send
+	CheckOff   CheckLevel = "off"   // Don't run the check
+)
+
+// SendChecks configures the checks run on an email before it is sent
+type SendChecks struct {
+	// Levels overrides the level of checks by name, e.g. empty_subject: warn
+	Levels map[string]CheckLevel `yaml:"levels,omitempty"`
+	// MaxVisibleRecipients is the number of To and Cc recipients above
+	// which Bcc is suggested. Default: 10
+	MaxVisibleRecipients int `yaml:"max_visible_recipients,omitempty"`
+	// AttachmentKeywords are words that suggest an attachment, matched at
+	// the start of a word. Replaces the defaults when set.
+	AttachmentKeywords []string `yaml:"attachment_keywords,omitempty"`
+}
+
+// DefaultAttachmentKeywords are the words that suggest an attachment
+var DefaultAttachmentKeywords = []string{"attach", "enclosed", "anbei", "anhang", "angehängt"}
+
+// Level returns the configured level of a check, or def if not configured
+func (s *SendChecks) Level(name string, def CheckLevel) CheckLevel {
+	if level, ok := s.Levels[name]; ok {
+		return level
+	}
+	return def
+}
+
+// DefaultEventsListen is the address the delivery event receiver listens
+// on when none is configured
+const DefaultEventsListen = "127.0.0.1:8725"
+
+// DeliveryEvents configures the local server that receives delivery events
+// from provider webhooks. Only providers with credentials set are accepted.
+type DeliveryEvents struct {
+	Listen string `yaml:"listen,omitempty"` // Default: 127.0.0.1:8725
+	// MailgunSigningKey is the HTTP webhook signing key from the Mailgun
+	// dashboard, used to verify each event's signature
+	MailgunSigningKey string `yaml:"mailgun_signing_key,omitempty"`
+	// SendGridVerificationKey is the public key of SendGrid's signed event
+	// webhook, base64 encoded as shown in its settings
+	SendGridVerificationKey string `yaml:"sendgrid_verification_key,omitempty"`
+	// Postmark and SparkPost authenticate with basic auth credentials
+	// included in the webhook URL
+	Postmark  *BasicAuth `yaml:"postmark,omitempty"`
+	SparkPost *BasicAuth `yaml:"sparkpost,omitempty"`
+}
+
+// BasicAuth holds HTTP basic auth credentials
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// GetListen returns the configured listen address, or the default
+func (d *DeliveryEvents) GetListen() string {
+	if d.Listen == "" {
+		return DefaultEventsListen
+	}
+	return d.Listen
+}
+
+// FailoverChain represents an ordered list of providers to try in turn.
+// Chains are named by their key in FailoverChains.
+type FailoverChain struct {
+	Providers []string `yaml:"providers"`
 }
 
 // ProviderConfig represents a single named provider configuration
@@ -47,6 +143,29 @@ type ProviderConfig struct {
 	FromAddress string   `yaml:"from_address"`
 	FromName    string   `yaml:"from_name"`
 
+	// Identities are additional named senders selectable in Compose
+	Identities []Identity `yaml:"identities,omitempty"`
+	// AllowedDomains restricts sender addresses and is used to complete "user@"
+	AllowedDomains []string `yaml:"allowed_domains,omitempty"`
+	// Signature is the default signature name or inline text for all identities
+	Signature string `yaml:"signature,omitempty"`
+	// MaxMessageSizeMB caps the encoded message size, see GetMaxMessageSizeMB
+	MaxMessageSizeMB int `yaml:"max_message_size_mb,omitempty"`
+	// MaxRecipients caps To, Cc and Bcc recipients per message, see
+	// GetMaxRecipients
+	MaxRecipients int `yaml:"max_recipients,omitempty"`
+	// RateLimit spaces out sends through this provider
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty"`
+	// DKIM signs messages from the default identity, and from identities
+	// without a DKIM key of their own, see DKIMFor
+	DKIM *DKIMConfig `yaml:"dkim,omitempty"`
+	// PGP signs messages from the default identity, and from identities
+	// without a PGP key of their own, see PGPFor
+	PGP *PGPConfig `yaml:"pgp,omitempty"`
+	// SMIME signs messages from the default identity, and from identities
+	// without a certificate of their own, see SMIMEFor
+	SMIME *SMIMEConfig `yaml:"smime,omitempty"`
+
 	// Provider-specific configs (only one should be populated based on Type)
 	SMTP      *SMTPConfig      `yaml:"smtp,omitempty"`
 	Mailgun   *MailgunConfig   `yaml:"mailgun,omitempty"`
@@ -54,6 +173,295 @@ type ProviderConfig struct {
 	Postmark  *PostmarkConfig  `yaml:"postmark,omitempty"`
 	SparkPost *SparkPostConfig `yaml:"sparkpost,omitempty"`
 	Postal    *PostalConfig    `yaml:"postal,omitempty"`
+	Webhook   *WebhookConfig   `yaml:"webhook,omitempty"`
+	SES       *SESConfig       `yaml:"ses,omitempty"`
+	Sendmail  *SendmailConfig  `yaml:"sendmail,omitempty"`
+}
+
+// Identity represents a named sender identity of a provider
+type Identity struct {
+	Name        string `yaml:"name"`
+	Address     string `yaml:"address"`
+	DisplayName string `yaml:"display_name,omitempty"`
+	ReplyTo     string `yaml:"reply_to,omitempty"`
+	Signature   string `yaml:"signature,omitempty"`
+	// DKIM signs messages sent as this identity, overriding the provider's
+	DKIM *DKIMConfig `yaml:"dkim,omitempty"`
+	// PGP signs messages sent as this identity, overriding the provider's
+	PGP *PGPConfig `yaml:"pgp,omitempty"`
+	// SMIME signs messages sent as this identity, overriding the provider's
+	SMIME *SMIMEConfig `yaml:"smime,omitempty"`
+}
+
+// PGPConfig holds the OpenPGP secret key messages are signed with
+type PGPConfig struct {
+	SecretKey  string `yaml:"secret_key"` // Path to an exported secret key, armored or binary
+	Passphrase string `yaml:"passphrase,omitempty"`
+}
+
+// PGPFor returns the PGP configuration for a sender address: that of the
+// identity with the address if it has one, otherwise the provider's. nil
+// means messages can't be signed.
+func (pc *ProviderConfig) PGPFor(address string) *PGPConfig {
+	for _, identity := range pc.Identities {
+		if identity.PGP != nil && strings.EqualFold(identity.Address, address) {
+			return identity.PGP
+		}
+	}
+	return pc.PGP
+}
+
+// SMIMEConfig holds the certificate and private key S/MIME messages are
+// signed with
+type SMIMEConfig struct {
+	Certificate string `yaml:"certificate"` // Path to a PKCS#12 file (.p12 or .pfx)
+	Password    string `yaml:"password,omitempty"`
+}
+
+// SMIMEFor returns the S/MIME configuration for a sender address: that of
+// the identity with the address if it has one, otherwise the provider's.
+// nil means messages can't be signed.
+func (pc *ProviderConfig) SMIMEFor(address string) *SMIMEConfig {
+	for _, identity := range pc.Identities {
+		if identity.SMIME != nil && strings.EqualFold(identity.Address, address) {
+			return identity.SMIME
+		}
+	}
+	return pc.SMIME
+}
+
+// DKIM canonicalization algorithms
+const (
+	DKIMSimple  = "simple"
+	DKIMRelaxed = "relaxed"
+)
+
+// DefaultDKIMHeaders are the header fields signed unless configured
+// otherwise. Fields a message doesn't have are signed as empty, so they
+// can't be added after signing.
+var DefaultDKIMHeaders = []string{
+	"From", "Reply-To", "To", "Cc", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type", "List-Unsubscribe",
+}
+
+// DKIMConfig holds the key messages are DKIM signed with. Only transports
+// that write the message themselves sign it, SMTP and sendmail.
+type DKIMConfig struct {
+	Domain     string `yaml:"domain,omitempty"` // Defaults to the domain of the sender address
+	Selector   string `yaml:"selector"`
+	PrivateKey string `yaml:"private_key"` // Path to a PEM encoded RSA or Ed25519 key
+	// Headers are the header fields to sign, see DefaultDKIMHeaders
+	Headers []string `yaml:"headers,omitempty"`
+	// Canonicalization is "header/body" with simple or relaxed each, or
+	// one of them for both. Defaults to relaxed/relaxed.
+	Canonicalization string `yaml:"canonicalization,omitempty"`
+}
+
+// GetDomain returns the signing domain for a sender address
+func (d *DKIMConfig) GetDomain(address string) string {
+	if d.Domain != "" {
+		return d.Domain
+	}
+	return AddressDomain(address)
+}
+
+// AddressDomain returns the domain part of an email address, or "" if it
+// has none
+func AddressDomain(address string) string {
+	if at := strings.LastIndex(address, "@"); at != -1 {
+		return address[at+1:]
+	}
+	return ""
+}
+
+// GetHeaders returns the header fields to sign
+func (d *DKIMConfig) GetHeaders() []string {
+	if len(d.Headers) == 0 {
+		return DefaultDKIMHeaders
+	}
+	return d.Headers
+}
+
+// GetCanonicalization returns the header and body canonicalization
+func (d *DKIMConfig) GetCanonicalization() (header, body string) {
+	if d.Canonicalization == "" {
+		return DKIMRelaxed, DKIMRelaxed
+	}
+	header, body, found := strings.Cut(d.Canonicalization, "/")
+	if !found {
+		body = DKIMSimple // As specified by RFC 6376 for a missing body algorithm
+	}
+	return header, body
+}
+
+// Validate checks a DKIM configuration
+func (d *DKIMConfig) Validate() error {
+	if d.Selector == "" {
+		return fmt.Errorf("selector is required")
+	}
+	if d.PrivateKey == "" {
+		return fmt.Errorf("private_key is required")
+	}
+	header, body := d.GetCanonicalization()
+	for _, c := range []string{header, body} {
+		if c != DKIMSimple && c != DKIMRelaxed {
+			return fmt.Errorf("canonicalization must be simple or relaxed, got '%s'", d.Canonicalization)
+		}
+	}
+	if len(d.Headers) > 0 {
+		signsFrom := false
+		for _, h := range d.Headers {
+			if strings.EqualFold(h, "From") {
+				signsFrom = true
+			}
+			if strings.ContainsAny(h, ": \t") {
+				return fmt.Errorf("invalid header name '%s'", h)
+			}
+		}
+		if !signsFrom {
+			return fmt.Errorf("headers must include From")
+		}
+	}
+	return nil
+}
+
+// DKIMFor returns the DKIM configuration for a sender address: that of the
+// identity with the address if it has one, otherwise the provider's. nil
+// means messages aren't signed.
+func (pc *ProviderConfig) DKIMFor(address string) *DKIMConfig {
+	for _, identity := range pc.Identities {
+		if identity.DKIM != nil && strings.EqualFold(identity.Address, address) {
+			return identity.DKIM
+		}
+	}
+	return pc.DKIM
+}
+
+// Signature is a plain-text signature with an optional HTML version.
+// Both may contain template variables such as {{date}} or {{from_name}}.
+type Signature struct {
+	Text string `yaml:"text,omitempty"`
+	HTML string `yaml:"html,omitempty"`
+}
+
+// DefaultIdentityName is the name of the identity built from from_address/from_name
+const DefaultIdentityName = "default"
+
+// GetIdentities returns the provider's default identity followed by any
+// configured identities
+func (pc *ProviderConfig) GetIdentities() []Identity {
+	identities := make([]Identity, 0, len(pc.Identities)+1)
+	identities = append(identities, Identity{
+		Name:        DefaultIdentityName,
+		Address:     pc.FromAddress,
+		DisplayName: pc.FromName,
+		DKIM:        pc.DKIM,
+		PGP:         pc.PGP,
+		SMIME:       pc.SMIME,
+	})
+	return append(identities, pc.Identities...)
+}
+
+// GetIdentity retrieves an identity by name
+func (pc *ProviderConfig) GetIdentity(name string) (*Identity, error) {
+	for _, identity := range pc.GetIdentities() {
+		if identity.Name == name {
+			return &identity, nil
+		}
+	}
+	return nil, fmt.Errorf("identity '%s' not found", name)
+}
+
+// Domains returns the sender domains of the provider. These are the allowed
+// domains if configured, otherwise the Mailgun domain or the domain of the
+// From address.
+func (pc *ProviderConfig) Domains() []string {
+	if len(pc.AllowedDomains) > 0 {
+		return pc.AllowedDomains
+	}
+	if pc.Mailgun != nil && pc.Mailgun.Domain != "" {
+		return []string{pc.Mailgun.Domain}
+	}
+	if domain := AddressDomain(pc.FromAddress); domain != "" {
+		return []string{domain}
+	}
+	return nil
+}
+
+// IsAllowedAddress reports whether an address may be used as sender
+func (pc *ProviderConfig) IsAllowedAddress(address string) bool {
+	if len(pc.AllowedDomains) == 0 {
+		return true
+	}
+	domain := AddressDomain(address)
+	for _, allowed := range pc.AllowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit caps how fast messages are sent through a provider. Zero
+// values are unlimited.
+type RateLimit struct {
+	PerSecond     int `yaml:"per_second,omitempty"`
+	PerMinute     int `yaml:"per_minute,omitempty"`
+	PerHour       int `yaml:"per_hour,omitempty"`
+	MaxConcurrent int `yaml:"max_concurrent,omitempty"` // Connections or requests at once
+}
+
+// IsZero reports whether no limit is set
+func (r *RateLimit) IsZero() bool {
+	return r == nil || *r == RateLimit{}
+}
+
+// defaultMaxMessageSizeMB is the total message size each provider accepts,
+// including base64-encoded attachments
+var defaultMaxMessageSizeMB = map[Provider]int{
+	ProviderMailgun:   25,
+	ProviderSendGrid:  30,
+	ProviderPostmark:  10,
+	ProviderSparkPost: 20,
+	ProviderPostal:    14,
+	ProviderSES:       40,
+	ProviderSMTP:      25,
+	ProviderSendmail:  25,
+	ProviderWebhook:   25,
+}
+
+// defaultMaxRecipients is the number of recipients each provider accepts
+// in one message. Webhooks have no limit of their own.
+var defaultMaxRecipients = map[Provider]int{
+	ProviderMailgun:   1000,
+	ProviderSendGrid:  1000,
+	ProviderPostmark:  50,
+	ProviderSparkPost: 10000,
+	ProviderPostal:    50,
+	ProviderSES:       50,
+	ProviderSMTP:      100,
+	ProviderSendmail:  100,
+}
+
+// GetMaxRecipients returns the configured recipient limit per message, or
+// the provider's documented limit. 0 means unlimited.
+func (pc *ProviderConfig) GetMaxRecipients() int {
+	if pc.MaxRecipients > 0 {
+		return pc.MaxRecipients
+	}
+	return defaultMaxRecipients[pc.Type]
+}
+
+// GetMaxMessageSizeMB returns the configured message size limit, or the
+// provider's documented limit
+func (pc *ProviderConfig) GetMaxMessageSizeMB() int {
+	if pc.MaxMessageSizeMB > 0 {
+		return pc.MaxMessageSizeMB
+	}
+	if size, ok := defaultMaxMessageSizeMB[pc.Type]; ok {
+		return size
+	}
+	return 25
 }
 
 // SMTPConfig contains SMTP-specific settings
@@ -62,6 +470,9 @@ type SMTPConfig struct {
 	Port     int    `yaml:"port"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// RequireTLS refuses servers that don't offer STARTTLS instead of
+	// sending unencrypted. Port 465 always uses implicit TLS.
+	RequireTLS bool `yaml:"require_tls,omitempty"`
 }
 
 // MailgunConfig contains Mailgun-specific settings
@@ -93,6 +504,80 @@ type PostalConfig struct {
 	APIKey string `yaml:"api_key"`
 }
 
+// WebhookConfig contains settings for a generic HTTP mail gateway
+type WebhookConfig struct {
+	URL      string            `yaml:"url"`
+	Method   string            `yaml:"method,omitempty"`   // Default: POST
+	Username string            `yaml:"username,omitempty"` // Basic auth
+	Password string            `yaml:"password,omitempty"` // Basic auth
+	Token    string            `yaml:"token,omitempty"`    // Bearer auth
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	// BodyTemplate is a Go text/template rendered into the request body.
+	// Available fields: .From, .FromName, .To, .CC, .BCC, .Subject, .HTML,
+	// .Text and .Attachments (each with .Filename, .ContentType, .Content
+	// as base64). Use the json function to encode values, e.g. {{json .To}}.
+	// A JSON document containing all fields is sent when empty.
+	BodyTemplate string `yaml:"body_template,omitempty"`
+}
+
+// GetMethod returns the HTTP method, using POST if not configured
+func (wc *WebhookConfig) GetMethod() string {
+	if wc.Method == "" {
+		return "POST"
+	}
+	return strings.ToUpper(wc.Method)
+}
+
+// SESConfig contains Amazon SES-specific settings.
+// Credentials are taken from the access key fields, then the named profile
+// in ~/.aws/credentials, then the AWS_* environment variables.
+type SESConfig struct {
+	Region           string `yaml:"region"`
+	AccessKeyID      string `yaml:"access_key_id,omitempty"`
+	SecretAccessKey  string `yaml:"secret_access_key,omitempty"`
+	Profile          string `yaml:"profile,omitempty"`
+	ConfigurationSet string `yaml:"configuration_set,omitempty"`
+	Endpoint         string `yaml:"endpoint,omitempty"` // e.g., http://localhost:4566 for a local stand-in
+}
+
+// GetEndpoint returns the SES API endpoint, using the regional default if not configured
+func (sc *SESConfig) GetEndpoint() string {
+	if sc.Endpoint != "" {
+		return strings.TrimRight(sc.Endpoint, "/")
+	}
+	return "https://email." + sc.Region + ".amazonaws.com"
+}
+
+// DefaultSendmailPath is where sendmail and its replacements are installed
+const DefaultSendmailPath = "/usr/sbin/sendmail"
+
+// SendmailConfig contains settings for handing messages to a local sendmail
+// compatible program, such as Postfix, Exim or msmtp
+type SendmailConfig struct {
+	Path string `yaml:"path,omitempty"` // Defaults to DefaultSendmailPath
+}
+
+// GetPath returns the sendmail program, using the default if not configured
+func (sc *SendmailConfig) GetPath() string {
+	if sc == nil || sc.Path == "" {
+		return DefaultSendmailPath
+	}
+	return ExpandHome(sc.Path)
+}
+
+// ExpandHome replaces a leading ~ in a configured or typed path with the
+// home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
 // GetConfigPath returns the path to the config file
 func GetConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -179,6 +664,9 @@ func DefaultLimits() *Limits {
 		MaxHistoryEntries:   100,
 		MaxBodyLength:       10000,
 		MaxEmailsPerField:   500,
+
+		MaxSnapshotStorageMB:  500,
+		SnapshotRetentionDays: 90,
 	}
 }
 
@@ -201,9 +689,29 @@ func (c *Config) GetLimits() *Limits {
 	if c.Limits.MaxEmailsPerField == 0 {
 		c.Limits.MaxEmailsPerField = defaults.MaxEmailsPerField
 	}
+	if c.Limits.MaxSnapshotStorageMB == 0 {
+		c.Limits.MaxSnapshotStorageMB = defaults.MaxSnapshotStorageMB
+	}
+	if c.Limits.SnapshotRetentionDays == 0 {
+		c.Limits.SnapshotRetentionDays = defaults.SnapshotRetentionDays
+	}
 	return c.Limits
 }
 
+// GetSendChecks returns the send check settings, using defaults if not configured
+func (c *Config) GetSendChecks() *SendChecks {
+	if c.SendChecks == nil {
+		c.SendChecks = &SendChecks{}
+	}
+	if c.SendChecks.MaxVisibleRecipients == 0 {
+		c.SendChecks.MaxVisibleRecipients = 10
+	}
+	if len(c.SendChecks.AttachmentKeywords) == 0 {
+		c.SendChecks.AttachmentKeywords = DefaultAttachmentKeywords
+	}
+	return c.SendChecks
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	if len(c.Providers) == 0 {
@@ -217,9 +725,54 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate each failover chain
+	for name, chain := range c.FailoverChains {
+		if err := c.validateChain(name, chain); err != nil {
+			return fmt.Errorf("failover chain '%s': %w", name, err)
+		}
+	}
+
+	// Validate signatures
+	for name, sig := range c.Signatures {
+		if sig == nil || (strings.TrimSpace(sig.Text) == "" && strings.TrimSpace(sig.HTML) == "") {
+			return fmt.Errorf("signature '%s': text or html is required", name)
+		}
+	}
+
+	if c.UndoSendSeconds < 0 {
+		return fmt.Errorf("undo_send_seconds must not be negative")
+	}
+
+	// Validate send check levels
+	if c.SendChecks != nil {
+		for name, level := range c.SendChecks.Levels {
+			switch level {
+			case CheckWarn, CheckBlock, CheckOff:
+			default:
+				return fmt.Errorf("send check '%s': level must be warn, block or off", name)
+			}
+		}
+		if c.SendChecks.MaxVisibleRecipients < 0 {
+			return fmt.Errorf("send_checks: max_visible_recipients must not be negative")
+		}
+	}
+
+	// Validate the delivery event receiver
+	if d := c.DeliveryEvents; d != nil {
+		if d.MailgunSigningKey == "" && d.SendGridVerificationKey == "" && d.Postmark == nil && d.SparkPost == nil {
+			return fmt.Errorf("delivery_events: credentials for at least one provider are required")
+		}
+		for name, auth := range map[string]*BasicAuth{"postmark": d.Postmark, "sparkpost": d.SparkPost} {
+			if auth != nil && (auth.Username == "" || auth.Password == "") {
+				return fmt.Errorf("delivery_events: %s username and password are required", name)
+			}
+		}
+	}
+
 	// Validate default provider exists if set
 	if c.DefaultProvider != "" {
-		if _, ok := c.Providers[c.DefaultProvider]; !ok {
+		_, isProvider := c.Providers[c.DefaultProvider]
+		if !isProvider && !c.IsChain(c.DefaultProvider) {
 			return fmt.Errorf("default provider '%s' does not exist", c.DefaultProvider)
 		}
 	}
@@ -227,6 +780,53 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// ResolveSignature returns the signature for an identity of a provider.
+// The identity's signature takes precedence over the provider default; a
+// value naming an entry in Signatures refers to it, any other value is used
+// as inline plain text. Returns nil if no signature applies.
+func (c *Config) ResolveSignature(pc *ProviderConfig, identity *Identity) *Signature {
+	value := ""
+	if pc != nil {
+		value = pc.Signature
+	}
+	if identity != nil && identity.Signature != "" {
+		value = identity.Signature
+	}
+	if value == "" {
+		return nil
+	}
+	if sig, ok := c.Signatures[value]; ok && sig != nil {
+		return sig
+	}
+	return &Signature{Text: value}
+}
+
+// validateChain checks if a failover chain only references existing providers
+func (c *Config) validateChain(name string, chain *FailoverChain) error {
+	if chain == nil {
+		return fmt.Errorf("providers are required")
+	}
+	if _, ok := c.Providers[name]; ok {
+		return fmt.Errorf("name clashes with a provider of the same name")
+	}
+	if len(chain.Providers) < 2 {
+		return fmt.Errorf("at least two providers are required")
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range chain.Providers {
+		if _, ok := c.Providers[name]; !ok {
+			return fmt.Errorf("provider '%s' does not exist", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("provider '%s' is listed more than once", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
 // Validate checks if a provider configuration is valid
 func (pc *ProviderConfig) Validate() error {
 	if pc.Name == "" {
@@ -237,6 +837,64 @@ func (pc *ProviderConfig) Validate() error {
 		return fmt.Errorf("from_address is required")
 	}
 
+	if !pc.IsAllowedAddress(pc.FromAddress) {
+		return fmt.Errorf("from_address %s is not in allowed_domains", pc.FromAddress)
+	}
+
+	if pc.MaxMessageSizeMB < 0 {
+		return fmt.Errorf("max_message_size_mb must not be negative")
+	}
+
+	if pc.MaxRecipients < 0 {
+		return fmt.Errorf("max_recipients must not be negative")
+	}
+
+	if r := pc.RateLimit; r != nil && (r.PerSecond < 0 || r.PerMinute < 0 || r.PerHour < 0 || r.MaxConcurrent < 0) {
+		return fmt.Errorf("rate_limit values must not be negative")
+	}
+
+	seen := map[string]bool{DefaultIdentityName: true}
+	for i, identity := range pc.Identities {
+		if identity.Name == "" {
+			return fmt.Errorf("identities[%d].name is required", i)
+		}
+		if seen[identity.Name] {
+			return fmt.Errorf("identity '%s' is defined more than once", identity.Name)
+		}
+		seen[identity.Name] = true
+		if identity.Address == "" {
+			return fmt.Errorf("identity '%s': address is required", identity.Name)
+		}
+		if !pc.IsAllowedAddress(identity.Address) {
+			return fmt.Errorf("identity '%s': address %s is not in allowed_domains", identity.Name, identity.Address)
+		}
+		if identity.DKIM != nil {
+			if err := identity.DKIM.Validate(); err != nil {
+				return fmt.Errorf("identity '%s': dkim: %w", identity.Name, err)
+			}
+		}
+		if identity.PGP != nil && identity.PGP.SecretKey == "" {
+			return fmt.Errorf("identity '%s': pgp.secret_key is required", identity.Name)
+		}
+		if identity.SMIME != nil && identity.SMIME.Certificate == "" {
+			return fmt.Errorf("identity '%s': smime.certificate is required", identity.Name)
+		}
+	}
+
+	if pc.DKIM != nil {
+		if err := pc.DKIM.Validate(); err != nil {
+			return fmt.Errorf("dkim: %w", err)
+		}
+	}
+
+	if pc.PGP != nil && pc.PGP.SecretKey == "" {
+		return fmt.Errorf("pgp.secret_key is required")
+	}
+
+	if pc.SMIME != nil && pc.SMIME.Certificate == "" {
+		return fmt.Errorf("smime.certificate is required")
+	}
+
 	switch pc.Type {
 	case ProviderSMTP:
 		if pc.SMTP == nil {
@@ -289,6 +947,30 @@ func (pc *ProviderConfig) Validate() error {
 		if pc.Postal.APIKey == "" {
 			return fmt.Errorf("postal.api_key is required")
 		}
+	case ProviderWebhook:
+		if pc.Webhook == nil {
+			return fmt.Errorf("webhook configuration is required")
+		}
+		if pc.Webhook.URL == "" {
+			return fmt.Errorf("webhook.url is required")
+		}
+		switch pc.Webhook.GetMethod() {
+		case "POST", "PUT", "PATCH":
+		default:
+			return fmt.Errorf("webhook.method must be POST, PUT or PATCH, got %s", pc.Webhook.Method)
+		}
+	case ProviderSES:
+		if pc.SES == nil {
+			return fmt.Errorf("ses configuration is required")
+		}
+		if pc.SES.Region == "" {
+			return fmt.Errorf("ses.region is required")
+		}
+		if pc.SES.AccessKeyID != "" && pc.SES.SecretAccessKey == "" {
+			return fmt.Errorf("ses.secret_access_key is required when ses.access_key_id is set")
+		}
+	case ProviderSendmail:
+		// Every field has a default, the section may be left out
 	default:
 		return fmt.Errorf("unknown provider type: %s", pc.Type)
 	}
@@ -332,14 +1014,32 @@ func (c *Config) DeleteProvider(name string) error {
 	}
 
 	delete(c.Providers, name)
+	defaultRemoved := c.DefaultProvider == name
 
-	// Update default if we deleted it
-	if c.DefaultProvider == name {
+	// Drop the provider from failover chains, removing chains that become too short
+	for chainName, chain := range c.FailoverChains {
+		remaining := make([]string, 0, len(chain.Providers))
+		for _, p := range chain.Providers {
+			if p != name {
+				remaining = append(remaining, p)
+			}
+		}
+		chain.Providers = remaining
+		if len(remaining) < 2 {
+			delete(c.FailoverChains, chainName)
+			if c.DefaultProvider == chainName {
+				defaultRemoved = true
+			}
+		}
+	}
+
+	// Fall back to the first remaining provider if the default went away,
+	// either directly or with a chain it belonged to
+	if defaultRemoved {
 		c.DefaultProvider = ""
-		// Set to first available provider
-		for n := range c.Providers {
-			c.DefaultProvider = n
-			break
+		if names := c.ListProviders(); len(names) > 0 {
+			sort.Strings(names)
+			c.DefaultProvider = names[0]
 		}
 	}
 
@@ -355,6 +1055,45 @@ func (c *Config) ListProviders() []string {
 	return names
 }
 
+// ListChains returns a sorted slice of all failover chain names
+func (c *Config) ListChains() []string {
+	names := make([]string, 0, len(c.FailoverChains))
+	for name := range c.FailoverChains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListSendTargets returns all providers followed by all failover chains
+func (c *Config) ListSendTargets() []string {
+	return append(c.ListProviders(), c.ListChains()...)
+}
+
+// IsChain reports whether name refers to a failover chain
+func (c *Config) IsChain(name string) bool {
+	_, ok := c.FailoverChains[name]
+	return ok
+}
+
+// GetChain retrieves a failover chain by name
+func (c *Config) GetChain(name string) (*FailoverChain, error) {
+	chain, ok := c.FailoverChains[name]
+	if !ok {
+		return nil, fmt.Errorf("failover chain '%s' not found", name)
+	}
+	return chain, nil
+}
+
+// PrimaryProvider returns the provider config for a provider name, or the
+// first provider of a failover chain
+func (c *Config) PrimaryProvider(name string) (*ProviderConfig, error) {
+	if chain, ok := c.FailoverChains[name]; ok && len(chain.Providers) > 0 {
+		return c.GetProvider(chain.Providers[0])
+	}
+	return c.GetProvider(name)
+}
+
 // ConfigExists checks if a config file exists
 func ConfigExists() bool {
 	configPath, err := GetConfigPath()
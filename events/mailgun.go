@@ -0,0 +1,116 @@
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// mailgunPayload is the body of a Mailgun webhook request
+type mailgunPayload struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData struct {
+		Event          string  `json:"event"`
+		Timestamp      float64 `json:"timestamp"`
+		Recipient      string  `json:"recipient"`
+		Severity       string  `json:"severity"`
+		Reason         string  `json:"reason"`
+		URL            string  `json:"url"`
+		DeliveryStatus struct {
+			Code        int    `json:"code"`
+			Message     string `json:"message"`
+			Description string `json:"description"`
+		} `json:"delivery-status"`
+		Message struct {
+			Headers struct {
+				MessageID string `json:"message-id"`
+			} `json:"headers"`
+		} `json:"message"`
+	} `json:"event-data"`
+}
+
+// parseMailgun verifies the signature of a Mailgun webhook request and
+// returns its event. The signature is an HMAC of the timestamp and token
+// with the webhook signing key; the timestamp must be recent and the token
+// not used before.
+func parseMailgun(body []byte, signingKey string, tokens *tokenCache, now time.Time) ([]Event, error) {
+	var p mailgunPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("invalid Mailgun payload: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(p.Signature.Timestamp + p.Signature.Token))
+	signature, err := hex.DecodeString(p.Signature.Signature)
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errUnauthorized
+	}
+	if err := checkTimestamp(p.Signature.Timestamp, now); err != nil {
+		return nil, err
+	}
+	if !tokens.add(p.Signature.Token, now) {
+		return nil, fmt.Errorf("%w: token was already used", errUnauthorized)
+	}
+
+	data := p.EventData
+	event := Event{
+		MessageID: data.Message.Headers.MessageID,
+		Recipient: data.Recipient,
+		Time:      unixTime(data.Timestamp),
+	}
+	switch data.Event {
+	case "accepted":
+		event.Type = TypeAccepted
+	case "delivered":
+		event.Type = TypeDelivered
+	case "failed":
+		event.Type = TypeBounced
+		if data.Severity == "temporary" {
+			event.Type = TypeDeferred
+		}
+	case "rejected":
+		event.Type = TypeDropped
+	case "opened":
+		event.Type = TypeOpened
+	case "clicked":
+		event.Type = TypeClicked
+	case "complained":
+		event.Type = TypeComplained
+	case "unsubscribed":
+		event.Type = TypeUnsubscribed
+	default:
+		event.Type = data.Event
+	}
+
+	switch {
+	case data.URL != "":
+		event.Detail = data.URL
+	case data.DeliveryStatus.Description != "":
+		event.Detail = data.DeliveryStatus.Description
+	case data.DeliveryStatus.Message != "":
+		event.Detail = data.DeliveryStatus.Message
+	case data.Reason != "":
+		event.Detail = data.Reason
+	}
+	if data.DeliveryStatus.Code != 0 && event.Type != TypeDelivered {
+		event.Detail = fmt.Sprintf("%d %s", data.DeliveryStatus.Code, event.Detail)
+	}
+	return []Event{event}, nil
+}
+
+// unixTime converts fractional Unix seconds to a time
+func unixTime(seconds float64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// postmarkPayload is the body of a Postmark webhook request. Each record
+// type uses some of the fields.
+type postmarkPayload struct {
+	RecordType      string
+	MessageID       string
+	Recipient       string // Delivery, Open, Click and SubscriptionChange
+	Email           string // Bounce and SpamComplaint
+	Type            string // Bounce type, e.g. HardBounce
+	Description     string
+	Details         string
+	OriginalLink    string
+	SuppressSending bool
+	DeliveredAt     time.Time
+	BouncedAt       time.Time
+	ReceivedAt      time.Time
+	ChangedAt       time.Time
+}
+
+// parsePostmark returns the event of a Postmark webhook request
+func parsePostmark(body []byte) ([]Event, error) {
+	var p postmarkPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("invalid Postmark payload: %w", err)
+	}
+
+	event := Event{MessageID: p.MessageID, Recipient: p.Recipient}
+	switch p.RecordType {
+	case "Delivery":
+		event.Type = TypeDelivered
+		event.Time = p.DeliveredAt
+		event.Detail = p.Details
+	case "Bounce":
+		event.Type = TypeBounced
+		switch p.Type {
+		case "SoftBounce", "Transient", "DnsError", "AutoResponder":
+			event.Type = TypeDeferred
+		}
+		event.Recipient = p.Email
+		event.Time = p.BouncedAt
+		event.Detail = p.Description
+		if p.Details != "" {
+			event.Detail = p.Details
+		}
+	case "SpamComplaint":
+		event.Type = TypeComplained
+		event.Recipient = p.Email
+		event.Time = p.BouncedAt
+	case "Open":
+		event.Type = TypeOpened
+		event.Time = p.ReceivedAt
+	case "Click":
+		event.Type = TypeClicked
+		event.Time = p.ReceivedAt
+		event.Detail = p.OriginalLink
+	case "SubscriptionChange":
+		if !p.SuppressSending {
+			return nil, nil // Resubscribed
+		}
+		event.Type = TypeUnsubscribed
+		event.Time = p.ChangedAt
+	default:
+		event.Type = p.RecordType
+	}
+	return []Event{event}, nil
+}
@@ -0,0 +1,272 @@
+package events
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"mailgloss/config"
+	"mailgloss/logger"
+)
+
+// Normalized event types. Providers report more detail, which is kept in
+// Event.Detail.
+const (
+	TypeAccepted     = "accepted"     // Queued by the provider
+	TypeDelivered    = "delivered"    // Accepted by the recipient's server
+	TypeDeferred     = "deferred"     // Temporarily failed, will be retried
+	TypeBounced      = "bounced"      // Permanently failed
+	TypeDropped      = "dropped"      // Not sent, e.g. a suppressed address
+	TypeComplained   = "complained"   // Marked as spam
+	TypeOpened       = "opened"       // Opened, if open tracking is enabled
+	TypeClicked      = "clicked"      // Link clicked, if click tracking is enabled
+	TypeUnsubscribed = "unsubscribed" // Recipient unsubscribed
+)
+
+// maxPayloadSize is the largest webhook request accepted
+const maxPayloadSize = 5 << 20
+
+// maxSignatureAge is how far the signed timestamp of a webhook request may
+// be from the current time. Older requests are rejected as possible replays
+// of a captured request.
+const maxSignatureAge = 5 * time.Minute
+
+// Event is a delivery event reported by a provider webhook
+type Event struct {
+	Provider  string // mailgun, sendgrid, postmark or sparkpost
+	MessageID string // The provider's message ID
+	Type      string // One of the Type constants, or the provider's own
+	Recipient string
+	Time      time.Time
+	Detail    string // Bounce reason, clicked link and the like
+}
+
+// Receiver is a local HTTP server receiving provider event webhooks. Each
+// provider posts to its own path, e.g. /mailgun.
+type Receiver struct {
+	cfg         *config.DeliveryEvents
+	sendGridKey *ecdsa.PublicKey // nil if SendGrid isn't configured
+	tokens      *tokenCache      // Tokens of recent Mailgun requests
+	server      *http.Server
+	listener    net.Listener
+	events      chan []Event
+	done        chan struct{} // Closed by Close
+	closeOnce   sync.Once
+}
+
+// NewReceiver creates a receiver for the configured providers
+func NewReceiver(cfg *config.DeliveryEvents) (*Receiver, error) {
+	r := &Receiver{cfg: cfg, events: make(chan []Event, 64), done: make(chan struct{}), tokens: &tokenCache{tokens: map[string]time.Time{}}}
+	if cfg.SendGridVerificationKey != "" {
+		key, err := parseSendGridKey(cfg.SendGridVerificationKey)
+		if err != nil {
+			return nil, fmt.Errorf("sendgrid verification key: %w", err)
+		}
+		r.sendGridKey = key
+	}
+	r.server = &http.Server{Handler: r.handler(), ReadHeaderTimeout: 10 * time.Second}
+	return r, nil
+}
+
+// Start listens on the configured address and serves in the background
+func (r *Receiver) Start() error {
+	listener, err := net.Listen("tcp", r.cfg.GetListen())
+	if err != nil {
+		return fmt.Errorf("failed to listen for delivery events: %w", err)
+	}
+	r.listener = listener
+	logger.Info("Delivery event receiver started", "address", listener.Addr().String())
+
+	go func() {
+		if err := r.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Delivery event receiver stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the receiver listens on
+func (r *Receiver) Addr() string {
+	if r.listener == nil {
+		return r.cfg.GetListen()
+	}
+	return r.listener.Addr().String()
+}
+
+// Events returns the channel the events of each webhook request are
+// delivered on
+func (r *Receiver) Events() <-chan []Event {
+	return r.events
+}
+
+// Done returns a channel that is closed when the receiver is closed, so
+// readers of Events can stop waiting
+func (r *Receiver) Done() <-chan struct{} {
+	return r.done
+}
+
+// Close stops the receiver and waits for running requests to finish
+func (r *Receiver) Close() error {
+	r.closeOnce.Do(func() { close(r.done) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return r.server.Shutdown(ctx)
+}
+
+// handler routes the providers with credentials configured
+func (r *Receiver) handler() http.Handler {
+	mux := http.NewServeMux()
+	if r.cfg.MailgunSigningKey != "" {
+		mux.HandleFunc("POST /mailgun", r.handle("mailgun", func(req *http.Request, body []byte) ([]Event, error) {
+			return parseMailgun(body, r.cfg.MailgunSigningKey, r.tokens, time.Now())
+		}))
+	}
+	if r.sendGridKey != nil {
+		mux.HandleFunc("POST /sendgrid", r.handle("sendgrid", func(req *http.Request, body []byte) ([]Event, error) {
+			if err := verifySendGrid(r.sendGridKey, req.Header, body, time.Now()); err != nil {
+				return nil, err
+			}
+			return parseSendGrid(body)
+		}))
+	}
+	if r.cfg.Postmark != nil {
+		mux.HandleFunc("POST /postmark", r.handle("postmark", func(req *http.Request, body []byte) ([]Event, error) {
+			if err := checkBasicAuth(req, r.cfg.Postmark); err != nil {
+				return nil, err
+			}
+			return parsePostmark(body)
+		}))
+	}
+	if r.cfg.SparkPost != nil {
+		mux.HandleFunc("POST /sparkpost", r.handle("sparkpost", func(req *http.Request, body []byte) ([]Event, error) {
+			if err := checkBasicAuth(req, r.cfg.SparkPost); err != nil {
+				return nil, err
+			}
+			return parseSparkPost(body)
+		}))
+	}
+	return mux
+}
+
+// errUnauthorized is returned when a request fails verification
+var errUnauthorized = errors.New("signature or credentials do not match")
+
+// handle reads a webhook request, parses it and passes the events on. The
+// request is held until the events are taken so the provider retries if
+// the app can't keep up.
+func (r *Receiver) handle(provider string, parse func(*http.Request, []byte) ([]Event, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+
+		events, err := parse(req, body)
+		if errors.Is(err, errUnauthorized) {
+			logger.Warn("Rejected delivery event", "provider", provider, "error", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logger.Warn("Invalid delivery event", "provider", provider, "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for i := range events {
+			events[i].Provider = provider
+			if events[i].Time.IsZero() {
+				events[i].Time = time.Now()
+			}
+		}
+		if len(events) > 0 {
+			select {
+			case r.events <- events:
+			case <-req.Context().Done():
+				return
+			case <-r.done:
+				http.Error(w, "receiver closed", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		logger.Debug("Received delivery events", "provider", provider, "count", len(events))
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// checkTimestamp verifies that the signed Unix timestamp of a request is
+// recent
+func checkTimestamp(timestamp string, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errUnauthorized
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return fmt.Errorf("%w: signed %s ago", errUnauthorized, age.Round(time.Second))
+	}
+	return nil
+}
+
+// tokenCache remembers the tokens of recent Mailgun requests, which are
+// unique per request, to reject replays within the signature age
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time // Token to the time it can be forgotten
+}
+
+// add records a token, reporting false if it was used before
+func (c *tokenCache) add(token string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, expires := range c.tokens {
+		if now.After(expires) {
+			delete(c.tokens, t)
+		}
+	}
+	if _, used := c.tokens[token]; used {
+		return false
+	}
+	// Timestamps are accepted up to maxSignatureAge either way of now
+	c.tokens[token] = now.Add(2 * maxSignatureAge)
+	return true
+}
+
+// checkBasicAuth verifies a request's basic auth credentials
+func checkBasicAuth(req *http.Request, auth *config.BasicAuth) error {
+	username, password, ok := req.BasicAuth()
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(username), []byte(auth.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(auth.Password)) != 1 {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// parseSendGridKey parses the base64 encoded public key of SendGrid's
+// signed event webhook
+func parseSendGridKey(encoded string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ECDSA public key")
+	}
+	return ecKey, nil
+}
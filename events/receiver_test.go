@@ -0,0 +1,191 @@
+package events
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mailgloss/config"
+)
+
+func TestMailgunSignature(t *testing.T) {
+	r, err := NewReceiver(&config.DeliveryEvents{MailgunSigningKey: "key-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	sign := func(timestamp, token string) string {
+		mac := hmac.New(sha256.New, []byte("key-secret"))
+		mac.Write([]byte(timestamp + token))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	send := func(timestamp, token, signature string) *httptest.ResponseRecorder {
+		payload := fmt.Sprintf(`{"signature":{"timestamp":%q,"token":%q,"signature":%q},
+			"event-data":{"event":"failed","severity":"permanent","timestamp":1700000001.5,"recipient":"bob@example.com",
+			"delivery-status":{"code":550,"description":"No such user"},"message":{"headers":{"message-id":"abc@mg.example.com"}}}}`,
+			timestamp, token, signature)
+		rec := httptest.NewRecorder()
+		r.handler().ServeHTTP(rec, httptest.NewRequest("POST", "/mailgun", strings.NewReader(payload)))
+		return rec
+	}
+
+	if rec := send(now, "token", "00ff"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature: status = %d, want 401", rec.Code)
+	}
+	if rec := send(stale, "old-token", sign(stale, "old-token")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("stale timestamp: status = %d, want 401", rec.Code)
+	}
+
+	rec := send(now, "token", sign(now, "token"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid signature: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	events := <-r.Events()
+	want := Event{Provider: "mailgun", MessageID: "abc@mg.example.com", Type: TypeBounced, Recipient: "bob@example.com", Detail: "550 No such user"}
+	if got := events[0]; got.Provider != want.Provider || got.MessageID != want.MessageID || got.Type != want.Type ||
+		got.Recipient != want.Recipient || got.Detail != want.Detail || got.Time.Unix() != 1700000001 {
+		t.Errorf("event = %+v, want %+v", got, want)
+	}
+
+	if rec := send(now, "token", sign(now, "token")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("replayed token: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestSendGridSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReceiver(&config.DeliveryEvents{SendGridVerificationKey: base64.StdEncoding.EncodeToString(der)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `[{"email":"bob@example.com","timestamp":1700000000,"event":"delivered","sg_message_id":"abc123.filter0001.1.0"},
+		{"email":"bob@example.com","timestamp":1700000100,"event":"click","url":"https://example.com","sg_message_id":"abc123.filter0001.1.0"}]`
+	send := func(body, timestamp string) int {
+		digest := sha256.Sum256([]byte(timestamp + body))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/sendgrid", strings.NewReader(body))
+		req.Header.Set(sendGridSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+		req.Header.Set(sendGridTimestampHeader, timestamp)
+		rec := httptest.NewRecorder()
+		r.handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	// Sign the original body but send a tampered one
+	digest := sha256.Sum256([]byte(now + body))
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	req := httptest.NewRequest("POST", "/sendgrid", strings.NewReader(strings.Replace(body, "delivered", "bounce", 1)))
+	req.Header.Set(sendGridSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+	req.Header.Set(sendGridTimestampHeader, now)
+	rec := httptest.NewRecorder()
+	r.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("tampered body: status = %d, want 401", rec.Code)
+	}
+
+	if code := send(body, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)); code != http.StatusUnauthorized {
+		t.Errorf("stale timestamp: status = %d, want 401", code)
+	}
+	if code := send(body, now); code != http.StatusOK {
+		t.Fatalf("valid signature: status = %d, want 200", code)
+	}
+	events := <-r.Events()
+	if len(events) != 2 || events[0].Type != TypeDelivered || events[1].Type != TypeClicked || events[1].Detail != "https://example.com" {
+		t.Errorf("events = %+v, want delivered and clicked", events)
+	}
+}
+
+func TestPostmarkBasicAuth(t *testing.T) {
+	r, err := NewReceiver(&config.DeliveryEvents{Postmark: &config.BasicAuth{Username: "hook", Password: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"RecordType":"Bounce","Type":"HardBounce","MessageID":"883953f4","Email":"bob@example.com",
+		"Description":"The server was unable to deliver your message","BouncedAt":"2024-01-02T10:00:00Z"}`
+	for _, tt := range []struct {
+		password string
+		want     int
+	}{{"wrong", http.StatusUnauthorized}, {"secret", http.StatusOK}} {
+		req := httptest.NewRequest("POST", "/postmark", strings.NewReader(body))
+		req.SetBasicAuth("hook", tt.password)
+		rec := httptest.NewRecorder()
+		r.handler().ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("password %q: status = %d, want %d", tt.password, rec.Code, tt.want)
+		}
+	}
+
+	events := <-r.Events()
+	if len(events) != 1 || events[0].Type != TypeBounced || events[0].Recipient != "bob@example.com" || events[0].MessageID != "883953f4" {
+		t.Errorf("events = %+v, want a bounce for bob@example.com", events)
+	}
+
+	// Providers without credentials aren't served
+	rec := httptest.NewRecorder()
+	r.handler().ServeHTTP(rec, httptest.NewRequest("POST", "/mailgun", strings.NewReader("{}")))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unconfigured provider: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestReceiverClose(t *testing.T) {
+	cfg := &config.DeliveryEvents{Listen: "127.0.0.1:0", Postmark: &config.BasicAuth{Username: "hook", Password: "secret"}}
+	r, err := NewReceiver(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr := r.Addr()
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	select {
+	case <-r.Done():
+	default:
+		t.Error("Done() is not closed after Close()")
+	}
+	if _, err := http.Post("http://"+addr+"/postmark", "application/json", strings.NewReader("{}")); err == nil {
+		t.Error("receiver still accepts requests after Close()")
+	}
+
+	// The address is free for a restarted receiver
+	cfg.Listen = addr
+	restarted, err := NewReceiver(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Start(); err != nil {
+		t.Fatalf("Start() after Close() error = %v", err)
+	}
+	restarted.Close()
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SendGrid signs event webhook requests with these headers
+const (
+	sendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	sendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// sendGridEvent is one event of a SendGrid webhook request
+type sendGridEvent struct {
+	Email       string `json:"email"`
+	Timestamp   int64  `json:"timestamp"`
+	Event       string `json:"event"`
+	MessageID   string `json:"sg_message_id"`
+	Reason      string `json:"reason"`
+	Response    string `json:"response"`
+	URL         string `json:"url"`
+	Type        string `json:"type"` // "bounce" or "blocked" for bounces
+	BounceClass string `json:"bounce_classification"`
+}
+
+// verifySendGrid checks the ECDSA signature of a SendGrid webhook request,
+// which covers the timestamp header followed by the body, and that the
+// timestamp is recent
+func verifySendGrid(key *ecdsa.PublicKey, header http.Header, body []byte, now time.Time) error {
+	signature, err := base64.StdEncoding.DecodeString(header.Get(sendGridSignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return errUnauthorized
+	}
+	digest := sha256.Sum256(append([]byte(header.Get(sendGridTimestampHeader)), body...))
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return errUnauthorized
+	}
+	return checkTimestamp(header.Get(sendGridTimestampHeader), now)
+}
+
+// parseSendGrid returns the events of a SendGrid webhook request
+func parseSendGrid(body []byte) ([]Event, error) {
+	var payload []sendGridEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid payload: %w", err)
+	}
+
+	events := make([]Event, 0, len(payload))
+	for _, e := range payload {
+		event := Event{
+			MessageID: e.MessageID,
+			Recipient: e.Email,
+			Time:      time.Unix(e.Timestamp, 0),
+			Detail:    e.Reason,
+		}
+		switch e.Event {
+		case "processed":
+			event.Type = TypeAccepted
+		case "delivered":
+			event.Type = TypeDelivered
+			event.Detail = e.Response
+		case "deferred":
+			event.Type = TypeDeferred
+			if event.Detail == "" {
+				event.Detail = e.Response
+			}
+		case "bounce":
+			event.Type = TypeBounced
+		case "dropped":
+			event.Type = TypeDropped
+		case "open":
+			event.Type = TypeOpened
+		case "click":
+			event.Type = TypeClicked
+			event.Detail = e.URL
+		case "spamreport":
+			event.Type = TypeComplained
+		case "unsubscribe", "group_unsubscribe":
+			event.Type = TypeUnsubscribed
+		default:
+			event.Type = e.Event
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// sparkPostEvent is the part of a SparkPost event used here. Events are
+// wrapped in an object named after their class, such as message_event.
+type sparkPostEvent struct {
+	Type           string `json:"type"`
+	TransmissionID string `json:"transmission_id"`
+	Recipient      string `json:"rcpt_to"`
+	Timestamp      string `json:"timestamp"`
+	Reason         string `json:"reason"`
+	RawReason      string `json:"raw_reason"`
+	TargetLinkURL  string `json:"target_link_url"`
+}
+
+// parseSparkPost returns the events of a SparkPost webhook request. Event
+// classes other than messages, tracking and unsubscribes are ignored.
+func parseSparkPost(body []byte) ([]Event, error) {
+	var payload []struct {
+		Msys map[string]json.RawMessage `json:"msys"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid SparkPost payload: %w", err)
+	}
+
+	var events []Event
+	for _, item := range payload {
+		for _, class := range []string{"message_event", "track_event", "unsubscribe_event"} {
+			raw, ok := item.Msys[class]
+			if !ok {
+				continue
+			}
+			var e sparkPostEvent
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return nil, fmt.Errorf("invalid SparkPost %s: %w", class, err)
+			}
+
+			event := Event{
+				MessageID: e.TransmissionID,
+				Recipient: e.Recipient,
+				Time:      sparkPostTime(e.Timestamp),
+				Detail:    e.RawReason,
+			}
+			if event.Detail == "" {
+				event.Detail = e.Reason
+			}
+			switch e.Type {
+			case "injection":
+				event.Type = TypeAccepted
+			case "delivery":
+				event.Type = TypeDelivered
+			case "delay":
+				event.Type = TypeDeferred
+			case "bounce", "out_of_band":
+				event.Type = TypeBounced
+			case "policy_rejection", "generation_rejection", "generation_failure":
+				event.Type = TypeDropped
+			case "spam_complaint":
+				event.Type = TypeComplained
+			case "open", "initial_open", "amp_open", "amp_initial_open":
+				event.Type = TypeOpened
+			case "click", "amp_click":
+				event.Type = TypeClicked
+				event.Detail = e.TargetLinkURL
+			case "list_unsubscribe", "link_unsubscribe":
+				event.Type = TypeUnsubscribed
+			default:
+				event.Type = e.Type
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// sparkPostTime parses a SparkPost timestamp, given in Unix seconds or as
+// RFC 3339 depending on the account
+func sparkPostTime(value string) time.Time {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return unixTime(seconds)
+	}
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
@@ -0,0 +1,289 @@
+package mailer
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive is a set of files and folders sent as a single zip attachment
+type Archive struct {
+	Name  string   // File name of the zip, e.g. "attachments.zip"
+	Paths []string // Files and folders to include
+}
+
+// errArchiveTooLarge is returned when an archive outgrows its size limit
+var errArchiveTooLarge = errors.New("archive too large")
+
+// archiveEntry is a file to add to an archive under a name
+type archiveEntry struct {
+	path string
+	name string
+	info fs.FileInfo
+}
+
+// ArchiveName returns the zip file name used for a folder attachment
+func ArchiveName(path string) string {
+	return filepath.Base(filepath.Clean(path)) + ".zip"
+}
+
+// writeArchive writes a zip of the given files and folders to w. Folders
+// keep their name as the top-level directory. With a password, entries are
+// encrypted with traditional PKWARE encryption, which common unzip tools
+// support. Writing stops with errArchiveTooLarge once the archive exceeds
+// limit bytes.
+func writeArchive(w io.Writer, paths []string, password string, limit int64) error {
+	entries, err := archiveEntries(paths)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("nothing to archive")
+	}
+
+	lw := &limitWriter{w: w, limit: limit}
+	zw := zip.NewWriter(lw)
+	for _, entry := range entries {
+		if err := writeArchiveEntry(zw, entry, password); err != nil {
+			if errors.Is(err, errArchiveTooLarge) {
+				return err
+			}
+			return fmt.Errorf("%s: %w", entry.path, err)
+		}
+	}
+	return zw.Close()
+}
+
+// archiveEntries lists the regular files under the given paths, named
+// relative to the parent of each path. Duplicate names get a numeric prefix.
+func archiveEntries(paths []string) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	used := map[string]bool{}
+	add := func(path, name string, info fs.FileInfo) {
+		unique := name
+		dir, base := filepath.Split(name)
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s%d-%s", dir, i, base)
+		}
+		used[unique] = true
+		entries = append(entries, archiveEntry{path: path, name: filepath.ToSlash(unique), info: info})
+	}
+
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				add(root, info.Name(), info)
+			}
+			continue
+		}
+
+		parent := filepath.Dir(root)
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Symlinks, devices and sockets are skipped
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(parent, path)
+			if err != nil {
+				return err
+			}
+			add(path, name, info)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// writeArchiveEntry deflates a file into the archive, encrypting it when a
+// password is set
+func writeArchiveEntry(zw *zip.Writer, entry archiveEntry, password string) error {
+	f, err := os.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Sizes and checksum follow the data in a data descriptor, so the file
+	// is only read once
+	fh := &zip.FileHeader{
+		Name:           entry.name,
+		Method:         zip.Deflate,
+		Flags:          0x8 | 0x800, // Data descriptor, UTF-8 names
+		CreatorVersion: 20,
+		ReaderVersion:  20,
+	}
+	fh.ModifiedDate, fh.ModifiedTime = msDosTime(entry.info.ModTime())
+	if password != "" {
+		fh.Flags |= 0x1
+	}
+
+	raw, err := zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+	compressed := &countWriter{w: raw}
+	var out io.Writer = compressed
+	if password != "" {
+		enc := newZipCrypto(password, compressed)
+		// The last header byte lets unzip tools check the password
+		header := make([]byte, 12)
+		if _, err := rand.Read(header); err != nil {
+			return err
+		}
+		header[11] = byte(fh.ModifiedTime >> 8)
+		if _, err := enc.Write(header); err != nil {
+			return err
+		}
+		out = enc
+	}
+
+	fw, err := flate.NewWriter(out, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	crc := crc32.NewIEEE()
+	size, err := io.Copy(io.MultiWriter(fw, crc), f)
+	if err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	fh.CRC32 = crc.Sum32()
+	fh.UncompressedSize64 = uint64(size)
+	fh.CompressedSize64 = uint64(compressed.n)
+	fh.UncompressedSize = uint32(min(fh.UncompressedSize64, 0xffffffff))
+	fh.CompressedSize = uint32(min(fh.CompressedSize64, 0xffffffff))
+	return nil
+}
+
+// msDosTime converts a time to the MS-DOS date and time of zip headers
+func msDosTime(t time.Time) (date, clock uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+// zipCrypto encrypts with the traditional PKWARE stream cipher
+type zipCrypto struct {
+	w    io.Writer
+	keys [3]uint32
+	buf  []byte
+}
+
+func newZipCrypto(password string, w io.Writer) *zipCrypto {
+	z := &zipCrypto{w: w, keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	return z
+}
+
+func (z *zipCrypto) update(b byte) {
+	z.keys[0] = crc32Update(z.keys[0], b)
+	z.keys[1] = (z.keys[1]+z.keys[0]&0xff)*134775813 + 1
+	z.keys[2] = crc32Update(z.keys[2], byte(z.keys[1]>>24))
+}
+
+// keyByte returns the next byte of the key stream
+func (z *zipCrypto) keyByte() byte {
+	t := uint16(z.keys[2] | 2)
+	return byte(uint32(t) * uint32(t^1) >> 8)
+}
+
+func (z *zipCrypto) Write(p []byte) (int, error) {
+	z.buf = z.buf[:0]
+	for _, b := range p {
+		z.buf = append(z.buf, b^z.keyByte())
+		z.update(b)
+	}
+	return z.w.Write(z.buf)
+}
+
+// crc32Update advances a CRC-32 by one byte without pre/post conditioning
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[(crc^uint32(b))&0xff] ^ crc>>8
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitWriter fails once more than limit bytes are written, if limit is set
+type limitWriter struct {
+	w     io.Writer
+	limit int64
+	n     int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.n+int64(len(p)) > l.limit {
+		return 0, errArchiveTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// folderSize returns the total size and number of regular files in a folder
+func folderSize(path string) (int64, int, error) {
+	var size int64
+	var files int
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		files++
+		return nil
+	})
+	return size, files, err
+}
+
+// isArchiveName reports whether a file name already has the zip extension
+func isArchiveName(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
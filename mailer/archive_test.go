@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	if err := os.MkdirAll(filepath.Join(project, "src"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"project/README":      "read me",
+		"project/src/main.go": "package main",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, password := range []string{"", "secret"} {
+		var buf bytes.Buffer
+		if err := writeArchive(&buf, []string{project}, password, 0); err != nil {
+			t.Fatalf("writeArchive(%q) error = %v", password, err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("zip.NewReader() error = %v", err)
+		}
+		if len(zr.File) != len(files) {
+			t.Fatalf("archive has %d files, want %d", len(zr.File), len(files))
+		}
+
+		for _, f := range zr.File {
+			var content []byte
+			if password == "" {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, _ = io.ReadAll(rc)
+				rc.Close()
+			} else {
+				if f.Flags&0x1 == 0 {
+					t.Fatalf("%s is not marked as encrypted", f.Name)
+				}
+				content = decryptEntry(t, f, password)
+			}
+			if string(content) != files[f.Name] {
+				t.Errorf("%s = %q, want %q", f.Name, content, files[f.Name])
+			}
+		}
+	}
+
+	if err := writeArchive(io.Discard, []string{project}, "", 10); !errors.Is(err, errArchiveTooLarge) {
+		t.Errorf("writeArchive() over the limit error = %v, want errArchiveTooLarge", err)
+	}
+}
+
+// decryptEntry decrypts and inflates a PKWARE encrypted entry
+func decryptEntry(t *testing.T, f *zip.File, password string) []byte {
+	t.Helper()
+	r, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := newZipCrypto(password, io.Discard)
+	for i := range data {
+		data[i] ^= z.keyByte()
+		z.update(data[i])
+	}
+	if data[11] != byte(f.ModifiedTime>>8) {
+		t.Fatalf("%s: password check byte mismatch", f.Name)
+	}
+	content, err := io.ReadAll(flate.NewReader(bytes.NewReader(data[12:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
@@ -0,0 +1,246 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mailgloss/config"
+)
+
+// dkimSigner adds a DKIM-Signature header (RFC 6376) to complete messages
+type dkimSigner struct {
+	domain     string
+	selector   string
+	key        crypto.Signer
+	algorithm  string // rsa-sha256 or ed25519-sha256
+	headers    []string
+	headerAlgo string // simple or relaxed
+	bodyAlgo   string
+}
+
+// newDKIMSigner loads the private key of a DKIM configuration for messages
+// from an address
+func newDKIMSigner(dc *config.DKIMConfig, from string) (*dkimSigner, error) {
+	domain := dc.GetDomain(from)
+	if domain == "" {
+		return nil, fmt.Errorf("no signing domain for %s", from)
+	}
+	key, err := loadDKIMKey(dc.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &dkimSigner{
+		domain:   domain,
+		selector: dc.Selector,
+		key:      key,
+		headers:  dc.GetHeaders(),
+	}
+	s.headerAlgo, s.bodyAlgo = dc.GetCanonicalization()
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		s.algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		s.algorithm = "ed25519-sha256"
+	}
+	return s, nil
+}
+
+// loadDKIMKey reads a PEM encoded RSA or Ed25519 private key
+func loadDKIMKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(config.ExpandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DKIM key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("DKIM key %s is not PEM encoded", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM key %s: %w", path, err)
+	}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("DKIM key %s must be an RSA or Ed25519 key", path)
+}
+
+// publicKeyRecord returns the p= value of the DNS record for the key
+func (s *dkimSigner) publicKeyRecord() (string, error) {
+	if key, ok := s.key.Public().(ed25519.PublicKey); ok {
+		return base64.StdEncoding.EncodeToString(key), nil
+	}
+	der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// sign returns the message with line endings normalized to CRLF and a
+// DKIM-Signature header in front
+func (s *dkimSigner) sign(msg []byte) ([]byte, error) {
+	msg = normalizeCRLF(msg)
+	header, body, found := bytes.Cut(msg, []byte("\r\n\r\n"))
+	if !found {
+		return nil, fmt.Errorf("message has no body")
+	}
+	fields := splitHeaderFields(string(header) + "\r\n")
+
+	bodyHash := sha256.Sum256(dkimBody(body, s.bodyAlgo))
+
+	// Each occurrence of a name signs the next field from the bottom up. A
+	// name without a field left is still listed, so a field of that name
+	// added later breaks the signature (RFC 6376 section 5.4.2).
+	h := sha256.New()
+	used := map[int]bool{}
+	for _, name := range s.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fieldName(fields[i]), name) {
+				continue
+			}
+			used[i] = true
+			h.Write([]byte(dkimHeader(fields[i], s.headerAlgo)))
+			break
+		}
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + s.algorithm,
+		"c=" + s.headerAlgo + "/" + s.bodyAlgo,
+		"d=" + s.domain,
+		"s=" + s.selector,
+		"t=" + strconv.FormatInt(time.Now().Unix(), 10),
+		"h=" + strings.Join(s.headers, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+		"b=",
+	}
+	field := "DKIM-Signature: " + strings.Join(tags, ";\r\n\t")
+
+	// The signature covers its own header field with an empty b= tag and
+	// without the trailing line break
+	h.Write([]byte(strings.TrimSuffix(dkimHeader(field+"\r\n", s.headerAlgo), "\r\n")))
+	opts := crypto.Hash(0)
+	if s.algorithm == "rsa-sha256" {
+		opts = crypto.SHA256
+	}
+	sig, err := s.key.Sign(rand.Reader, h.Sum(nil), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(field) + len(msg) + 512)
+	out.WriteString(field)
+	out.WriteString(base64.StdEncoding.EncodeToString(sig))
+	out.WriteString("\r\n")
+	out.Write(msg)
+	return out.Bytes(), nil
+}
+
+// normalizeCRLF turns bare LF line endings into CRLF
+func normalizeCRLF(b []byte) []byte {
+	if bytes.Count(b, []byte("\n")) == bytes.Count(b, []byte("\r\n")) {
+		return b
+	}
+	out := make([]byte, 0, len(b)+bytes.Count(b, []byte("\n")))
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// splitHeaderFields splits a header block into its fields, each including
+// continuation lines and the final CRLF
+func splitHeaderFields(header string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// fieldName returns the name of a header field
+func fieldName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+	return strings.TrimRight(name, " \t")
+}
+
+// dkimHeader canonicalizes a header field including its CRLF
+func dkimHeader(field, algo string) string {
+	if algo == config.DKIMSimple {
+		return field
+	}
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.Fields(value), " ")
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + value + "\r\n"
+}
+
+// dkimBody canonicalizes a message body
+func dkimBody(body []byte, algo string) []byte {
+	if algo == config.DKIMRelaxed {
+		lines := strings.SplitAfter(string(body), "\r\n")
+		var b strings.Builder
+		for _, line := range lines {
+			text, crlf := strings.CutSuffix(line, "\r\n")
+			// Whitespace runs become one space, trailing whitespace goes
+			text = strings.TrimRight(strings.NewReplacer("\t", " ").Replace(text), " ")
+			for strings.Contains(text, "  ") {
+				text = strings.ReplaceAll(text, "  ", " ")
+			}
+			b.WriteString(text)
+			if crlf {
+				b.WriteString("\r\n")
+			}
+		}
+		body = []byte(b.String())
+	}
+
+	// Trailing empty lines are ignored and the body ends in CRLF, except
+	// that an empty body stays empty in relaxed
+	if len(body) > 0 && !bytes.HasSuffix(body, []byte("\r\n")) {
+		body = append(body, '\r', '\n')
+	}
+	for bytes.HasSuffix(body, []byte("\r\n\r\n")) {
+		body = body[:len(body)-2]
+	}
+	if bytes.Equal(body, []byte("\r\n")) && algo == config.DKIMRelaxed {
+		return nil
+	}
+	if len(body) == 0 && algo == config.DKIMSimple {
+		return []byte("\r\n")
+	}
+	return body
+}
@@ -0,0 +1,216 @@
+package mailer
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"mailgloss/config"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+// The example of RFC 6376 section 3.4.6
+func TestDKIMCanonicalization(t *testing.T) {
+	fields := splitHeaderFields("A: X\r\nB : Y\t\r\n\tZ  \r\n")
+	var relaxed string
+	for _, f := range fields {
+		relaxed += dkimHeader(f, config.DKIMRelaxed)
+	}
+	if want := "a:X\r\nb:Y Z\r\n"; relaxed != want {
+		t.Errorf("relaxed header = %q, want %q", relaxed, want)
+	}
+
+	body := []byte(" C \r\nD \t E\r\n\r\n\r\n")
+	if got, want := string(dkimBody(body, config.DKIMRelaxed)), " C\r\nD E\r\n"; got != want {
+		t.Errorf("relaxed body = %q, want %q", got, want)
+	}
+	if got, want := string(dkimBody(body, config.DKIMSimple)), " C \r\nD \t E\r\n"; got != want {
+		t.Errorf("simple body = %q, want %q", got, want)
+	}
+	if got := string(dkimBody(nil, config.DKIMSimple)); got != "\r\n" {
+		t.Errorf("simple empty body = %q, want CRLF", got)
+	}
+	if got := dkimBody([]byte("\r\n\r\n"), config.DKIMRelaxed); len(got) != 0 {
+		t.Errorf("relaxed empty body = %q, want empty", got)
+	}
+}
+
+func TestDKIMSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "dkim.pem")
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, pemKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, canon := range []string{"relaxed/relaxed", "simple/simple"} {
+		signer, err := newDKIMSigner(&config.DKIMConfig{Selector: "mail", PrivateKey: keyPath, Canonicalization: canon}, "me@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := buildMIME(mail.Address{Address: "me@example.com"}, &message{Transmission: &gomail.Transmission{
+			Recipients: []string{"you@example.org"},
+			Subject:    "Signed",
+			PlainText:  "Hello\n\nBye",
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed, err := signer.sign(raw)
+		if err != nil {
+			t.Fatalf("%s: sign() error: %v", canon, err)
+		}
+
+		tags, err := checkDKIMSignature(string(signed), func(digest, sig []byte) error {
+			return rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, sig)
+		})
+		if err != nil {
+			t.Errorf("%s: %v", canon, err)
+		}
+		if tags["d"] != "example.com" || tags["s"] != "mail" || tags["c"] != canon {
+			t.Errorf("%s: tags = %v", canon, tags)
+		}
+		if tags["h"] != strings.Join(config.DefaultDKIMHeaders, ":") {
+			t.Errorf("%s: h = %q", canon, tags["h"])
+		}
+	}
+}
+
+// The signed example of RFC 8463 appendix A, whose ed25519 signature was
+// made by another implementation
+const rfc8463Message = "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+	" subject : date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+	" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" +
+	"From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe."
+
+// The private key seed and DNS public key of the brisbane selector
+const (
+	rfc8463Seed      = "nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A="
+	rfc8463PublicKey = "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+)
+
+func TestDKIMRFC8463(t *testing.T) {
+	pub, _ := base64.StdEncoding.DecodeString(rfc8463PublicKey)
+	verify := func(digest, sig []byte) error {
+		if !ed25519.Verify(ed25519.PublicKey(pub), digest, sig) {
+			return fmt.Errorf("ed25519 signature does not verify")
+		}
+		return nil
+	}
+
+	// The published signature verifies with our canonicalization
+	if _, err := checkDKIMSignature(rfc8463Message, verify); err != nil {
+		t.Fatalf("RFC 8463 example: %v", err)
+	}
+
+	// Signing the example with its key gives the published body hash and a
+	// signature for the published public key
+	seed, _ := base64.StdEncoding.DecodeString(rfc8463Seed)
+	der, err := x509.MarshalPKCS8PrivateKey(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "dkim.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := newDKIMSigner(&config.DKIMConfig{
+		Selector:         "brisbane",
+		PrivateKey:       keyPath,
+		Canonicalization: "relaxed/relaxed",
+		Headers:          []string{"From", "To", "Subject", "Date", "Message-ID", "From", "Subject", "Date"},
+	}, "joe@football.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := signer.publicKeyRecord(); record != rfc8463PublicKey {
+		t.Errorf("publicKeyRecord() = %s, want %s", record, rfc8463PublicKey)
+	}
+	_, unsigned, _ := strings.Cut(rfc8463Message, "Bus\r\n Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n")
+	signed, err := signer.sign([]byte(unsigned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := checkDKIMSignature(string(signed), verify)
+	if err != nil {
+		t.Errorf("signed example: %v", err)
+	}
+	if want := "2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8="; tags["bh"] != want {
+		t.Errorf("bh = %s, want %s", tags["bh"], want)
+	}
+	if want := "From:To:Subject:Date:Message-ID:From:Subject:Date"; tags["h"] != want {
+		t.Errorf("h = %s, want %s", tags["h"], want)
+	}
+}
+
+// checkDKIMSignature checks the first DKIM-Signature of a message the way a
+// receiving server does and returns its tags. Each name in h= selects the
+// next field of that name from the bottom, or nothing once they run out.
+func checkDKIMSignature(msg string, verify func(digest, sig []byte) error) (map[string]string, error) {
+	header, body, _ := strings.Cut(msg, "\r\n\r\n")
+	fields := splitHeaderFields(header + "\r\n")
+	sigField := fields[0]
+	tags := map[string]string{}
+	for _, tag := range strings.Split(strings.TrimPrefix(sigField, "DKIM-Signature:"), ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		tags[k] = strings.Join(strings.Fields(v), "")
+	}
+	headerAlgo, bodyAlgo, _ := strings.Cut(tags["c"], "/")
+
+	bodyHash := sha256.Sum256(dkimBody([]byte(body), bodyAlgo))
+	if got := base64.StdEncoding.EncodeToString(bodyHash[:]); got != tags["bh"] {
+		return tags, fmt.Errorf("bh = %s, body hashes to %s", tags["bh"], got)
+	}
+
+	h := sha256.New()
+	used := map[int]bool{}
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if !used[i] && strings.EqualFold(fieldName(fields[i]), strings.TrimSpace(name)) {
+				used[i] = true
+				h.Write([]byte(dkimHeader(fields[i], headerAlgo)))
+				break
+			}
+		}
+	}
+	unsigned := regexp.MustCompile(`b=[^;]*\r\n$`).ReplaceAllString(sigField, "b=\r\n")
+	h.Write([]byte(strings.TrimSuffix(dkimHeader(unsigned, headerAlgo), "\r\n")))
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return tags, err
+	}
+	if err := verify(h.Sum(nil), sig); err != nil {
+		return tags, fmt.Errorf("signature does not verify: %w", err)
+	}
+	return tags, nil
+}
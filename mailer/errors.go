@@ -0,0 +1,90 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"reflect"
+)
+
+// deliveryError wraps a driver error together with the provider's status code
+type deliveryError struct {
+	StatusCode int // HTTP status code, 0 if unknown
+	Err        error
+}
+
+func (e *deliveryError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%v (status %d)", e.Err, e.StatusCode)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the driver error and, for go-mail drivers, the error it
+// wraps, so that network errors can be recognized
+func (e *deliveryError) Unwrap() []error {
+	if cause := driverCause(e.Err); cause != nil {
+		return []error{e.Err, cause}
+	}
+	return []error{e.Err}
+}
+
+// driverCause returns the error wrapped by a go-mail error. Its error type
+// keeps the cause in an exported Err field but has no Unwrap method.
+func driverCause(err error) error {
+	v := reflect.ValueOf(err)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	f := v.Elem().FieldByName("Err")
+	if !f.IsValid() || f.Type() != reflect.TypeFor[error]() || f.IsNil() {
+		return nil
+	}
+	return f.Interface().(error)
+}
+
+// IsTransient reports whether a send error is likely temporary, so that
+// retrying later or with another provider may succeed. Only the error's
+// type is considered: a provider's error message may mention a timeout
+// for a message it accepted, and retrying it elsewhere would send it twice.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var de *deliveryError
+	if errors.As(err, &de) {
+		switch {
+		case de.StatusCode == http.StatusTooManyRequests,
+			de.StatusCode == http.StatusRequestTimeout,
+			de.StatusCode >= 500:
+			return true
+		case de.StatusCode >= 400:
+			return false
+		}
+	}
+
+	// SMTP replies in the 4xx range are temporary by definition
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// Failing to connect means nothing was sent
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"mailgloss/config"
+
+	"github.com/ainsleyclark/go-mail/mail"
+)
+
+// stubDriver returns a fixed response and error for every send
+type stubDriver struct {
+	resp  mail.Response
+	err   error
+	calls int
+}
+
+func (d *stubDriver) Send(t *mail.Transmission) (mail.Response, error) {
+	d.calls++
+	return d.resp, d.err
+}
+
+func stubMailer(name string, driver *stubDriver) *Mailer {
+	return &Mailer{
+		driver:          driver,
+		providerConfig:  &config.ProviderConfig{Name: name, Type: config.ProviderMailgun},
+		maxAttachmentMB: 25,
+	}
+}
+
+// driverError has the shape of go-mail's error type
+type driverError struct {
+	Err error
+}
+
+func (e *driverError) Error() string { return "go-mail: " + e.Err.Error() }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &deliveryError{StatusCode: 429, Err: errors.New("limit")}, true},
+		{"server error", &deliveryError{StatusCode: 503, Err: errors.New("unavailable")}, true},
+		{"unauthorized", &deliveryError{StatusCode: 401, Err: errors.New("forbidden")}, false},
+		{"smtp temporary", fmt.Errorf("wrapped: %w", &textproto.Error{Code: 421, Msg: "try later"}), true},
+		{"smtp permanent", &textproto.Error{Code: 550, Msg: "no such user"}, false},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"go-mail timeout", &deliveryError{Err: &driverError{Err: &url.Error{Op: "Post", Err: timeoutError{}}}}, true},
+		{"connection closed", fmt.Errorf("reading reply: %w", io.ErrUnexpectedEOF), true},
+		{"timeout in message", &deliveryError{Err: errors.New("upstream timeout, message queued")}, false},
+		{"validation", errors.New("subject is required"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailoverSend(t *testing.T) {
+	data := EmailData{To: []string{"to@example.com"}, Subject: "Hi", Body: "Hello"}
+
+	t.Run("falls back on transient error", func(t *testing.T) {
+		primary := &stubDriver{resp: mail.Response{StatusCode: 429}, err: errors.New("rate limited")}
+		backup := &stubDriver{resp: mail.Response{StatusCode: 200}}
+		ml, _ := NewFailover("chain", []*Mailer{stubMailer("primary", primary), stubMailer("backup", backup)})
+
+		result, err := ml.Send(data)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if result.ProviderName != "backup" || len(result.Attempts) != 2 {
+			t.Errorf("Send() result = %+v", result)
+		}
+	})
+
+	t.Run("stops on permanent error", func(t *testing.T) {
+		primary := &stubDriver{resp: mail.Response{StatusCode: 400}, err: errors.New("bad request")}
+		backup := &stubDriver{}
+		ml, _ := NewFailover("chain", []*Mailer{stubMailer("primary", primary), stubMailer("backup", backup)})
+
+		result, err := ml.Send(data)
+		if err == nil {
+			t.Fatal("Send() expected error")
+		}
+		if backup.calls != 0 || result.ProviderName != "primary" {
+			t.Errorf("backup called %d times, result = %+v", backup.calls, result)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"mailgloss/config"
+)
+
+// Priority is the importance of an email
+type Priority string
+
+const (
+	PriorityNormal Priority = ""
+	PriorityHigh   Priority = "high"
+	PriorityLow    Priority = "low"
+)
+
+// ParsePriority converts user input to a Priority
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high", "urgent":
+		return PriorityHigh, nil
+	case "low":
+		return PriorityLow, nil
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority '%s' (use high, normal or low)", s)
+}
+
+// reservedHeaders are set by the mailer or the provider and can't be
+// overridden with custom headers
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+	"Reply-To":                  true, // Use EmailData.ReplyTo
+}
+
+// ValidateHeaderName checks that a custom header name is well-formed and
+// not managed by the mailer
+func ValidateHeaderName(name string) error {
+	if name == "" {
+		return fmt.Errorf("header name is empty")
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || r == ':' {
+			return fmt.Errorf("invalid header name '%s'", name)
+		}
+	}
+	if reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+		return fmt.Errorf("header '%s' can't be set as a custom header", name)
+	}
+	return nil
+}
+
+// ParseHeaders parses custom headers in the form "Name: value; Name: value"
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, field := range strings.Split(s, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("header '%s' must be in the form Name: value", field)
+		}
+		name = strings.TrimSpace(name)
+		if err := ValidateHeaderName(name); err != nil {
+			return nil, err
+		}
+		value = strings.TrimSpace(value)
+		if err := validateHeaderValue(name, value); err != nil {
+			return nil, err
+		}
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	return headers, nil
+}
+
+// FormatHeaders renders headers in the form accepted by ParseHeaders
+func FormatHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, name+": "+headers[name])
+	}
+	return strings.Join(fields, "; ")
+}
+
+// buildHeaders combines the custom headers, Reply-To, priority and read
+// receipt request of an email into the headers of a transmission
+func buildHeaders(data EmailData, pc *config.ProviderConfig) (map[string]string, error) {
+	headers := map[string]string{}
+	for name, value := range data.Headers {
+		if err := ValidateHeaderName(name); err != nil {
+			return nil, err
+		}
+		if err := validateHeaderValue(name, value); err != nil {
+			return nil, err
+		}
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+
+	if data.ReplyTo != "" {
+		if _, err := mail.ParseAddress(data.ReplyTo); err != nil {
+			return nil, fmt.Errorf("invalid Reply-To address '%s': %w", data.ReplyTo, err)
+		}
+		if err := validateHeaderValue("Reply-To", data.ReplyTo); err != nil {
+			return nil, err
+		}
+		headers["Reply-To"] = data.ReplyTo
+	}
+
+	switch data.Priority {
+	case PriorityNormal:
+	case PriorityHigh:
+		headers["X-Priority"] = "1 (Highest)"
+		headers["Importance"] = "high"
+		headers["Priority"] = "urgent"
+	case PriorityLow:
+		headers["X-Priority"] = "5 (Lowest)"
+		headers["Importance"] = "low"
+		headers["Priority"] = "non-urgent"
+	default:
+		return nil, fmt.Errorf("unknown priority '%s'", data.Priority)
+	}
+
+	if data.ReadReceipt {
+		headers["Disposition-Notification-To"] = pc.FromAddress
+	}
+
+	// The SparkPost driver replaces its Cc header with the custom headers
+	if pc.Type == config.ProviderSparkPost && len(headers) > 0 && len(data.CC) > 0 {
+		headers["cc"] = strings.Join(data.CC, ",")
+	}
+
+	return headers, nil
+}
+
+// validateHeaderValue rejects control characters in a header value. A CR
+// or LF would end the field and let the value add header fields or body
+// content of its own.
+func validateHeaderValue(name, value string) error {
+	for _, r := range value {
+		if (r < ' ' && r != '\t') || r == 0x7f {
+			return fmt.Errorf("header '%s' contains a control character", name)
+		}
+	}
+	return nil
+}
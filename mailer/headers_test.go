@@ -0,0 +1,166 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"mailgloss/config"
+
+	"github.com/ainsleyclark/go-mail/drivers"
+	"github.com/ainsleyclark/go-mail/mail"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{input: "", want: map[string]string{}},
+		{input: "x-campaign: spring; X-Team:ops", want: map[string]string{"X-Campaign": "spring", "X-Team": "ops"}},
+		{input: "X-Campaign spring", wantErr: true},
+		{input: "Subject: override", wantErr: true},
+		{input: "Reply-To: a@example.com", wantErr: true},
+		{input: "Bad Name: value", wantErr: true},
+		{input: "X-Tab: a\tb", want: map[string]string{"X-Tab": "a\tb"}},
+		{input: "X-Bell: ring\x07", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHeaders(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHeaders(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseHeaders(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("ParseHeaders(%q)[%s] = %q, want %q", tt.input, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestBuildHeaders(t *testing.T) {
+	data := EmailData{
+		ReplyTo:     "help@example.com",
+		Headers:     map[string]string{"x-campaign": "spring"},
+		Priority:    PriorityHigh,
+		ReadReceipt: true,
+	}
+
+	headers, err := buildHeaders(data, &config.ProviderConfig{Type: config.ProviderMailgun, FromAddress: "me@example.com"})
+	if err != nil {
+		t.Fatalf("buildHeaders() error = %v", err)
+	}
+	want := map[string]string{
+		"Reply-To":                    "help@example.com",
+		"X-Campaign":                  "spring",
+		"X-Priority":                  "1 (Highest)",
+		"Importance":                  "high",
+		"Priority":                    "urgent",
+		"Disposition-Notification-To": "me@example.com",
+	}
+	for k, v := range want {
+		if headers[k] != v {
+			t.Errorf("headers[%s] = %q, want %q", k, headers[k], v)
+		}
+	}
+
+	// A CR or LF in a value must not be able to add header fields
+	for _, value := range []string{"spring\r\nBcc: victim@example.com", "spring\nX-Injected: 1", "spring\r"} {
+		data.Headers = map[string]string{"X-Campaign": value}
+		if _, err := buildHeaders(data, &config.ProviderConfig{Type: config.ProviderMailgun}); err == nil {
+			t.Errorf("buildHeaders() accepted header value %q", value)
+		}
+	}
+}
+
+func TestWriteHeaderControlCharacters(t *testing.T) {
+	var b bytes.Buffer
+	header := map[string][]string{"X-Campaign": {"spring\r\n\r\nbody"}}
+	if err := writeHeader(&b, header); err == nil {
+		t.Errorf("writeHeader() accepted a value with CRLF, wrote %q", b.String())
+	}
+}
+
+// captureTransport records the body of a request and replies with an empty
+// success response
+type captureTransport struct {
+	body []byte
+}
+
+func (c *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.body, _ = io.ReadAll(req.Body)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestReplyToTransport(t *testing.T) {
+	tests := []struct {
+		provider config.Provider
+		driver   func(mail.Config) (mail.Mailer, error)
+		want     string // JSON of the reply-to field
+		path     []string
+	}{
+		{config.ProviderSendGrid, drivers.NewSendGrid, `{"email":"help@example.com","name":"Help Desk"}`, []string{"reply_to"}},
+		{config.ProviderPostmark, drivers.NewPostmark, `"Help Desk \u003chelp@example.com\u003e"`, []string{"ReplyTo"}},
+		{config.ProviderSparkPost, drivers.NewSparkPost, `"Help Desk \u003chelp@example.com\u003e"`, []string{"content", "reply_to"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.provider), func(t *testing.T) {
+			capture := &captureTransport{}
+			driver, err := tt.driver(mail.Config{
+				URL:         "https://api.example.com",
+				APIKey:      "key",
+				FromAddress: "me@example.com",
+				FromName:    "Me",
+				Client:      &http.Client{Transport: &replyToTransport{provider: tt.provider, next: capture}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = driver.Send(&mail.Transmission{
+				Recipients: []string{"to@example.com"},
+				Subject:    "Hi",
+				HTML:       "<p>Hello</p>",
+				Headers:    map[string]string{"Reply-To": "Help Desk <help@example.com>", "X-Campaign": "spring"},
+			})
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			if bytes.Contains(capture.body, []byte(`"Reply-To"`)) || !bytes.Contains(capture.body, []byte(`"X-Campaign"`)) {
+				t.Errorf("headers not moved: %s", capture.body)
+			}
+			var field interface{}
+			var payload map[string]json.RawMessage
+			json.Unmarshal(capture.body, &payload)
+			for i, key := range tt.path {
+				if i < len(tt.path)-1 {
+					json.Unmarshal(payload[key], &payload)
+					continue
+				}
+				json.Unmarshal(payload[key], &field)
+			}
+			got, _ := json.Marshal(field)
+			if string(got) != tt.want {
+				t.Errorf("reply-to field = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
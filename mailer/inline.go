@@ -0,0 +1,150 @@
+package mailer
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlDocumentPattern = regexp.MustCompile(`(?is)^\s*(<!doctype\s+html|<html[\s>]|<body[\s>]|<(p|div|table|h[1-6]|img)\b[^>]*>.*</?[a-z][^>]*>)`)
+	imgSrcPattern       = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*)("[^"]*"|'[^']*')`)
+	scriptStylePattern  = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	blockEndPattern     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|tr|h[1-6]|li|table)>`)
+	tagPattern          = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesPattern   = regexp.MustCompile(`\n{3,}`)
+)
+
+// IsHTML reports whether a body is an HTML document rather than plain text
+func IsHTML(body string) bool {
+	return htmlDocumentPattern.MatchString(body)
+}
+
+// htmlToText derives a plain-text alternative from an HTML body
+func htmlToText(body string) string {
+	text := scriptStylePattern.ReplaceAllString(body, "")
+	text = blockEndPattern.ReplaceAllString(text, "\n")
+	text = tagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(text, "\n\n"))
+}
+
+// appendToBody inserts an HTML fragment at the end of an HTML document's body
+func appendToBody(document, fragment string) string {
+	if i := strings.LastIndex(strings.ToLower(document), "</body>"); i != -1 {
+		return document[:i] + fragment + document[i:]
+	}
+	return document + fragment
+}
+
+// embedInlineImages collects the inline images and rewrites local <img src>
+// paths in the HTML body to cid: references, embedding those files too.
+// Images are referenced by their file name, e.g. <img src="cid:logo.png">.
+func embedInlineImages(htmlBody string, paths []string, inspect func(path string) (filePart, error)) (string, []filePart, error) {
+	var images []filePart
+	byPath := map[string]string{} // absolute path -> Content-ID
+	usedIDs := map[string]bool{}
+
+	add := func(path string) (string, error) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		if id, ok := byPath[abs]; ok {
+			return id, nil
+		}
+		part, err := inspect(path)
+		if err != nil {
+			return "", err
+		}
+
+		part.ContentID = contentID(part.Filename, usedIDs)
+		byPath[abs] = part.ContentID
+		images = append(images, part)
+		return part.ContentID, nil
+	}
+
+	for _, path := range paths {
+		if _, err := add(path); err != nil {
+			return "", nil, fmt.Errorf("inline image %s: %w", path, err)
+		}
+	}
+
+	var rewriteErr error
+	rewritten := imgSrcPattern.ReplaceAllStringFunc(htmlBody, func(tag string) string {
+		m := imgSrcPattern.FindStringSubmatch(tag)
+		quote := m[2][:1]
+		src := html.UnescapeString(m[2][1 : len(m[2])-1])
+
+		path, ok := localImagePath(src)
+		if !ok || rewriteErr != nil {
+			return tag
+		}
+		id, err := add(path)
+		if err != nil {
+			rewriteErr = fmt.Errorf("inline image %s: %w", src, err)
+			return tag
+		}
+		return m[1] + quote + "cid:" + id + quote
+	})
+	if rewriteErr != nil {
+		return "", nil, rewriteErr
+	}
+
+	return rewritten, images, nil
+}
+
+// localImagePath returns the file path of an <img src> that refers to a
+// local file rather than a URL or an existing cid: reference
+func localImagePath(src string) (string, bool) {
+	src = strings.TrimSpace(src)
+	if src == "" || strings.HasPrefix(src, "//") || strings.HasPrefix(src, "#") {
+		return "", false
+	}
+
+	if u, err := url.Parse(src); err == nil && u.Scheme != "" {
+		if strings.EqualFold(u.Scheme, "file") {
+			return u.Path, true
+		}
+		return "", false
+	}
+
+	if strings.HasPrefix(src, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		src = filepath.Join(homeDir, src[2:])
+	}
+	return filepath.FromSlash(src), true
+}
+
+// contentID derives a unique Content-ID from a file name
+func contentID(filename string, used map[string]bool) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, filename)
+	if id == "" {
+		id = "image"
+	}
+
+	unique := id
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%d-%s", i, id)
+	}
+	used[unique] = true
+	return unique
+}
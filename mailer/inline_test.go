@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+func TestEmbedInlineImages(t *testing.T) {
+	dir := t.TempDir()
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(logo, []byte("png"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `<html><body><img src="` + logo + `"><img src='` + logo + `'>` +
+		`<img src="https://example.com/a.png"><img src="cid:other"></body></html>`
+
+	rewritten, images, err := embedInlineImages(body, []string{logo}, newFilePart)
+	if err != nil {
+		t.Fatalf("embedInlineImages() error = %v", err)
+	}
+	if len(images) != 1 || images[0].ContentID != "logo.png" {
+		t.Fatalf("images = %+v, want one image with Content-ID logo.png", images)
+	}
+	want := `<html><body><img src="cid:logo.png"><img src='cid:logo.png'>` +
+		`<img src="https://example.com/a.png"><img src="cid:other"></body></html>`
+	if rewritten != want {
+		t.Errorf("rewritten = %s\nwant %s", rewritten, want)
+	}
+
+	if images[0].ContentType != "image/png" || images[0].Size != 3 {
+		t.Errorf("image = %+v, want image/png of 3 bytes", images[0])
+	}
+
+	if _, _, err := embedInlineImages(`<img src="./missing.png">`, nil, newFilePart); err == nil {
+		t.Error("embedInlineImages() with missing file: expected error")
+	}
+}
+
+func TestBuildMIMEInline(t *testing.T) {
+	raw, err := buildMIME(mail.Address{Address: "me@example.com"}, &message{
+		Transmission: &gomail.Transmission{
+			Recipients: []string{"to@example.com"},
+			Subject:    "Logo",
+			HTML:       `<img src="cid:logo.png">`,
+			PlainText:  "Logo",
+		},
+		Inline: []filePart{{Filename: "logo.png", ContentType: "image/png", Size: 3, ContentID: "logo.png", data: []byte("png")}},
+	})
+	if err != nil {
+		t.Fatalf("buildMIME() error = %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+
+	part, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if mediaType != "multipart/related" {
+		t.Fatalf("first part = %s, want multipart/related", mediaType)
+	}
+
+	related := multipart.NewReader(part, params["boundary"])
+	if _, err := related.NextPart(); err != nil { // multipart/alternative
+		t.Fatal(err)
+	}
+	img, err := related.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Header.Get("Content-ID"); got != "<logo.png>" {
+		t.Errorf("Content-ID = %s, want <logo.png>", got)
+	}
+	data, _ := io.ReadAll(img)
+	if strings.TrimSpace(string(data)) != "cG5n" {
+		t.Errorf("image data = %q, want base64 of png", data)
+	}
+}
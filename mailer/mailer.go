@@ -1,11 +1,14 @@
 package mailer
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"html"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"mailgloss/config"
 	"mailgloss/logger"
@@ -16,21 +19,115 @@ import (
 
 // EmailData represents an email to send
 type EmailData struct {
-	From        string // Optional override for From address
-	FromName    string // Optional override for From name
-	To          []string
-	CC          []string
-	BCC         []string
-	Subject     string
-	Body        string
-	Attachments []string // File paths
+	From     string // Optional override for From address
+	FromName string // Optional override for From name
+	ReplyTo  string // Optional Reply-To address
+	To       []string
+	CC       []string
+	BCC      []string
+	Subject  string
+	Body     string
+	// Signature is the plain-text signature block at the end of Body. The
+	// HTML version renders it separately, using HTMLSignature if set.
+	Signature     string
+	HTMLSignature string
+	Attachments   []string // File paths, folders are sent as zip archives
+	// Archives bundle several files into one zip attachment each
+	Archives []Archive
+	// ArchivePassword encrypts the zip archives of this email if set
+	ArchivePassword string
+	// InlineImages are embedded images referenced from the HTML body as
+	// cid:<file name>. Local <img src> paths in an HTML body are added
+	// automatically.
+	InlineImages []string
+	// Headers are additional custom headers, typically X- headers
+	Headers     map[string]string
+	Priority    Priority
+	ReadReceipt bool // Request a read receipt to the From address
+	// QueueID identifies the send in Scheduler.Status while it waits for
+	// a rate limit, optional
+	QueueID int
+	// PGPSign signs the message with the sender's PGP key, see
+	// config.PGPConfig. PGPEncrypt encrypts it to the recipients' keys in
+	// PGPKeys, which must include a key for every recipient.
+	PGPSign    bool
+	PGPEncrypt bool
+	PGPKeys    []*PGPKey
+	// SMIMESign signs the message with the sender's certificate, see
+	// config.SMIMEConfig. SMIMEEncrypt encrypts it to the recipients'
+	// certificates in SMIMECerts, which must include one for every
+	// recipient. PGP and S/MIME can't be combined.
+	SMIMESign    bool
+	SMIMEEncrypt bool
+	SMIMECerts   []*x509.Certificate
+}
+
+// maxResponseBody is the length a provider's response body is cut to
+const maxResponseBody = 2048
+
+// Result describes how an email was delivered
+type Result struct {
+	ProviderName string    // Provider that handled the final attempt
+	ProviderType string    // Type of that provider
+	Response               // Reply to the final attempt, empty for batches
+	Attempts     []Attempt // Every provider tried, in order
+	// Batches are set when the recipients were split over several
+	// messages to stay within the provider's recipient limit
+	Batches []Batch
+}
+
+// Batch is one of the messages a send was split into
+type Batch struct {
+	Recipients   int      // To, Cc and Bcc recipients of this message
+	BCC          []string // Bcc recipients of this message
+	ProviderName string
+	Error        string // Empty if the batch was sent
+	Response
+}
+
+// Failed returns the number of batches that could not be sent
+func (r *Result) Failed() int {
+	failed := 0
+	for _, b := range r.Batches {
+		if b.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Attempt records a single delivery attempt
+type Attempt struct {
+	ProviderName string
+	Error        string // Empty if the attempt succeeded
+	Response
+}
+
+// Response is what a provider replied to a message, for finding it in the
+// provider's logs and dashboards
+type Response struct {
+	MessageID  string // Provider's ID for the message, empty if it returns none
+	StatusCode int    // HTTP status, or the SMTP reply code
+	Body       string // Response body or SMTP reply text
 }
 
 // Mailer wraps the go-mail functionality
 type Mailer struct {
 	driver          mail.Mailer
+	raw             messageSender // nil if the provider can't accept a complete message
 	providerConfig  *config.ProviderConfig
 	maxAttachmentMB int
+	scheduler       *Scheduler // nil to send without rate limits
+
+	// The sender's secret key and certificate are read on first use, so a
+	// missing or locked key only fails the messages that need it
+	keyMu  sync.Mutex
+	pgpKey *PGPKey        // nil until loaded
+	smime  *smimeIdentity // nil until loaded
+
+	// Failover chains have no driver of their own and delegate to members
+	chainName string
+	chain     []*Mailer
 }
 
 // New creates a new Mailer from a provider configuration
@@ -50,6 +147,7 @@ func NewWithLimits(pc *config.ProviderConfig, maxAttachmentMB int) (*Mailer, err
 	mailConfig := mail.Config{
 		FromAddress: pc.FromAddress,
 		FromName:    pc.FromName,
+		Client:      newReplyToClient(pc.Type),
 	}
 
 	switch pc.Type {
@@ -84,6 +182,15 @@ func NewWithLimits(pc *config.ProviderConfig, maxAttachmentMB int) (*Mailer, err
 		mailConfig.APIKey = pc.Postal.APIKey
 		driver, err = drivers.NewPostal(mailConfig)
 
+	case config.ProviderWebhook:
+		driver, err = newWebhook(pc)
+
+	case config.ProviderSES:
+		driver, err = newSES(pc)
+
+	case config.ProviderSendmail:
+		driver, err = newSendmail(pc)
+
 	default:
 		return nil, fmt.Errorf("unsupported provider type: %s", pc.Type)
 	}
@@ -92,34 +199,235 @@ func NewWithLimits(pc *config.ProviderConfig, maxAttachmentMB int) (*Mailer, err
 		return nil, fmt.Errorf("failed to create mail driver: %w", err)
 	}
 
+	// Inline images bypass the go-mail drivers, which can't represent them
+	raw, ok := driver.(messageSender)
+	if !ok {
+		if raw, err = newRawTransport(pc); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Mailer{
 		driver:          driver,
+		raw:             raw,
 		providerConfig:  pc,
 		maxAttachmentMB: maxAttachmentMB,
+		scheduler:       DefaultScheduler,
+	}, nil
+}
+
+// NewFailover creates a Mailer that tries each member in order, moving on to
+// the next one when a send fails with a transient error
+func NewFailover(name string, members []*Mailer) (*Mailer, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("failover chain '%s' has no providers", name)
+	}
+	return &Mailer{
+		chainName: name,
+		chain:     members,
 	}, nil
 }
 
-// Send sends an email using the configured provider
-func (m *Mailer) Send(data EmailData) error {
+// Send sends an email using the configured provider, or the providers of a
+// failover chain in order. Recipients over the provider's limit per message
+// are split into batches, see batchRecipients.
+func (m *Mailer) Send(data EmailData) (*Result, error) {
+	// Bcc-only emails are addressed to the sender, like later batches
+	if len(data.To)+len(data.CC) == 0 && len(data.BCC) > 0 {
+		data.To = []string{m.senderAddress(data)}
+	}
+
+	batches, err := m.batchRecipients(data)
+	if err != nil {
+		return &Result{ProviderName: m.GetProviderName(), ProviderType: m.GetProviderType()}, err
+	}
+	if len(batches) == 1 {
+		return m.sendBatch(batches[0])
+	}
+
+	logger.Info("Sending in batches", "provider", m.GetProviderName(), "batches", len(batches))
+	result := &Result{}
+	var firstErr error
+	for _, batch := range batches {
+		r, err := m.sendBatch(batch)
+		result.ProviderName = r.ProviderName
+		result.ProviderType = r.ProviderType
+		result.Attempts = append(result.Attempts, r.Attempts...)
+
+		b := Batch{
+			Recipients:   len(batch.To) + len(batch.CC) + len(batch.BCC),
+			BCC:          batch.BCC,
+			ProviderName: r.ProviderName,
+			Response:     r.Response,
+		}
+		if err != nil {
+			b.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		result.Batches = append(result.Batches, b)
+	}
+
+	if firstErr != nil {
+		return result, fmt.Errorf("%d of %d batches failed: %w", result.Failed(), len(batches), firstErr)
+	}
+	return result, nil
+}
+
+// sendBatch sends one message through the provider or failover chain
+func (m *Mailer) sendBatch(data EmailData) (*Result, error) {
+	if len(m.chain) == 0 {
+		resp, err := m.send(data)
+		return &Result{
+			ProviderName: m.providerConfig.Name,
+			ProviderType: string(m.providerConfig.Type),
+			Response:     resp,
+			Attempts:     []Attempt{newAttempt(m.providerConfig.Name, resp, err)},
+		}, err
+	}
+
+	result := &Result{}
+	for i, member := range m.chain {
+		resp, err := member.send(data)
+		result.ProviderName = member.GetProviderName()
+		result.ProviderType = member.GetProviderType()
+		result.Response = resp
+		result.Attempts = append(result.Attempts, newAttempt(member.GetProviderName(), resp, err))
+
+		if err == nil {
+			return result, nil
+		}
+		if i == len(m.chain)-1 || !IsTransient(err) {
+			return result, err
+		}
+
+		next := m.chain[i+1].GetProviderName()
+		logger.Warn("Transient send failure, trying next provider", "chain", m.chainName, "failed", member.GetProviderName(), "next", next, "error", err)
+	}
+
+	return result, nil
+}
+
+// batchRecipients splits an email whose recipients exceed the provider's
+// limit per message into several, dividing the Bcc list. The first message
+// goes to the To and Cc recipients too; the others are addressed to the
+// sender, so nobody gets the message twice.
+func (m *Mailer) batchRecipients(data EmailData) ([]EmailData, error) {
+	limit := m.maxRecipients()
+	visible := len(data.To) + len(data.CC)
+	if limit == 0 || visible+len(data.BCC) <= limit {
+		return []EmailData{data}, nil
+	}
+	if visible > limit {
+		return nil, fmt.Errorf("%d To and Cc recipients exceed the limit of %d per message for %s, move some to Bcc", visible, limit, m.GetProviderName())
+	}
+	if limit < 2 {
+		return nil, fmt.Errorf("a limit of %d recipients per message is too low to send in batches", limit)
+	}
+
+	sender := m.senderAddress(data)
+	first := data
+	first.BCC = data.BCC[:min(limit-visible, len(data.BCC))]
+	batches := []EmailData{first}
+	for rest := data.BCC[len(first.BCC):]; len(rest) > 0; {
+		batch := data
+		batch.To = []string{sender}
+		batch.CC = nil
+		batch.BCC = rest[:min(limit-1, len(rest))]
+		rest = rest[len(batch.BCC):]
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// maxRecipients returns the recipient limit per message, the lowest of a
+// failover chain's providers. 0 means unlimited.
+func (m *Mailer) maxRecipients() int {
+	if len(m.chain) == 0 {
+		return m.providerConfig.GetMaxRecipients()
+	}
+	limit := 0
+	for _, member := range m.chain {
+		if l := member.maxRecipients(); l > 0 && (limit == 0 || l < limit) {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// senderAddress returns the From address of an email, or the address of
+// the first provider tried
+func (m *Mailer) senderAddress(data EmailData) string {
+	if data.From != "" {
+		return data.From
+	}
+	if len(m.chain) > 0 {
+		return m.chain[0].senderAddress(data)
+	}
+	return m.providerConfig.FromAddress
+}
+
+// newAttempt records the outcome of sending through a provider
+func newAttempt(providerName string, resp Response, err error) Attempt {
+	attempt := Attempt{ProviderName: providerName, Response: resp}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return attempt
+}
+
+// newResponse keeps the parts of a driver's response worth recording
+func newResponse(resp mail.Response) Response {
+	r := Response{
+		MessageID:  resp.ID,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(resp.Body)),
+	}
+	// SendGrid only returns the ID as a header
+	if r.MessageID == "" && resp.Headers != nil {
+		r.MessageID = resp.Headers.Get("X-Message-Id")
+	}
+	if r.Body == "" {
+		r.Body = resp.Message
+	}
+	if len(r.Body) > maxResponseBody {
+		r.Body = r.Body[:maxResponseBody] + "..."
+	}
+	return r
+}
+
+// send sends an email through this mailer's own driver and returns the
+// provider's response
+func (m *Mailer) send(data EmailData) (Response, error) {
 	logger.Debug("Sending email", "provider", m.providerConfig.Name, "to", data.To, "subject", data.Subject)
 
 	if len(data.To) == 0 {
-		return fmt.Errorf("at least one recipient is required")
+		return Response{}, fmt.Errorf("at least one recipient is required")
 	}
 
 	if data.Subject == "" {
-		return fmt.Errorf("subject is required")
+		return Response{}, fmt.Errorf("subject is required")
 	}
 
 	if data.Body == "" {
-		return fmt.Errorf("body is required")
+		return Response{}, fmt.Errorf("body is required")
 	}
 
 	// Note: Custom From address should be set in the provider config before creating the mailer.
 	// The driver's configured From address will be used for sending.
 
-	// Convert plain text body to simple HTML for providers that require it
-	htmlBody := convertPlainTextToHTML(data.Body)
+	plainText, htmlBody := renderBodies(data)
+
+	// Embed inline images, rewriting local image paths to cid: references
+	htmlBody, inline, err := embedInlineImages(htmlBody, data.InlineImages, m.inspectAttachment)
+	if err != nil {
+		logger.Error("Inline image failed", "error", err)
+		return Response{}, err
+	}
+	if len(inline) > 0 && m.raw == nil {
+		return Response{}, fmt.Errorf("%s provider does not support inline images", m.providerConfig.Type)
+	}
 
 	// Create transmission
 	tx := &mail.Transmission{
@@ -127,61 +435,134 @@ func (m *Mailer) Send(data EmailData) error {
 		CC:         data.CC,
 		BCC:        data.BCC,
 		Subject:    data.Subject,
-		PlainText:  data.Body,
+		PlainText:  plainText,
 		HTML:       htmlBody,
 	}
 
-	// Add attachments if any
-	if len(data.Attachments) > 0 {
-		logger.Debug("Processing attachments", "count", len(data.Attachments))
-		attachments := make([]mail.Attachment, 0, len(data.Attachments))
-		for _, path := range data.Attachments {
-			// Validate attachment before reading
-			if err := m.validateAttachment(path); err != nil {
-				logger.Error("Attachment validation failed", "path", path, "error", err)
-				return fmt.Errorf("invalid attachment %s: %w", path, err)
-			}
+	// Reply-To, priority, read receipt and custom headers
+	headers, err := buildHeaders(data, m.providerConfig)
+	if err != nil {
+		return Response{}, err
+	}
+	if len(headers) > 0 {
+		tx.Headers = headers
+	}
 
-			// Read file
-			fileData, err := os.ReadFile(path)
+	// Folders and bundles are zipped to a temporary directory first
+	files, archives := splitFolders(data.Attachments)
+	archives = append(archives, data.Archives...)
+	zipped, cleanup, err := m.buildArchives(archives, data.ArchivePassword)
+	defer cleanup()
+	if err != nil {
+		logger.Error("Failed to build archive", "error", err)
+		return Response{}, err
+	}
+
+	// Attachments are inspected up front and only read when sending
+	msg := &message{Transmission: tx, Inline: inline}
+	if len(files)+len(zipped) > 0 {
+		logger.Debug("Processing attachments", "count", len(files)+len(zipped))
+		for _, path := range append(files, zipped...) {
+			part, err := m.inspectAttachment(path)
 			if err != nil {
-				logger.Error("Failed to read attachment", "path", path, "error", err)
-				return fmt.Errorf("failed to read attachment %s: %w", path, err)
+				return Response{}, fmt.Errorf("attachment %s: %w", path, err)
 			}
+			logger.Debug("Attachment added", "filename", part.Filename, "type", part.ContentType, "size", part.Size)
+			msg.Attachments = append(msg.Attachments, part)
+		}
+	}
 
-			// Extract filename from path
-			filename := filepath.Base(path)
-			logger.Debug("Attachment added", "filename", filename, "size", len(fileData))
-
-			attachments = append(attachments, mail.Attachment{
-				Filename: filename,
-				Bytes:    fileData,
-			})
+	// PGP/MIME needs a transport that writes the message itself
+	if data.PGPSign || data.PGPEncrypt {
+		if !writesMIME(m.raw) {
+			return Response{}, fmt.Errorf("%s provider can't send PGP signed or encrypted messages", m.providerConfig.Type)
+		}
+		if msg.pgp, err = m.pgpOptions(data); err != nil {
+			return Response{}, err
+		}
+	}
+	if data.SMIMESign || data.SMIMEEncrypt {
+		if msg.pgp != nil {
+			return Response{}, fmt.Errorf("a message can't be protected with both PGP and S/MIME")
+		}
+		if !writesMIME(m.raw) {
+			return Response{}, fmt.Errorf("%s provider can't send S/MIME signed or encrypted messages", m.providerConfig.Type)
+		}
+		if msg.smime, err = m.smimeOptions(data); err != nil {
+			return Response{}, err
 		}
-		tx.Attachments = attachments
 	}
 
-	// Send email
-	_, err := m.driver.Send(tx)
+	if err := m.checkMessageSize(msg); err != nil {
+		logger.Error("Message too large", "provider", m.providerConfig.Name, "error", err)
+		return Response{}, err
+	}
+
+	// Wait for the provider's rate limits
+	release := m.scheduler.acquire(m.providerConfig, data.QueueID)
+	defer release()
+
+	// Send email, streaming the parts when the transport writes the message
+	// itself and reading them into the transmission otherwise. DKIM signed
+	// messages go through the raw transport, which signs them, and so does
+	// SMTP with implicit TLS, which the go-mail driver can't speak.
+	var resp mail.Response
+	if _, native := m.driver.(messageSender); native || len(inline) > 0 || msg.pgp != nil || msg.smime != nil || signsDKIM(m.raw) || implicitTLS(m.providerConfig) {
+		resp, err = m.raw.sendMessage(msg)
+	} else {
+		if err := loadAttachments(msg); err != nil {
+			return Response{}, err
+		}
+		resp, err = m.driver.Send(tx)
+	}
+	response := newResponse(resp)
 	if err != nil {
 		logger.Error("Failed to send email", "provider", m.providerConfig.Name, "error", err)
-		return fmt.Errorf("failed to send email: %w", err)
+		return response, fmt.Errorf("failed to send email: %w", &deliveryError{StatusCode: resp.StatusCode, Err: err})
 	}
 
-	logger.Info("Email sent successfully", "provider", m.providerConfig.Name, "to", data.To, "subject", data.Subject)
-	return nil
+	logger.Info("Email sent successfully", "provider", m.providerConfig.Name, "to", data.To, "subject", data.Subject, "message_id", response.MessageID)
+	return response, nil
 }
 
-// GetProviderName returns the name of the current provider config
+// GetProviderName returns the name of the current provider config or failover chain
 func (m *Mailer) GetProviderName() string {
+	if len(m.chain) > 0 {
+		return m.chainName
+	}
 	return m.providerConfig.Name
 }
 
 // GetProviderType returns the type of the current provider
 func (m *Mailer) GetProviderType() string {
+	if len(m.chain) > 0 {
+		return "failover"
+	}
 	return string(m.providerConfig.Type)
 }
 
+// renderBodies returns the plain-text and HTML parts of a message. HTML
+// bodies are sent as-is with a derived plain-text alternative, plain text
+// bodies are converted to simple HTML.
+func renderBodies(data EmailData) (string, string) {
+	body := strings.TrimSuffix(data.Body, data.Signature)
+	htmlBody := convertPlainTextToHTML(body)
+	plainText := data.Body
+	if IsHTML(body) {
+		htmlBody = body
+		plainText = htmlToText(body) + data.Signature
+	}
+
+	switch {
+	case data.HTMLSignature != "":
+		htmlBody = appendToBody(htmlBody, `<div class="signature">-- <br>`+"\n"+data.HTMLSignature+"</div>")
+	case data.Signature != "":
+		signature := html.EscapeString(strings.TrimLeft(data.Signature, "\n"))
+		htmlBody = appendToBody(htmlBody, `<div class="signature">`+strings.ReplaceAll(signature, "\n", "<br>\n")+"</div>")
+	}
+	return plainText, htmlBody
+}
+
 // convertPlainTextToHTML converts plain text to simple HTML
 // Preserves line breaks and escapes HTML special characters
 func convertPlainTextToHTML(plainText string) string {
@@ -191,8 +572,149 @@ func convertPlainTextToHTML(plainText string) string {
 	return "<html><body><p>" + escaped + "</p></body></html>"
 }
 
+// inspectAttachment validates an attachment or inline image and detects its type
+func (m *Mailer) inspectAttachment(path string) (filePart, error) {
+	if err := m.validateAttachment(path); err != nil {
+		logger.Error("Attachment validation failed", "path", path, "error", err)
+		return filePart{}, fmt.Errorf("invalid attachment: %w", err)
+	}
+
+	part, err := newFilePart(path)
+	if err != nil {
+		logger.Error("Failed to inspect attachment", "path", path, "error", err)
+		return filePart{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	return part, nil
+}
+
+// splitFolders separates the folders among attachment paths, which are sent
+// as one archive each
+func splitFolders(paths []string) ([]string, []Archive) {
+	var files []string
+	var archives []Archive
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			archives = append(archives, Archive{Name: ArchiveName(path), Paths: []string{path}})
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, archives
+}
+
+// buildArchives writes each archive as a zip file in a temporary directory
+// and returns their paths. The cleanup function removes the directory.
+func (m *Mailer) buildArchives(archives []Archive, password string) ([]string, func(), error) {
+	cleanup := func() {}
+	if len(archives) == 0 {
+		return nil, cleanup, nil
+	}
+
+	dir, err := os.MkdirTemp("", "mailgloss-archive-")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	limit := int64(m.maxAttachmentMB) * 1024 * 1024
+	used := map[string]bool{}
+	paths := make([]string, 0, len(archives))
+	for _, archive := range archives {
+		for _, path := range archive.Paths {
+			if strings.Contains(filepath.Clean(path), "..") {
+				return nil, cleanup, fmt.Errorf("archive %s: directory traversal not allowed", archive.Name)
+			}
+		}
+
+		name := filepath.Base(archive.Name)
+		if name == "" || name == "." || name == string(filepath.Separator) {
+			name = "attachments.zip"
+		}
+		if !isArchiveName(name) {
+			name += ".zip"
+		}
+		// Archives of folders with the same name go to separate directories
+		archiveDir := dir
+		if used[name] {
+			archiveDir = filepath.Join(dir, fmt.Sprintf("%d", len(paths)))
+			if err := os.Mkdir(archiveDir, 0700); err != nil {
+				return nil, cleanup, err
+			}
+		}
+		used[name] = true
+		path := filepath.Join(archiveDir, name)
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		err = writeArchive(f, archive.Paths, password, limit)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if errors.Is(err, errArchiveTooLarge) {
+			return nil, cleanup, fmt.Errorf("archive %s: larger than the %d MB attachment limit", name, m.maxAttachmentMB)
+		}
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("archive %s: %w", name, err)
+		}
+		logger.Debug("Archive created", "name", name, "files", len(archive.Paths), "encrypted", password != "")
+		paths = append(paths, path)
+	}
+	return paths, cleanup, nil
+}
+
+// checkMessageSize rejects a message whose encoded size exceeds the
+// provider's limit, naming the largest file to help trim it
+func (m *Mailer) checkMessageSize(msg *message) error {
+	limitMB := m.providerConfig.GetMaxMessageSizeMB()
+	size := msg.estimateSize()
+	if size <= int64(limitMB)*1024*1024 {
+		return nil
+	}
+
+	err := fmt.Errorf("message is about %s after encoding, over the %d MB limit for %s", FormatSize(size), limitMB, m.providerConfig.Name)
+	var largest *filePart
+	for _, parts := range [][]filePart{msg.Attachments, msg.Inline} {
+		for i := range parts {
+			if largest == nil || parts[i].Size > largest.Size {
+				largest = &parts[i]
+			}
+		}
+	}
+	if largest != nil {
+		err = fmt.Errorf("%w (largest file: %s, %s)", err, largest.Filename, FormatSize(largest.Size))
+	}
+	return err
+}
+
+// loadAttachments reads the message's attachments into its transmission
+// for go-mail drivers, which need the content in memory
+func loadAttachments(msg *message) error {
+	msg.Transmission.Attachments = make([]mail.Attachment, 0, len(msg.Attachments))
+	for _, part := range msg.Attachments {
+		data, err := part.readAll()
+		if err != nil {
+			logger.Error("Failed to read attachment", "path", part.Path, "error", err)
+			return fmt.Errorf("attachment %s: failed to read attachment: %w", part.Path, err)
+		}
+		msg.Transmission.Attachments = append(msg.Transmission.Attachments, mail.Attachment{
+			Filename: part.Filename,
+			Bytes:    data,
+		})
+	}
+	return nil
+}
+
 // validateAttachment validates an attachment file path and properties
 func (m *Mailer) validateAttachment(path string) error {
+	return ValidateAttachment(path, m.maxAttachmentMB)
+}
+
+// ValidateAttachment checks that a file exists, is readable and within the
+// attachment size limit. Folders are not accepted here, they are zipped
+// before sending and the archive is validated instead.
+func ValidateAttachment(path string, maxAttachmentMB int) error {
 	// Check file exists and get info
 	info, err := os.Stat(path)
 	if err != nil {
@@ -208,9 +730,9 @@ func (m *Mailer) validateAttachment(path string) error {
 	}
 
 	// Check size limit (configurable)
-	maxSize := int64(m.maxAttachmentMB) * 1024 * 1024
+	maxSize := int64(maxAttachmentMB) * 1024 * 1024
 	if info.Size() > maxSize {
-		return fmt.Errorf("file too large (max %dMB, got %d bytes)", m.maxAttachmentMB, info.Size())
+		return fmt.Errorf("file too large (max %dMB, got %d bytes)", maxAttachmentMB, info.Size())
 	}
 
 	// Get absolute path to prevent directory traversal
@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ainsleyclark/go-mail/mail"
+)
+
+// batchDriver records the recipients of every send and fails the given calls
+type batchDriver struct {
+	sent   [][]string
+	failOn map[int]bool
+}
+
+func (d *batchDriver) Send(t *mail.Transmission) (mail.Response, error) {
+	d.sent = append(d.sent, append(append(append([]string{}, t.Recipients...), t.CC...), t.BCC...))
+	if d.failOn[len(d.sent)] {
+		return mail.Response{StatusCode: 400}, errors.New("rejected")
+	}
+	return mail.Response{StatusCode: 200}, nil
+}
+
+func TestSendBatches(t *testing.T) {
+	driver := &batchDriver{failOn: map[int]bool{3: true}}
+	m := stubMailer("primary", nil)
+	m.driver = driver
+	m.providerConfig.FromAddress = "me@example.com"
+	m.providerConfig.MaxRecipients = 3
+
+	result, err := m.Send(EmailData{
+		To:      []string{"to@example.com"},
+		BCC:     []string{"b1@example.com", "b2@example.com", "b3@example.com", "b4@example.com", "b5@example.com"},
+		Subject: "News",
+		Body:    "Hello",
+	})
+	if err == nil {
+		t.Fatal("Send() expected an error for the failed batch")
+	}
+
+	want := [][]string{
+		{"to@example.com", "b1@example.com", "b2@example.com"},
+		{"me@example.com", "b3@example.com", "b4@example.com"},
+		{"me@example.com", "b5@example.com"},
+	}
+	if !reflect.DeepEqual(driver.sent, want) {
+		t.Errorf("sent %v, want %v", driver.sent, want)
+	}
+
+	if len(result.Batches) != 3 || result.Failed() != 1 || result.Batches[2].Error == "" {
+		t.Errorf("batches = %+v, want 3 with the last one failed", result.Batches)
+	}
+	if !reflect.DeepEqual(result.Batches[2].BCC, []string{"b5@example.com"}) {
+		t.Errorf("failed batch Bcc = %v, want [b5@example.com]", result.Batches[2].BCC)
+	}
+
+	if _, err := m.Send(EmailData{
+		To:      []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"},
+		Subject: "News",
+		Body:    "Hello",
+	}); err == nil {
+		t.Error("Send() with too many To recipients: expected error")
+	}
+}
+
+func TestSendResponse(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("X-Message-Id", "sg-123")
+	m := stubMailer("sendgrid", &stubDriver{resp: mail.Response{StatusCode: 202, Headers: headers, Message: "Successfully sent SendGrid email"}})
+
+	result, err := m.Send(EmailData{To: []string{"to@example.com"}, Subject: "Hi", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	want := Response{MessageID: "sg-123", StatusCode: 202, Body: "Successfully sent SendGrid email"}
+	if result.Response != want || result.Attempts[0].Response != want {
+		t.Errorf("Send() response = %+v, attempt %+v, want %+v", result.Response, result.Attempts[0].Response, want)
+	}
+}
+
+func TestSMTPQueueID(t *testing.T) {
+	tests := map[string]string{
+		"2.0.0 Ok: queued as 4F2B61C0A3":   "4F2B61C0A3",
+		"OK id=1rX2aB-0003xY-Lm":           "1rX2aB-0003xY-Lm",
+		"2.0.0 OK  1700000000 gsmtp":       "",
+		"Queued as <abc@mail.example>; ok": "<abc@mail.example>",
+	}
+	for reply, want := range tests {
+		if got := smtpQueueID(reply); got != want {
+			t.Errorf("smtpQueueID(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
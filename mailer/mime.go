@@ -0,0 +1,212 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// buildMIME renders a message as an RFC 5322 message for transports that
+// accept raw MIME, see writeMIME
+func buildMIME(from mail.Address, msg *message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMIME(&buf, from, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeMIME streams a message as RFC 5322 to w, reading attachments from
+// disk as they are written. BCC recipients are intentionally not written.
+// Inline images are placed next to the body in a multipart/related part.
+func writeMIME(w io.Writer, from mail.Address, msg *message) error {
+	t := msg.Transmission
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", from.String())
+	header.Set("To", strings.Join(t.Recipients, ", "))
+	if len(t.CC) > 0 {
+		header.Set("Cc", strings.Join(t.CC, ", "))
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", t.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-ID", newMessageID(from.Address))
+	header.Set("MIME-Version", "1.0")
+	for k, v := range t.Headers {
+		header.Set(k, v)
+	}
+
+	switch {
+	case msg.pgp != nil:
+		return writePGPMIME(w, header, msg)
+	case msg.smime != nil:
+		return writeSMIME(w, header, msg)
+	}
+	return writeEntity(w, header, msg)
+}
+
+// writeEntity writes the content of a message as a multipart/mixed entity,
+// preceded by the given header fields
+func writeEntity(w io.Writer, header textproto.MIMEHeader, msg *message) error {
+	t := msg.Transmission
+	mixed := multipart.NewWriter(w)
+	header.Set("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+
+	// Inline images share a multipart/related part with the body
+	body := mixed
+	var related *multipart.Writer
+	if len(msg.Inline) > 0 {
+		relatedBoundary := randomBoundary()
+		relatedHeader := textproto.MIMEHeader{}
+		relatedHeader.Set("Content-Type", `multipart/related; type="multipart/alternative"; boundary=`+relatedBoundary)
+		relatedPart, err := mixed.CreatePart(relatedHeader)
+		if err != nil {
+			return err
+		}
+		related = multipart.NewWriter(relatedPart)
+		if err := related.SetBoundary(relatedBoundary); err != nil {
+			return err
+		}
+		body = related
+	}
+
+	// Text and HTML alternatives
+	altBoundary := randomBoundary()
+	altHeader := textproto.MIMEHeader{}
+	altHeader.Set("Content-Type", "multipart/alternative; boundary="+altBoundary)
+	altPart, err := body.CreatePart(altHeader)
+	if err != nil {
+		return err
+	}
+	alt := multipart.NewWriter(altPart)
+	if err := alt.SetBoundary(altBoundary); err != nil {
+		return err
+	}
+	if t.PlainText != "" {
+		if err := writeQuotedPrintable(alt, "text/plain; charset=UTF-8", t.PlainText); err != nil {
+			return err
+		}
+	}
+	if t.HTML != "" {
+		if err := writeQuotedPrintable(alt, "text/html; charset=UTF-8", t.HTML); err != nil {
+			return err
+		}
+	}
+	if err := alt.Close(); err != nil {
+		return err
+	}
+
+	// Inline images
+	for _, img := range msg.Inline {
+		if err := writeFilePart(related, img, "inline"); err != nil {
+			return err
+		}
+	}
+	if related != nil {
+		if err := related.Close(); err != nil {
+			return err
+		}
+	}
+
+	// Attachments
+	for _, a := range msg.Attachments {
+		if err := writeFilePart(mixed, a, "attachment"); err != nil {
+			return err
+		}
+	}
+
+	return mixed.Close()
+}
+
+// writeFilePart adds a base64 encoded attachment or inline image
+func writeFilePart(w *multipart.Writer, p filePart, disposition string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", p.ContentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": p.Filename}))
+	if p.ContentID != "" {
+		header.Set("Content-ID", "<"+p.ContentID+">")
+	}
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if err := p.writeBase64(part); err != nil {
+		return fmt.Errorf("%s: %w", p.Filename, err)
+	}
+	return nil
+}
+
+// writeHeader writes header fields in a stable order followed by a blank
+// line. Values with control characters are refused, see validateHeaderValue.
+func writeHeader(w io.Writer, header textproto.MIMEHeader) error {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			if err := validateHeaderValue(k, v); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// writeQuotedPrintable adds a quoted-printable encoded text part
+func writeQuotedPrintable(w *multipart.Writer, contentType, content string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// newMessageID generates a unique Message-ID for the sender's domain
+func newMessageID(from string) string {
+	domain := "mailgloss.local"
+	if at := strings.LastIndex(from, "@"); at != -1 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
+
+// randomBoundary returns a random multipart boundary
+func randomBoundary() string {
+	return randomHex(15)
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
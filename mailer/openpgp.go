@@ -0,0 +1,209 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+)
+
+// OpenPGP keys are read, and messages signed and encrypted, with go-crypto.
+// Its v2 API is used for hidden recipients. Keys are trusted as found in the
+// configured keyring and contacts once their self-signatures verify.
+
+// PGPKey is an OpenPGP key with its user IDs and subkeys
+type PGPKey struct {
+	UserIDs []string
+	entity  *openpgp.Entity
+}
+
+// pgpRecipient is a key to encrypt to. Hidden recipients are written with
+// a wildcard key ID, so the other recipients can't tell who they are.
+type pgpRecipient struct {
+	key    *openpgp.Entity
+	hidden bool
+}
+
+// newPGPKey wraps an entity read from a keyring
+func newPGPKey(entity *openpgp.Entity) *PGPKey {
+	key := &PGPKey{entity: entity}
+	for name := range entity.Identities {
+		key.UserIDs = append(key.UserIDs, name)
+	}
+	sort.Strings(key.UserIDs)
+	return key
+}
+
+// Fingerprint returns the fingerprint of the primary key in hex
+func (k *PGPKey) Fingerprint() string {
+	return fmt.Sprintf("%X", k.entity.PrimaryKey.Fingerprint)
+}
+
+// HasAddress reports whether one of the key's user IDs has an address
+func (k *PGPKey) HasAddress(address string) bool {
+	for _, uid := range k.UserIDs {
+		addr := strings.TrimSpace(uid)
+		if parsed, err := mail.ParseAddress(uid); err == nil {
+			addr = parsed.Address
+		}
+		if strings.EqualFold(addr, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEncrypt reports whether the key has a usable encryption key
+func (k *PGPKey) CanEncrypt() bool {
+	_, ok := k.entity.EncryptionKey(time.Now(), nil)
+	return ok
+}
+
+// canSign reports whether the key has a valid, decrypted secret key able
+// to sign
+func (k *PGPKey) canSign(now time.Time) bool {
+	key, ok := k.entity.SigningKey(now, nil)
+	return ok && key.PrivateKey != nil && !key.PrivateKey.Encrypted
+}
+
+// FindPGPKey returns the first key with a user ID for an address that can
+// encrypt, or nil
+func FindPGPKey(keys []*PGPKey, address string) *PGPKey {
+	for _, key := range keys {
+		if key.HasAddress(address) && key.CanEncrypt() {
+			return key
+		}
+	}
+	return nil
+}
+
+// ReadPGPKeys reads the public keys of an armored or binary keyring. Keys
+// with unsupported algorithms are skipped.
+func ReadPGPKeys(data []byte) ([]*PGPKey, error) {
+	return readPGPKeys(data, nil)
+}
+
+// ArmorPGPKeys checks that data holds usable public keys and returns their
+// public parts ASCII armored, for storing them as text
+func ArmorPGPKeys(data []byte) (string, []*PGPKey, error) {
+	keys, err := ReadPGPKeys(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("no supported OpenPGP keys found")
+	}
+
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, key := range keys {
+		if err := key.entity.Serialize(w); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+	return b.String() + "\n", keys, nil
+}
+
+// readPGPKeys reads a keyring of one or more armored blocks, or binary.
+// Secret keys are decrypted with passphrase if it isn't nil.
+func readPGPKeys(data []byte, passphrase []byte) ([]*PGPKey, error) {
+	var entities openpgp.EntityList
+	const begin = "-----BEGIN PGP"
+	if !bytes.Contains(data, []byte(begin)) {
+		el, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		entities = el
+	}
+	for _, block := range bytes.Split(data, []byte(begin))[1:] {
+		el, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(append([]byte(begin), block...)))
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, el...)
+	}
+
+	var keys []*PGPKey
+	for _, entity := range entities {
+		if passphrase != nil {
+			if err := entity.DecryptPrivateKeys(passphrase); err != nil {
+				return nil, fmt.Errorf("key %X: %w", entity.PrimaryKey.Fingerprint, err)
+			}
+		}
+		keys = append(keys, newPGPKey(entity))
+	}
+	return keys, nil
+}
+
+// pgpConfig signs with SHA-256, as PGP/MIME declares in micalg, and
+// prefers AES-256 for encryption
+func pgpConfig(now time.Time) *packet.Config {
+	return &packet.Config{
+		DefaultHash:   crypto.SHA256,
+		DefaultCipher: packet.CipherAES256,
+		Time:          func() time.Time { return now },
+	}
+}
+
+// pgpSign returns an armored detached signature over data
+func pgpSign(key *openpgp.Entity, data []byte, now time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	err := openpgp.ArmoredDetachSign(&b, []*openpgp.Entity{key}, bytes.NewReader(data), &openpgp.SignParams{Config: pgpConfig(now)})
+	if err != nil {
+		return nil, err
+	}
+	return armorCRLF(b.Bytes()), nil
+}
+
+// pgpEncrypt returns data encrypted to the recipients as an armored message
+func pgpEncrypt(data []byte, recipients []pgpRecipient) ([]byte, error) {
+	var to, hidden []*openpgp.Entity
+	for _, r := range recipients {
+		if r.hidden {
+			hidden = append(hidden, r.key)
+		} else {
+			to = append(to, r.key)
+		}
+	}
+
+	var b bytes.Buffer
+	armored, err := armor.Encode(&b, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openpgp.Encrypt(armored, to, hidden, nil, nil, pgpConfig(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+	return armorCRLF(b.Bytes()), nil
+}
+
+// armorCRLF ends the lines of armored data with CRLF, like the rest of the
+// message, and after the last line too
+func armorCRLF(b []byte) []byte {
+	s := strings.ReplaceAll(strings.TrimRight(string(b), "\r\n"), "\n", "\r\n")
+	return []byte(s + "\r\n")
+}
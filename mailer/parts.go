@@ -0,0 +1,262 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+const (
+	// partOverhead approximates the MIME headers and boundary of a part
+	partOverhead = 256
+	// messageOverhead approximates the message headers and multipart structure
+	messageOverhead = 2048
+)
+
+// filePart is an attachment or inline image. File-backed parts are only
+// read while the message is written, so large files are never held in
+// memory more than once.
+type filePart struct {
+	Path        string
+	Filename    string
+	ContentType string
+	Size        int64
+	ContentID   string // Set for inline images
+
+	data []byte // In-memory content for parts not backed by a file
+}
+
+// message is a transmission whose attachments and inline images are kept
+// as parts. Transports that write the message themselves stream the parts.
+type message struct {
+	*gomail.Transmission
+	Attachments []filePart
+	Inline      []filePart
+	pgp         *pgpOptions   // nil to send the message unprotected
+	smime       *smimeOptions // nil to send the message unprotected
+}
+
+// messageSender is implemented by transports that write the message
+// themselves, which lets them stream attachments and send inline images
+type messageSender interface {
+	sendMessage(msg *message) (gomail.Response, error)
+}
+
+// writesMIME reports whether a sender writes messages as MIME, which
+// PGP/MIME and S/MIME need. The webhook driver renders its own format instead.
+func writesMIME(s messageSender) bool {
+	switch s.(type) {
+	case *rawTransport, *sesDriver, *sendmailDriver:
+		return true
+	}
+	return false
+}
+
+// FileInfo describes a file about to be attached
+type FileInfo struct {
+	Size        int64
+	ContentType string
+	Folder      bool // Folders are sent zipped
+	Files       int  // Number of files in a folder
+}
+
+// InspectFile returns the size and detected MIME type of a file. Folders
+// report the total size of their files before compression.
+func InspectFile(path string) (FileInfo, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		size, files, err := folderSize(path)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		return FileInfo{Size: size, ContentType: "application/zip", Folder: true, Files: files}, nil
+	}
+
+	part, err := newFilePart(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Size: part.Size, ContentType: part.ContentType}, nil
+}
+
+// EstimateMessageSize estimates the encoded size of a message with the
+// given body length and files, as returned by InspectFile, including base64
+// overhead. Folders count with their uncompressed size, an upper bound of
+// the zipped size.
+func EstimateMessageSize(bodyLength int, files []FileInfo) int64 {
+	size := int64(messageOverhead + bodyLength*2) // Plain text and HTML versions
+	for _, file := range files {
+		size += encodedSize(file.Size)
+	}
+	return size
+}
+
+// FormatSize renders a byte count for display
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// newFilePart describes a file without reading all of it
+func newFilePart(path string) (filePart, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return filePart{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return filePart{}, fmt.Errorf("not a regular file")
+	}
+
+	contentType, err := detectContentType(path)
+	if err != nil {
+		return filePart{}, err
+	}
+
+	return filePart{
+		Path:        path,
+		Filename:    filepath.Base(path),
+		ContentType: contentType,
+		Size:        info.Size(),
+	}, nil
+}
+
+// detectContentType determines a file's MIME type from its extension,
+// falling back to sniffing the first 512 bytes
+func detectContentType(path string) (string, error) {
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); contentType != "" {
+		return contentType, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// partsFromAttachments converts go-mail attachments to in-memory parts
+func partsFromAttachments(attachments []gomail.Attachment) []filePart {
+	parts := make([]filePart, 0, len(attachments))
+	for _, a := range attachments {
+		parts = append(parts, filePart{
+			Filename:    a.Filename,
+			ContentType: a.Mime(),
+			Size:        int64(len(a.Bytes)),
+			data:        a.Bytes,
+		})
+	}
+	return parts
+}
+
+// open returns a reader for the part's content
+func (p filePart) open() (io.ReadCloser, error) {
+	if p.data != nil || p.Path == "" {
+		return io.NopCloser(bytes.NewReader(p.data)), nil
+	}
+	return os.Open(p.Path)
+}
+
+// readAll reads the part's content into memory
+func (p filePart) readAll() ([]byte, error) {
+	if p.data != nil || p.Path == "" {
+		return p.data, nil
+	}
+	return os.ReadFile(p.Path)
+}
+
+// encodedSize returns the size of the part once base64 encoded
+func (p filePart) encodedSize() int64 {
+	return encodedSize(p.Size)
+}
+
+// writeBase64 streams the part's content as base64 wrapped at 76 characters
+func (p filePart) writeBase64(w io.Writer) error {
+	r, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	lw := &lineWrapper{w: w}
+	enc := base64.NewEncoder(base64.StdEncoding, lw)
+	if _, err := io.Copy(enc, r); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\r\n")
+	return err
+}
+
+// encodedSize returns the size of base64 encoded content of the given
+// length, including line breaks and part headers
+func encodedSize(size int64) int64 {
+	b64 := (size + 2) / 3 * 4
+	return b64 + (b64+75)/76*2 + partOverhead
+}
+
+// estimateSize estimates the encoded size of a message
+func (msg *message) estimateSize() int64 {
+	size := int64(messageOverhead + len(msg.PlainText) + len(msg.HTML))
+	for _, p := range msg.Attachments {
+		size += p.encodedSize()
+	}
+	for _, p := range msg.Inline {
+		size += p.encodedSize()
+	}
+	// Encrypted content is ASCII armored or base64 encoded once more
+	if (msg.pgp != nil && len(msg.pgp.recipients) > 0) || (msg.smime != nil && len(msg.smime.recipients) > 0) {
+		size = size * 11 / 8
+	}
+	return size
+}
+
+// lineWrapper inserts CRLF line breaks every 76 bytes
+type lineWrapper struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.col == 76 {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+		n := 76 - l.col
+		if n > len(p) {
+			n = len(p)
+		}
+		m, err := l.w.Write(p[:n])
+		written += m
+		l.col += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
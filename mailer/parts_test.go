@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilePartWriteBase64(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	part, err := newFilePart(path)
+	if err != nil {
+		t.Fatalf("newFilePart() error = %v", err)
+	}
+	if part.ContentType != "application/pdf" || part.Size != int64(len(content)) {
+		t.Errorf("part = %+v, want application/pdf of %d bytes", part, len(content))
+	}
+
+	var buf bytes.Buffer
+	if err := part.writeBase64(&buf); err != nil {
+		t.Fatalf("writeBase64() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	for _, line := range lines {
+		if len(line) > 76 {
+			t.Fatalf("line of %d characters, want at most 76", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(lines, ""))
+	if err != nil || !bytes.Equal(decoded, content) {
+		t.Errorf("decoded content does not match, err = %v", err)
+	}
+
+	if encoded := int64(buf.Len()); part.encodedSize() < encoded {
+		t.Errorf("encodedSize() = %d, want at least %d", part.encodedSize(), encoded)
+	}
+}
@@ -0,0 +1,228 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"mailgloss/config"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+)
+
+// pgpOptions is how a message is protected with PGP/MIME (RFC 3156)
+type pgpOptions struct {
+	signer     *openpgp.Entity // nil to not sign
+	recipients []pgpRecipient  // Empty to not encrypt
+}
+
+// LoadPGPKeyring reads the public keys of a keyring file
+func LoadPGPKeyring(path string) ([]*PGPKey, error) {
+	data, err := os.ReadFile(config.ExpandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP keyring: %w", err)
+	}
+	keys, err := ReadPGPKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP keyring %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// loadPGPSecretKey reads and decrypts the secret key of a PGP configuration
+func loadPGPSecretKey(pc *config.PGPConfig) (*PGPKey, error) {
+	data, err := os.ReadFile(config.ExpandHome(pc.SecretKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP secret key: %w", err)
+	}
+	keys, err := readPGPKeys(data, []byte(pc.Passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("PGP secret key %s: %w", pc.SecretKey, err)
+	}
+	for _, key := range keys {
+		if key.canSign(time.Now()) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("PGP secret key %s: no valid secret key able to sign", pc.SecretKey)
+}
+
+// senderPGPKey returns the sender's secret key, reading it the first time.
+// It is nil without an error if no key is configured.
+func (m *Mailer) senderPGPKey() (*PGPKey, error) {
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+	if m.pgpKey != nil {
+		return m.pgpKey, nil
+	}
+	pgp := m.providerConfig.PGPFor(m.providerConfig.FromAddress)
+	if pgp == nil {
+		return nil, nil
+	}
+	key, err := loadPGPSecretKey(pgp)
+	if err != nil {
+		return nil, err
+	}
+	m.pgpKey = key
+	return key, nil
+}
+
+// pgpOptions resolves the keys to sign and encrypt an email with. Every
+// recipient needs a public key in data.PGPKeys to encrypt, or the sender's
+// own key for batches addressed to the sender.
+func (m *Mailer) pgpOptions(data EmailData) (*pgpOptions, error) {
+	now := time.Now()
+	opts := &pgpOptions{}
+	ownKey, keyErr := m.senderPGPKey()
+	if data.PGPSign {
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		if ownKey == nil {
+			return nil, fmt.Errorf("no PGP secret key is configured for %s", m.providerConfig.FromAddress)
+		}
+		if !ownKey.canSign(now) {
+			return nil, fmt.Errorf("PGP key %s can't sign, it may have expired", ownKey.Fingerprint())
+		}
+		opts.signer = ownKey.entity
+	}
+
+	if data.PGPEncrypt {
+		keys := data.PGPKeys
+		if ownKey != nil {
+			keys = append(keys[:len(keys):len(keys)], ownKey)
+		}
+		var missing []string
+		for _, list := range []struct {
+			addresses []string
+			hidden    bool
+		}{{data.To, false}, {data.CC, false}, {data.BCC, true}} {
+			for _, rcpt := range list.addresses {
+				addr, err := mail.ParseAddress(rcpt)
+				if err != nil {
+					return nil, fmt.Errorf("invalid recipient '%s': %w", rcpt, err)
+				}
+				key := FindPGPKey(keys, addr.Address)
+				if key == nil {
+					// The sender's key failing to load is the better error
+					if keyErr != nil && strings.EqualFold(addr.Address, m.providerConfig.FromAddress) {
+						return nil, keyErr
+					}
+					missing = append(missing, addr.Address)
+					continue
+				}
+				opts.recipients = append(opts.recipients, pgpRecipient{key: key.entity, hidden: list.hidden})
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("no PGP public key for %s", strings.Join(missing, ", "))
+		}
+	}
+	return opts, nil
+}
+
+// writePGPMIME writes a message signed, encrypted or both, signing first.
+// The header fields stay readable; only the content is protected.
+func writePGPMIME(w io.Writer, header textproto.MIMEHeader, msg *message) error {
+	var entity bytes.Buffer
+	if err := writeEntity(&entity, textproto.MIMEHeader{}, msg); err != nil {
+		return err
+	}
+	content := entity.Bytes()
+
+	p := msg.pgp
+	if p.signer == nil {
+		return writePGPEncrypted(w, header, content, p.recipients)
+	}
+	if len(p.recipients) == 0 {
+		return writePGPSigned(w, header, content, p.signer)
+	}
+	var signed bytes.Buffer
+	if err := writePGPSigned(&signed, textproto.MIMEHeader{}, content, p.signer); err != nil {
+		return err
+	}
+	return writePGPEncrypted(w, header, signed.Bytes(), p.recipients)
+}
+
+// writePGPSigned writes content as the first part of a multipart/signed
+// entity, followed by a detached signature over it
+func writePGPSigned(w io.Writer, header textproto.MIMEHeader, content []byte, key *openpgp.Entity) error {
+	sig, err := pgpSign(key, content, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	// The content is written as is, since it is what the signature covers
+	boundary := randomBoundary()
+	header.Set("Content-Type", `multipart/signed; micalg=pgp-sha256; protocol="application/pgp-signature"; boundary=`+boundary)
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "--%s\r\n", boundary); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "\r\n--%s\r\n", boundary); err != nil {
+		return err
+	}
+
+	sigHeader := textproto.MIMEHeader{}
+	sigHeader.Set("Content-Type", `application/pgp-signature; name="signature.asc"`)
+	sigHeader.Set("Content-Description", "OpenPGP digital signature")
+	sigHeader.Set("Content-Disposition", `attachment; filename="signature.asc"`)
+	if err := writeHeader(w, sigHeader); err != nil {
+		return err
+	}
+	if _, err := w.Write(sig); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\r\n--%s--\r\n", boundary)
+	return err
+}
+
+// writePGPEncrypted writes content encrypted to the recipients as a
+// multipart/encrypted entity
+func writePGPEncrypted(w io.Writer, header textproto.MIMEHeader, content []byte, recipients []pgpRecipient) error {
+	encrypted, err := pgpEncrypt(content, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt message: %w", err)
+	}
+
+	mp := multipart.NewWriter(w)
+	header.Set("Content-Type", `multipart/encrypted; protocol="application/pgp-encrypted"; boundary=`+mp.Boundary())
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+
+	versionHeader := textproto.MIMEHeader{}
+	versionHeader.Set("Content-Type", "application/pgp-encrypted")
+	versionHeader.Set("Content-Description", "PGP/MIME version identification")
+	part, err := mp.CreatePart(versionHeader)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(part, "Version: 1\r\n"); err != nil {
+		return err
+	}
+
+	dataHeader := textproto.MIMEHeader{}
+	dataHeader.Set("Content-Type", `application/octet-stream; name="encrypted.asc"`)
+	dataHeader.Set("Content-Description", "OpenPGP encrypted message")
+	dataHeader.Set("Content-Disposition", `inline; filename="encrypted.asc"`)
+	part, err = mp.CreatePart(dataHeader)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(encrypted); err != nil {
+		return err
+	}
+	return mp.Close()
+}
@@ -0,0 +1,146 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Preview is a message as recipients will get it, built without sending
+type Preview struct {
+	ProviderName string
+	Headers      []HeaderField // In the order they are shown
+	PlainText    string
+	HTML         string // Inline images refer to the local files
+	Attachments  []PreviewFile
+	Size         int64 // Estimated encoded size
+}
+
+// HeaderField is a message header
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// PreviewFile is an attachment or inline image of a previewed message.
+// Folders and bundles are listed as the zip sent in their place, with the
+// size of their files before compression.
+type PreviewFile struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Files       int // Number of files in a zip
+	Inline      bool
+}
+
+// Preview builds the message the way Send would, resolving the From
+// address, headers and both body parts. A failover chain previews its
+// first provider.
+func (m *Mailer) Preview(data EmailData) (*Preview, error) {
+	if len(m.chain) > 0 {
+		return m.chain[0].Preview(data)
+	}
+
+	pc := m.providerConfig
+	preview := &Preview{ProviderName: pc.Name}
+	from := mail.Address{Name: pc.FromName, Address: pc.FromAddress}
+	preview.Headers = append(preview.Headers, HeaderField{"From", from.String()})
+	for _, h := range []HeaderField{
+		{"To", strings.Join(data.To, ", ")},
+		{"Cc", strings.Join(data.CC, ", ")},
+		{"Bcc", strings.Join(data.BCC, ", ")},
+		{"Subject", data.Subject},
+	} {
+		if h.Value != "" {
+			preview.Headers = append(preview.Headers, h)
+		}
+	}
+
+	headers, err := buildHeaders(data, pc)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		// The SparkPost Cc workaround repeats the Cc header shown above
+		if name != "cc" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		preview.Headers = append(preview.Headers, HeaderField{name, headers[name]})
+	}
+
+	plainText, htmlBody := renderBodies(data)
+	htmlBody, inline, err := embedInlineImages(htmlBody, data.InlineImages, m.inspectAttachment)
+	if err != nil {
+		return nil, err
+	}
+	// Point the cid: references back at the files so a browser shows them
+	for _, part := range inline {
+		abs, err := filepath.Abs(part.Path)
+		if err != nil {
+			continue
+		}
+		fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		htmlBody = strings.ReplaceAll(htmlBody, "cid:"+part.ContentID, fileURL)
+	}
+	preview.PlainText = plainText
+	preview.HTML = htmlBody
+	preview.Size = int64(messageOverhead + len(plainText) + len(htmlBody))
+
+	files, archives := splitFolders(data.Attachments)
+	for _, path := range files {
+		part, err := m.inspectAttachment(path)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %s: %w", path, err)
+		}
+		preview.addFile(PreviewFile{Name: part.Filename, ContentType: part.ContentType, Size: part.Size})
+	}
+	for _, archive := range append(archives, data.Archives...) {
+		file := PreviewFile{Name: archive.Name, ContentType: "application/zip"}
+		for _, path := range archive.Paths {
+			info, err := InspectFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("archive %s: %w", archive.Name, err)
+			}
+			file.Size += info.Size
+			file.Files += max(info.Files, 1)
+		}
+		preview.addFile(file)
+	}
+	for _, part := range inline {
+		preview.addFile(PreviewFile{Name: part.Filename, ContentType: part.ContentType, Size: part.Size, Inline: true})
+	}
+
+	return preview, nil
+}
+
+// addFile lists a file and adds its encoded size to the message size
+func (p *Preview) addFile(file PreviewFile) {
+	p.Attachments = append(p.Attachments, file)
+	p.Size += encodedSize(file.Size)
+}
+
+// WriteHTMLFile writes the HTML part to a temporary file for viewing in a
+// browser and returns its path. The caller removes the file.
+func (p *Preview) WriteHTMLFile() (string, error) {
+	f, err := os.CreateTemp("", "mailgloss-preview-*.html")
+	if err != nil {
+		return "", fmt.Errorf("failed to create preview file: %w", err)
+	}
+	_, err = f.WriteString(p.HTML)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write preview file: %w", err)
+	}
+	return f.Name(), nil
+}
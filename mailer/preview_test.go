@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	dir := t.TempDir()
+	logo := filepath.Join(dir, "logo.png")
+	report := filepath.Join(dir, "report.txt")
+	for path, content := range map[string]string{logo: "png", report: "numbers"} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := stubMailer("primary", &stubDriver{})
+	m.providerConfig.FromName = "Ops"
+	m.providerConfig.FromAddress = "ops@example.com"
+
+	preview, err := m.Preview(EmailData{
+		To:          []string{"a@example.com", "b@example.com"},
+		Subject:     "Report",
+		Body:        `<html><body><p>See <img src="` + logo + `"></p></body></html>`,
+		ReplyTo:     "reply@example.com",
+		Attachments: []string{report},
+		Archives:    []Archive{{Name: "attachments.zip", Paths: []string{report}}},
+	})
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+
+	var headers []string
+	for _, h := range preview.Headers {
+		headers = append(headers, h.Name+": "+h.Value)
+	}
+	want := []string{
+		`From: "Ops" <ops@example.com>`,
+		"To: a@example.com, b@example.com",
+		"Subject: Report",
+		"Reply-To: reply@example.com",
+	}
+	if strings.Join(headers, "\n") != strings.Join(want, "\n") {
+		t.Errorf("headers =\n%s\nwant\n%s", strings.Join(headers, "\n"), strings.Join(want, "\n"))
+	}
+
+	if !strings.Contains(preview.HTML, `src="file://`+filepath.ToSlash(logo)+`"`) {
+		t.Errorf("HTML = %s, want the inline image to refer to its file", preview.HTML)
+	}
+	if preview.PlainText != "See" {
+		t.Errorf("PlainText = %q, want %q", preview.PlainText, "See")
+	}
+
+	if len(preview.Attachments) != 3 {
+		t.Fatalf("attachments = %+v, want report, zip and inline logo", preview.Attachments)
+	}
+	zip := preview.Attachments[1]
+	if zip.Name != "attachments.zip" || zip.Files != 1 || zip.Size != 7 {
+		t.Errorf("zip = %+v, want attachments.zip with 1 file of 7 bytes", zip)
+	}
+	if !preview.Attachments[2].Inline {
+		t.Errorf("logo = %+v, want inline", preview.Attachments[2])
+	}
+}
@@ -0,0 +1,449 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mailgloss/config"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+// rawTimeout is the amount of time to wait for a raw MIME submission
+const rawTimeout = 30 * time.Second
+
+// smtpQueueIDPattern matches the queue ID in common SMTP replies to DATA
+var smtpQueueIDPattern = regexp.MustCompile(`(?i)(?:queued as|id=)\s*([^\s;,()]+)`)
+
+// rawTransport submits complete MIME messages for providers whose go-mail
+// driver can't express everything a message contains, such as inline images.
+// SMTP and Mailgun submissions stream attachments from disk.
+type rawTransport struct {
+	pc     *config.ProviderConfig
+	client *http.Client
+	dkim   *dkimSigner // nil if SMTP messages aren't signed
+
+	// SMTP connections are made with dial and verified against rootCAs,
+	// which are the system's when nil; tests replace both
+	dial    func(network, addr string) (net.Conn, error)
+	rootCAs *x509.CertPool
+}
+
+// newRawTransport returns a raw MIME transport for the provider, or nil if
+// the provider has no way to accept raw MIME. SMTP messages are DKIM signed
+// when the sender has a key configured.
+func newRawTransport(pc *config.ProviderConfig) (messageSender, error) {
+	switch pc.Type {
+	case config.ProviderMailgun, config.ProviderSparkPost, config.ProviderPostal, config.ProviderSMTP:
+		r := &rawTransport{pc: pc, client: &http.Client{Timeout: rawTimeout}}
+		if dc := pc.DKIMFor(pc.FromAddress); dc != nil && pc.Type == config.ProviderSMTP {
+			signer, err := newDKIMSigner(dc, pc.FromAddress)
+			if err != nil {
+				return nil, fmt.Errorf("dkim: %w", err)
+			}
+			r.dkim = signer
+		}
+		return r, nil
+	}
+	return nil, nil
+}
+
+// signsDKIM reports whether a transport is a raw transport that DKIM signs
+// the messages it sends
+func signsDKIM(s messageSender) bool {
+	r, ok := s.(*rawTransport)
+	return ok && r.dkim != nil
+}
+
+// implicitTLS reports whether a provider is an SMTP server on port 465,
+// which expects TLS from the start of the connection
+func implicitTLS(pc *config.ProviderConfig) bool {
+	return pc.Type == config.ProviderSMTP && pc.SMTP.Port == 465
+}
+
+// sendMessage writes the MIME message and submits it to the provider
+func (r *rawTransport) sendMessage(msg *message) (gomail.Response, error) {
+	t := msg.Transmission
+	if err := t.Validate(); err != nil {
+		return gomail.Response{}, err
+	}
+
+	recipients := make([]string, 0, len(t.Recipients)+len(t.CC)+len(t.BCC))
+	for _, list := range [][]string{t.Recipients, t.CC, t.BCC} {
+		for _, r := range list {
+			addr, err := mail.ParseAddress(r)
+			if err != nil {
+				return gomail.Response{}, fmt.Errorf("invalid recipient '%s': %w", r, err)
+			}
+			recipients = append(recipients, addr.Address)
+		}
+	}
+
+	// SMTP and Mailgun stream the message, the JSON APIs need it in memory
+	writeTo := func(w io.Writer) error {
+		return writeMIME(w, r.from(), msg)
+	}
+	if r.dkim != nil {
+		// The body hash goes in a header, so signed messages are built in memory
+		raw, err := buildMIME(r.from(), msg)
+		if err != nil {
+			return gomail.Response{}, fmt.Errorf("failed to build message: %w", err)
+		}
+		signed, err := r.dkim.sign(raw)
+		if err != nil {
+			return gomail.Response{}, fmt.Errorf("dkim: %w", err)
+		}
+		writeTo = func(w io.Writer) error {
+			_, err := w.Write(signed)
+			return err
+		}
+	}
+	switch r.pc.Type {
+	case config.ProviderMailgun:
+		return r.sendMailgun(writeTo, recipients)
+	case config.ProviderSMTP:
+		return r.sendSMTP(writeTo, recipients)
+	}
+
+	raw, err := buildMIME(r.from(), msg)
+	if err != nil {
+		return gomail.Response{}, fmt.Errorf("failed to build message: %w", err)
+	}
+	switch r.pc.Type {
+	case config.ProviderSparkPost:
+		return r.sendSparkPost(raw, recipients, strings.Join(t.Recipients, ", "))
+	case config.ProviderPostal:
+		return r.sendPostal(raw, recipients)
+	}
+	return gomail.Response{}, fmt.Errorf("%s provider does not accept raw MIME messages", r.pc.Type)
+}
+
+// from returns the sender of the provider
+func (r *rawTransport) from() mail.Address {
+	return mail.Address{Name: r.pc.FromName, Address: r.pc.FromAddress}
+}
+
+// sendMailgun streams the message to the messages.mime endpoint
+func (r *rawTransport) sendMailgun(writeTo func(io.Writer) error, recipients []string) (gomail.Response, error) {
+	mc := r.pc.Mailgun
+	baseURL := mc.URL
+	if baseURL == "" {
+		baseURL = "https://api.mailgun.net"
+	}
+
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(func() error {
+			for _, rcpt := range recipients {
+				if err := form.WriteField("to", rcpt); err != nil {
+					return err
+				}
+			}
+			part, err := form.CreateFormFile("message", "message.mime")
+			if err != nil {
+				return err
+			}
+			if err := writeTo(part); err != nil {
+				return err
+			}
+			return form.Close()
+		}())
+	}()
+
+	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/v3/"+mc.Domain+"/messages.mime", pr)
+	if err != nil {
+		pr.Close()
+		return gomail.Response{}, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetBasicAuth("api", mc.APIKey)
+
+	var result struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	}
+	response, err := r.do(req, &result)
+	if err != nil {
+		return response, err
+	}
+	response.ID = result.ID
+	response.Message = result.Message
+	return response, nil
+}
+
+// sendSparkPost creates a transmission from RFC 822 content
+func (r *rawTransport) sendSparkPost(raw []byte, recipients []string, headerTo string) (gomail.Response, error) {
+	sc := r.pc.SparkPost
+	baseURL := sc.URL
+	if baseURL == "" {
+		baseURL = "https://api.sparkpost.com"
+	}
+
+	type address struct {
+		Email    string `json:"email"`
+		HeaderTo string `json:"header_to"`
+	}
+	type recipient struct {
+		Address address `json:"address"`
+	}
+	payload := struct {
+		Recipients []recipient `json:"recipients"`
+		Content    struct {
+			EmailRFC822 string `json:"email_rfc822"`
+		} `json:"content"`
+	}{}
+	for _, rcpt := range recipients {
+		payload.Recipients = append(payload.Recipients, recipient{Address: address{Email: rcpt, HeaderTo: headerTo}})
+	}
+	payload.Content.EmailRFC822 = string(raw)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/api/v1/transmissions", bytes.NewReader(body))
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", sc.APIKey)
+
+	var result struct {
+		Results struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	response, err := r.do(req, &result)
+	if err != nil {
+		return response, err
+	}
+	response.ID = result.Results.ID
+	return response, nil
+}
+
+// sendPostal submits the message to the send/raw endpoint
+func (r *rawTransport) sendPostal(raw []byte, recipients []string) (gomail.Response, error) {
+	pc := r.pc.Postal
+	body, err := json.Marshal(map[string]interface{}{
+		"mail_from": r.pc.FromAddress,
+		"rcpt_to":   recipients,
+		"data":      base64.StdEncoding.EncodeToString(raw),
+	})
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(pc.URL, "/")+"/api/v1/send/raw", bytes.NewReader(body))
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Server-API-Key", pc.APIKey)
+
+	var result struct {
+		Status string `json:"status"`
+		Data   struct {
+			MessageID string `json:"message_id"`
+			Message   string `json:"message"`
+		} `json:"data"`
+	}
+	response, err := r.do(req, &result)
+	if err != nil {
+		return response, err
+	}
+	// Postal reports errors with a 200 status
+	if result.Status != "success" {
+		return response, fmt.Errorf("postal returned %s: %s", result.Status, result.Data.Message)
+	}
+	response.ID = result.Data.MessageID
+	return response, nil
+}
+
+// sendSMTP delivers the message over SMTP, using implicit TLS on port 465
+// and STARTTLS elsewhere when the server offers it. Servers without
+// STARTTLS are refused if the provider requires TLS.
+func (r *rawTransport) sendSMTP(writeTo func(io.Writer) error, recipients []string) (gomail.Response, error) {
+	sc := r.pc.SMTP
+	addr := net.JoinHostPort(sc.Host, strconv.Itoa(sc.Port))
+	tlsConfig := &tls.Config{ServerName: sc.Host, RootCAs: r.rootCAs}
+
+	dial := r.dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: rawTimeout}).Dial
+	}
+	conn, err := dial("tcp", addr)
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	conn.SetDeadline(time.Now().Add(rawTimeout))
+	if implicitTLS(r.pc) {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, sc.Host)
+	if err != nil {
+		conn.Close()
+		return gomail.Response{}, err
+	}
+	defer client.Close()
+
+	if !implicitTLS(r.pc) {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return gomail.Response{}, err
+			}
+		} else if sc.RequireTLS {
+			return gomail.Response{}, fmt.Errorf("%s does not offer STARTTLS, refusing to send unencrypted (require_tls)", sc.Host)
+		}
+	}
+	if ok, mechanisms := client.Extension("AUTH"); ok && sc.Username != "" {
+		auth, err := smtpAuth(sc, mechanisms)
+		if err != nil {
+			return gomail.Response{}, err
+		}
+		if err := client.Auth(auth); err != nil {
+			return gomail.Response{}, err
+		}
+	}
+
+	if err := client.Mail(r.pc.FromAddress); err != nil {
+		return gomail.Response{}, err
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return gomail.Response{}, err
+		}
+	}
+
+	// DATA is sent by hand because Client.Data drops the server's final
+	// reply, which usually carries its queue ID
+	id, err := client.Text.Cmd("DATA")
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	client.Text.StartResponse(id)
+	_, _, err = client.Text.ReadResponse(354)
+	client.Text.EndResponse(id)
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	w := client.Text.DotWriter()
+	if err := writeTo(w); err != nil {
+		return gomail.Response{}, err
+	}
+	if err := w.Close(); err != nil {
+		return gomail.Response{}, err
+	}
+	code, reply, err := client.Text.ReadResponse(250)
+	if err != nil {
+		return gomail.Response{}, err
+	}
+	client.Quit()
+
+	return gomail.Response{StatusCode: code, Message: reply, ID: smtpQueueID(reply)}, nil
+}
+
+// smtpAuth picks the first mechanism the server offers of PLAIN, LOGIN and
+// CRAM-MD5. PLAIN and LOGIN send the password, so like net/smtp they are
+// only used over TLS or with a server on the local machine.
+func smtpAuth(sc *config.SMTPConfig, mechanisms string) (smtp.Auth, error) {
+	offered := strings.Fields(strings.ToUpper(mechanisms))
+	switch {
+	case slices.Contains(offered, "PLAIN"):
+		return smtp.PlainAuth("", sc.Username, sc.Password, sc.Host), nil
+	case slices.Contains(offered, "LOGIN"):
+		return &loginAuth{username: sc.Username, password: sc.Password, host: sc.Host}, nil
+	case slices.Contains(offered, "CRAM-MD5"):
+		return smtp.CRAMMD5Auth(sc.Username, sc.Password), nil
+	}
+	return nil, fmt.Errorf("%s offers no supported authentication mechanism (%s)", sc.Host, mechanisms)
+}
+
+// loginAuth implements the LOGIN mechanism, which many servers offer
+// instead of PLAIN
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalHost(server.Name) {
+		return "", nil, fmt.Errorf("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, fmt.Errorf("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(challenge []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(string(challenge), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", challenge)
+}
+
+// isLocalHost reports whether a host name refers to the local machine
+func isLocalHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// smtpQueueID extracts the queue ID from a server's reply to DATA, such as
+// Postfix's "Ok: queued as 4F2B61C0A3" or Exim's "OK id=1rX2aB-0003xY-Lm"
+func smtpQueueID(reply string) string {
+	if m := smtpQueueIDPattern.FindStringSubmatch(reply); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// do performs an API request and decodes a successful JSON response
+func (r *rawTransport) do(req *http.Request, result interface{}) (gomail.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return gomail.Response{}, fmt.Errorf("%s request failed: %w", r.pc.Type, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return gomail.Response{StatusCode: resp.StatusCode}, fmt.Errorf("failed to read %s response: %w", r.pc.Type, err)
+	}
+
+	response := gomail.Response{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		Headers:    resp.Header,
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("%s returned %s: %s", r.pc.Type, resp.Status, bytes.TrimSpace(body))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return response, fmt.Errorf("failed to decode %s response: %w", r.pc.Type, err)
+	}
+	return response, nil
+}
@@ -0,0 +1,185 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mailgloss/config"
+)
+
+// fakeSMTP is a minimal SMTP server for one connection that records the
+// commands and message it receives
+type fakeSMTP struct {
+	ln       net.Listener
+	tls      *tls.Config // nil to not offer STARTTLS
+	auth     string      // Offered AUTH mechanism
+	login    []string    // Username and password received with LOGIN
+	commands []string
+	data     string
+	tlsUsed  bool
+	done     chan struct{}
+}
+
+func startFakeSMTP(t *testing.T, tlsConfig *tls.Config, auth string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{ln: ln, tls: tlsConfig, auth: auth, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 mail.example.com ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		s.commands = append(s.commands, line)
+		switch verb, _, _ := strings.Cut(strings.ToUpper(line), " "); verb {
+		case "EHLO":
+			tp.PrintfLine("250-mail.example.com")
+			if s.tls != nil && !s.tlsUsed {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH %s", s.auth)
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			conn = tls.Server(conn, s.tls)
+			tp = textproto.NewConn(conn)
+			s.tlsUsed = true
+		case "AUTH":
+			if strings.HasPrefix(strings.ToUpper(line), "AUTH LOGIN") {
+				for _, prompt := range []string{"VXNlcm5hbWU6", "UGFzc3dvcmQ6"} { // Username:, Password:
+					tp.PrintfLine("334 %s", prompt)
+					answer, _ := tp.ReadLine()
+					decoded, _ := base64.StdEncoding.DecodeString(answer)
+					s.login = append(s.login, string(decoded))
+				}
+			}
+			tp.PrintfLine("235 Authenticated")
+		case "MAIL", "RCPT":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 2.0.0 Ok: queued as 4F2B61C0A3")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// sent reports whether the server received a MAIL command
+func (s *fakeSMTP) sent() bool {
+	for _, cmd := range s.commands {
+		if strings.HasPrefix(cmd, "MAIL") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSendSMTP(t *testing.T) {
+	// The test certificate of httptest is valid for example.com
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	defer https.Close()
+	serverTLS := &tls.Config{Certificates: https.TLS.Certificates}
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+
+	message := "Subject: Hi\r\n\r\nHello\r\n"
+	writeTo := func(w io.Writer) error {
+		_, err := io.WriteString(w, message)
+		return err
+	}
+
+	tests := []struct {
+		name       string
+		host       string
+		tls        *tls.Config
+		auth       string
+		username   string
+		requireTLS bool
+		wantErr    bool
+		wantTLS    bool
+		wantLogin  []string
+	}{
+		{name: "starttls", host: "example.com", tls: serverTLS, auth: "PLAIN LOGIN", username: "me", wantTLS: true},
+		{name: "starttls with login", host: "example.com", tls: serverTLS, auth: "LOGIN", username: "me", wantTLS: true, wantLogin: []string{"me", "secret"}},
+		{name: "no starttls but required", host: "example.com", requireTLS: true, wantErr: true},
+		{name: "no starttls", host: "example.com", auth: "PLAIN"},
+		{name: "no starttls with password", host: "example.com", auth: "PLAIN", username: "me", wantErr: true},
+		{name: "no starttls with login", host: "example.com", auth: "LOGIN", username: "me", wantErr: true},
+		{name: "no starttls on localhost", host: "localhost", auth: "LOGIN", username: "me", wantLogin: []string{"me", "secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startFakeSMTP(t, tt.tls, tt.auth)
+			r := &rawTransport{
+				pc: &config.ProviderConfig{
+					Type:        config.ProviderSMTP,
+					FromAddress: "me@example.com",
+					SMTP:        &config.SMTPConfig{Host: tt.host, Port: 587, Username: tt.username, Password: "secret", RequireTLS: tt.requireTLS},
+				},
+				dial: func(network, addr string) (net.Conn, error) {
+					return net.Dial(network, server.ln.Addr().String())
+				},
+				rootCAs: roots,
+			}
+
+			resp, err := r.sendSMTP(writeTo, []string{"to@example.com"})
+			<-server.done
+			if tt.wantErr {
+				if err == nil || server.sent() {
+					t.Errorf("sendSMTP() error = %v, sent = %v, want refused", err, server.sent())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sendSMTP() error = %v", err)
+			}
+			if server.tlsUsed != tt.wantTLS {
+				t.Errorf("TLS used = %v, want %v", server.tlsUsed, tt.wantTLS)
+			}
+			if !reflect.DeepEqual(server.login, tt.wantLogin) {
+				t.Errorf("LOGIN received %q, want %q", server.login, tt.wantLogin)
+			}
+			if resp.ID != "4F2B61C0A3" || resp.StatusCode != 250 {
+				t.Errorf("sendSMTP() response = %+v", resp)
+			}
+			if server.data != strings.ReplaceAll(message, "\r\n", "\n") {
+				t.Errorf("server received %q", server.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"mailgloss/config"
+)
+
+// replyToTransport moves the Reply-To header out of the requests made by the
+// SendGrid, Postmark and SparkPost drivers into the reply-to field of each
+// API. The drivers send Reply-To with the custom headers, which these APIs
+// reject or ignore, and have no way to set the field.
+type replyToTransport struct {
+	provider config.Provider
+	next     http.RoundTripper
+}
+
+// newReplyToClient returns the HTTP client for a provider's go-mail driver,
+// or nil for go-mail's default client if the driver handles Reply-To
+func newReplyToClient(provider config.Provider) *http.Client {
+	switch provider {
+	case config.ProviderSendGrid, config.ProviderPostmark, config.ProviderSparkPost:
+		return &http.Client{
+			Timeout:   10 * time.Second, // go-mail's default
+			Transport: &replyToTransport{provider: provider, next: http.DefaultTransport},
+		}
+	}
+	return nil
+}
+
+func (t *replyToTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		return t.next.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var payload map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err == nil && moveReplyTo(t.provider, payload) {
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+// moveReplyTo moves the Reply-To header of a request payload to the API's
+// reply-to field, reporting whether the payload changed
+func moveReplyTo(provider config.Provider, payload map[string]interface{}) bool {
+	switch provider {
+	case config.ProviderSendGrid:
+		headers, _ := payload["headers"].(map[string]interface{})
+		value, ok := headers["Reply-To"].(string)
+		if !ok {
+			return false
+		}
+		addr, err := mail.ParseAddress(value)
+		if err != nil {
+			return false
+		}
+		delete(headers, "Reply-To")
+		if len(headers) == 0 {
+			delete(payload, "headers")
+		}
+		replyTo := map[string]interface{}{"email": addr.Address}
+		if addr.Name != "" {
+			replyTo["name"] = addr.Name
+		}
+		payload["reply_to"] = replyTo
+		return true
+
+	case config.ProviderPostmark:
+		headers, _ := payload["headers"].([]interface{})
+		for i, h := range headers {
+			if field, _ := h.(map[string]interface{}); field["Name"] == "Reply-To" {
+				payload["ReplyTo"] = field["Value"]
+				payload["headers"] = append(headers[:i:i], headers[i+1:]...)
+				return true
+			}
+		}
+
+	case config.ProviderSparkPost:
+		content, _ := payload["content"].(map[string]interface{})
+		headers, _ := content["headers"].(map[string]interface{})
+		if value, ok := headers["Reply-To"]; ok {
+			delete(headers, "Reply-To")
+			content["reply_to"] = value
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package mailer
+
+import (
+	"sync"
+	"time"
+
+	"mailgloss/config"
+)
+
+// rateWindows pairs each rate limit with the period it counts over
+var rateWindows = []struct {
+	period time.Duration
+	limit  func(config.RateLimit) int
+}{
+	{time.Second, func(r config.RateLimit) int { return r.PerSecond }},
+	{time.Minute, func(r config.RateLimit) int { return r.PerMinute }},
+	{time.Hour, func(r config.RateLimit) int { return r.PerHour }},
+}
+
+// Scheduler spaces out deliveries so each provider's rate limits hold. Sends
+// to a provider start in the order they arrive. Mailers share
+// DefaultScheduler, so the limits apply across every send path.
+type Scheduler struct {
+	mu        sync.Mutex
+	providers map[string]*providerQueue
+}
+
+// DefaultScheduler is the scheduler of mailers created with New or
+// NewWithLimits
+var DefaultScheduler = NewScheduler()
+
+// providerQueue tracks the deliveries of one provider
+type providerQueue struct {
+	limit   config.RateLimit
+	started []time.Time // Start times within the longest window, oldest first
+	active  int
+	waiting []*waiter     // In arrival order
+	changed chan struct{} // Closed when a slot may have opened up
+}
+
+// waiter is a delivery waiting for a slot
+type waiter struct {
+	queueID int
+}
+
+// QueueStatus describes a delivery waiting for its turn
+type QueueStatus struct {
+	ProviderName string
+	Position     int           // 1 for the next delivery to start
+	ETA          time.Duration // Estimated wait until it starts
+}
+
+// NewScheduler creates a scheduler without any deliveries
+func NewScheduler() *Scheduler {
+	return &Scheduler{providers: map[string]*providerQueue{}}
+}
+
+// acquire blocks until the provider's limits allow another delivery and
+// returns a function to call once it is done. Providers without limits
+// don't wait. The queue ID identifies the delivery for Status.
+func (s *Scheduler) acquire(pc *config.ProviderConfig, queueID int) func() {
+	if s == nil || pc.RateLimit.IsZero() {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	q := s.providers[pc.Name]
+	if q == nil {
+		q = &providerQueue{changed: make(chan struct{})}
+		s.providers[pc.Name] = q
+	}
+	q.limit = *pc.RateLimit
+	w := &waiter{queueID: queueID}
+	q.waiting = append(q.waiting, w)
+
+	for {
+		now := time.Now()
+		q.prune(now)
+		var wait time.Duration
+		if q.waiting[0] == w && (q.limit.MaxConcurrent == 0 || q.active < q.limit.MaxConcurrent) {
+			wait = q.nextStart(q.started, now).Sub(now)
+			if wait <= 0 {
+				q.waiting = q.waiting[1:]
+				q.started = append(q.started, now)
+				q.active++
+				q.notify()
+				s.mu.Unlock()
+				return func() {
+					s.mu.Lock()
+					q.active--
+					q.notify()
+					s.mu.Unlock()
+				}
+			}
+		}
+
+		changed := q.changed
+		s.mu.Unlock()
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-changed:
+				timer.Stop()
+			}
+		} else {
+			<-changed
+		}
+		s.mu.Lock()
+	}
+}
+
+// Status returns the queue position and estimated wait of a delivery, or
+// false if it isn't waiting
+func (s *Scheduler) Status(queueID int) (QueueStatus, bool) {
+	if queueID == 0 {
+		return QueueStatus{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for name, q := range s.providers {
+		for i, w := range q.waiting {
+			if w.queueID != queueID {
+				continue
+			}
+			// Estimate the start of each delivery ahead from the limits.
+			// Deliveries held up by max_concurrent can take longer.
+			started := append([]time.Time(nil), q.started...)
+			var start time.Time
+			for range i + 1 {
+				start = q.nextStart(started, now)
+				started = append(started, start)
+			}
+			return QueueStatus{ProviderName: name, Position: i + 1, ETA: max(start.Sub(now), 0)}, true
+		}
+	}
+	return QueueStatus{}, false
+}
+
+// nextStart returns the earliest time at or after now that another delivery
+// can start without exceeding a limit, given the start times so far
+func (q *providerQueue) nextStart(started []time.Time, now time.Time) time.Time {
+	next := now
+	for moved := true; moved; {
+		moved = false
+		for _, w := range rateWindows {
+			limit := w.limit(q.limit)
+			if limit == 0 || len(started) < limit {
+				continue
+			}
+			// The window is full until the limit-th most recent start leaves it
+			if free := started[len(started)-limit].Add(w.period); free.After(next) {
+				next = free
+				moved = true
+			}
+		}
+	}
+	return next
+}
+
+// prune forgets start times older than the longest limited window
+func (q *providerQueue) prune(now time.Time) {
+	var longest time.Duration
+	for _, w := range rateWindows {
+		if w.limit(q.limit) > 0 {
+			longest = w.period
+		}
+	}
+	i := 0
+	for i < len(q.started) && now.Sub(q.started[i]) >= longest {
+		i++
+	}
+	q.started = q.started[i:]
+}
+
+// notify wakes the deliveries waiting for a slot
+func (q *providerQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"testing"
+	"time"
+
+	"mailgloss/config"
+)
+
+func TestNextStart(t *testing.T) {
+	now := time.Now()
+	q := &providerQueue{limit: config.RateLimit{PerSecond: 5, PerMinute: 2}}
+	started := []time.Time{now.Add(-50 * time.Second), now.Add(-10 * time.Second)}
+
+	if got, want := q.nextStart(started, now), now.Add(10*time.Second); !got.Equal(want) {
+		t.Errorf("nextStart() = %v after now, want %v", got.Sub(now), want.Sub(now))
+	}
+	if got := q.nextStart(started[1:], now); !got.Equal(now) {
+		t.Errorf("nextStart() with room = %v after now, want now", got.Sub(now))
+	}
+}
+
+func TestSchedulerMaxConcurrent(t *testing.T) {
+	s := NewScheduler()
+	pc := &config.ProviderConfig{Name: "smtp", RateLimit: &config.RateLimit{MaxConcurrent: 1}}
+
+	release := s.acquire(pc, 1)
+	acquired := make(chan struct{})
+	go func() {
+		s.acquire(pc, 2)()
+		close(acquired)
+	}()
+
+	// Wait for the second delivery to queue up behind the first
+	deadline := time.Now().Add(time.Second)
+	for {
+		status, ok := s.Status(2)
+		if ok {
+			if status.Position != 1 || status.ProviderName != "smtp" {
+				t.Fatalf("Status() = %+v, want position 1 for smtp", status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second delivery never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-acquired:
+		t.Fatal("second delivery started while the first was active")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second delivery did not start after release")
+	}
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"os/exec"
+	"strings"
+	"time"
+
+	"mailgloss/config"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+// sendmailTimeout is the amount of time to wait for sendmail to accept a
+// message
+const sendmailTimeout = 60 * time.Second
+
+// sendmailDriver pipes complete MIME messages to a local sendmail program,
+// which queues them for delivery
+type sendmailDriver struct {
+	path string
+	from mail.Address
+	dkim *dkimSigner // nil if messages aren't signed
+}
+
+// newSendmail creates a new sendmail driver from a provider configuration
+func newSendmail(pc *config.ProviderConfig) (gomail.Mailer, error) {
+	d := &sendmailDriver{
+		path: pc.Sendmail.GetPath(),
+		from: mail.Address{Name: pc.FromName, Address: pc.FromAddress},
+	}
+	if dc := pc.DKIMFor(pc.FromAddress); dc != nil {
+		signer, err := newDKIMSigner(dc, pc.FromAddress)
+		if err != nil {
+			return nil, fmt.Errorf("dkim: %w", err)
+		}
+		d.dkim = signer
+	}
+	return d, nil
+}
+
+// Send builds a MIME message and pipes it to sendmail
+func (d *sendmailDriver) Send(t *gomail.Transmission) (gomail.Response, error) {
+	return d.sendMessage(&message{Transmission: t, Attachments: partsFromAttachments(t.Attachments)})
+}
+
+// sendMessage builds a MIME message, signs it if configured and pipes it to
+// sendmail with the recipients as arguments
+func (d *sendmailDriver) sendMessage(msg *message) (gomail.Response, error) {
+	t := msg.Transmission
+	if err := t.Validate(); err != nil {
+		return gomail.Response{}, err
+	}
+
+	// -i keeps lines of a single dot, -f sets the envelope sender
+	args := []string{"-i", "-f", d.from.Address, "--"}
+	for _, list := range [][]string{t.Recipients, t.CC, t.BCC} {
+		for _, r := range list {
+			addr, err := mail.ParseAddress(r)
+			if err != nil {
+				return gomail.Response{}, fmt.Errorf("invalid recipient '%s': %w", r, err)
+			}
+			args = append(args, addr.Address)
+		}
+	}
+
+	raw, err := buildMIME(d.from, msg)
+	if err != nil {
+		return gomail.Response{}, fmt.Errorf("failed to build message: %w", err)
+	}
+	if d.dkim != nil {
+		if raw, err = d.dkim.sign(raw); err != nil {
+			return gomail.Response{}, fmt.Errorf("dkim: %w", err)
+		}
+	}
+	// sendmail reads local text with newline endings and restores CRLF when
+	// relaying, which leaves the DKIM signature intact
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendmailTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, d.path, args...)
+	cmd.Stdin = bytes.NewReader(raw)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return gomail.Response{}, fmt.Errorf("sendmail: %w: %s", err, detail)
+		}
+		return gomail.Response{}, fmt.Errorf("sendmail: %w", err)
+	}
+
+	return gomail.Response{Message: "Email sent successfully"}, nil
+}
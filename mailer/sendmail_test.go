@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mailgloss/config"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+func TestSendmailSend(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "sendmail")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > \""+dir+"/args\"\ncat > \""+dir+"/message\"\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "dkim.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, err := newSendmail(&config.ProviderConfig{
+		FromAddress: "sender@example.com",
+		Sendmail:    &config.SendmailConfig{Path: script},
+		DKIM:        &config.DKIMConfig{Selector: "mail", PrivateKey: keyPath},
+	})
+	if err != nil {
+		t.Fatalf("newSendmail() error = %v", err)
+	}
+	if _, err := driver.Send(&gomail.Transmission{
+		Recipients: []string{"Ada <to@example.com>"},
+		BCC:        []string{"hidden@example.com"},
+		Subject:    "Hello",
+		HTML:       "<p>Hi</p>",
+		PlainText:  "Hi\n.\nBye",
+	}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if got, want := strings.TrimSpace(string(args)), "-i -f sender@example.com -- to@example.com hidden@example.com"; got != want {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+
+	raw, _ := os.ReadFile(filepath.Join(dir, "message"))
+	if strings.Contains(string(raw), "\r\n") {
+		t.Error("message has CRLF line endings")
+	}
+	if strings.Contains(string(raw), "hidden@example.com") {
+		t.Errorf("message leaks bcc recipient:\n%s", raw)
+	}
+	// The relaying server restores CRLF, after which the signature verifies
+	msg := strings.ReplaceAll(string(raw), "\n", "\r\n")
+	tags, err := checkDKIMSignature(msg, func(digest, sig []byte) error {
+		if !ed25519.Verify(pub, digest, sig) {
+			return fmt.Errorf("ed25519 signature does not verify")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if tags["d"] != "example.com" || tags["s"] != "mail" {
+		t.Errorf("tags = %v", tags)
+	}
+
+	// Errors include what sendmail printed
+	failing := filepath.Join(dir, "failing")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\necho 'recipient rejected' >&2\nexit 67\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	driver.(*sendmailDriver).path = failing
+	_, err = driver.Send(&gomail.Transmission{Recipients: []string{"to@example.com"}, Subject: "Hello", HTML: "<p>Hi</p>"})
+	if err == nil || !strings.Contains(err.Error(), "recipient rejected") {
+		t.Errorf("Send() error = %v, want sendmail's message", err)
+	}
+}
@@ -0,0 +1,262 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mailgloss/config"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+// sesTimeout is the amount of time to wait for SES to respond
+const sesTimeout = 30 * time.Second
+
+// sesDriver sends mail through the Amazon SES v2 API using raw MIME content
+type sesDriver struct {
+	cfg    *config.SESConfig
+	creds  awsCredentials
+	from   mail.Address
+	client *http.Client
+}
+
+// sesSendRequest is the body of the SES v2 SendEmail call
+type sesSendRequest struct {
+	FromEmailAddress     string         `json:"FromEmailAddress"`
+	Destination          sesDestination `json:"Destination"`
+	Content              sesContent     `json:"Content"`
+	ConfigurationSetName string         `json:"ConfigurationSetName,omitempty"`
+}
+
+type sesDestination struct {
+	ToAddresses  []string `json:"ToAddresses,omitempty"`
+	CcAddresses  []string `json:"CcAddresses,omitempty"`
+	BccAddresses []string `json:"BccAddresses,omitempty"`
+}
+
+type sesContent struct {
+	Raw sesRawMessage `json:"Raw"`
+}
+
+type sesRawMessage struct {
+	Data string `json:"Data"` // Base64 encoded MIME message
+}
+
+// newSES creates a new SES driver from a provider configuration
+func newSES(pc *config.ProviderConfig) (gomail.Mailer, error) {
+	creds, err := loadAWSCredentials(pc.SES)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sesDriver{
+		cfg:    pc.SES,
+		creds:  creds,
+		from:   mail.Address{Name: pc.FromName, Address: pc.FromAddress},
+		client: &http.Client{Timeout: sesTimeout},
+	}, nil
+}
+
+// Send builds a MIME message and submits it with SendEmail
+func (d *sesDriver) Send(t *gomail.Transmission) (gomail.Response, error) {
+	return d.sendMessage(&message{Transmission: t, Attachments: partsFromAttachments(t.Attachments)})
+}
+
+// sendMessage builds a MIME message and submits it with SendEmail
+func (d *sesDriver) sendMessage(msg *message) (gomail.Response, error) {
+	t := msg.Transmission
+	if err := t.Validate(); err != nil {
+		return gomail.Response{}, err
+	}
+
+	raw, err := buildMIME(d.from, msg)
+	if err != nil {
+		return gomail.Response{}, fmt.Errorf("failed to build message: %w", err)
+	}
+
+	body, err := json.Marshal(sesSendRequest{
+		FromEmailAddress: d.from.String(),
+		Destination: sesDestination{
+			ToAddresses:  t.Recipients,
+			CcAddresses:  t.CC,
+			BccAddresses: t.BCC,
+		},
+		Content: sesContent{
+			Raw: sesRawMessage{Data: base64.StdEncoding.EncodeToString(raw)},
+		},
+		ConfigurationSetName: d.cfg.ConfigurationSet,
+	})
+	if err != nil {
+		return gomail.Response{}, err
+	}
+
+	resp, respBody, err := d.do("POST", "/v2/email/outbound-emails", body)
+	if err != nil {
+		return gomail.Response{}, err
+	}
+
+	response := gomail.Response{
+		StatusCode: resp.StatusCode,
+		Body:       respBody,
+		Headers:    resp.Header,
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("ses returned %s: %s", resp.Status, sesErrorMessage(respBody))
+	}
+
+	var result struct {
+		MessageID string `json:"MessageId"`
+	}
+	if json.Unmarshal(respBody, &result) == nil {
+		response.ID = result.MessageID
+	}
+	response.Message = "Email sent successfully"
+
+	return response, nil
+}
+
+// do performs a signed request against the SES API
+func (d *sesDriver) do(method, path string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, d.cfg.GetEndpoint()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create ses request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	signV4(req, body, d.creds, d.cfg.Region, "ses", time.Now())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ses request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, fmt.Errorf("failed to read ses response: %w", err)
+	}
+	return resp, respBody, nil
+}
+
+// sesErrorMessage extracts the message from an SES error response
+func sesErrorMessage(body []byte) string {
+	var apiErr struct {
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil {
+		if apiErr.Message != "" {
+			return apiErr.Message
+		}
+		if apiErr.MessageUpper != "" {
+			return apiErr.MessageUpper
+		}
+	}
+	return string(bytes.TrimSpace(body))
+}
+
+// loadAWSCredentials resolves credentials from config, profile or environment
+func loadAWSCredentials(sc *config.SESConfig) (awsCredentials, error) {
+	if sc.AccessKeyID != "" {
+		return awsCredentials{
+			AccessKeyID:     sc.AccessKeyID,
+			SecretAccessKey: sc.SecretAccessKey,
+		}, nil
+	}
+
+	profile := sc.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+
+	// Environment variables apply unless a profile was requested explicitly
+	if profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return awsCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	if profile == "" {
+		profile = "default"
+	}
+
+	creds, err := readAWSProfile(profile)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no ses credentials configured: %w", err)
+	}
+	return creds, nil
+}
+
+// readAWSProfile reads a profile from the shared AWS credentials file
+func readAWSProfile(profile string) (awsCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, ".aws", "credentials")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to open credentials file: %w", err)
+	}
+	defer file.Close()
+
+	var creds awsCredentials
+	found := false
+	inProfile := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+			found = found || inProfile
+			continue
+		}
+		if !inProfile {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	if !found {
+		return awsCredentials{}, fmt.Errorf("profile '%s' not found in %s", profile, path)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("profile '%s' has no access keys", profile)
+	}
+	return creds, nil
+}
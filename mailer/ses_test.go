@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mailgloss/config"
+
+	gomail "github.com/ainsleyclark/go-mail/mail"
+)
+
+func TestSignV4(t *testing.T) {
+	// "get-vanilla" from the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
+
+func TestSESSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/email/outbound-emails" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+			t.Errorf("request not signed: %s", r.Header.Get("Authorization"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var req sesSendRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("invalid body: %v", err)
+		}
+		if req.ConfigurationSetName != "tracking" {
+			t.Errorf("configuration set = %q", req.ConfigurationSetName)
+		}
+		if len(req.Destination.BccAddresses) != 1 {
+			t.Errorf("bcc = %v", req.Destination.BccAddresses)
+		}
+
+		raw, _ := base64.StdEncoding.DecodeString(req.Content.Raw.Data)
+		if !strings.Contains(string(raw), "Subject: Hello") {
+			t.Errorf("raw message missing subject:\n%s", raw)
+		}
+		if strings.Contains(string(raw), "hidden@example.com") {
+			t.Errorf("raw message leaks bcc recipient:\n%s", raw)
+		}
+
+		w.Write([]byte(`{"MessageId": "ses-123"}`))
+	}))
+	defer server.Close()
+
+	driver, err := newSES(&config.ProviderConfig{
+		FromAddress: "sender@example.com",
+		FromName:    "Sender",
+		SES: &config.SESConfig{
+			Region:           "eu-west-1",
+			AccessKeyID:      "AKID",
+			SecretAccessKey:  "secret",
+			ConfigurationSet: "tracking",
+			Endpoint:         server.URL,
+		},
+	})
+	if err != nil {
+		t.Fatalf("newSES() error = %v", err)
+	}
+
+	resp, err := driver.Send(&gomail.Transmission{
+		Recipients: []string{"to@example.com"},
+		BCC:        []string{"hidden@example.com"},
+		Subject:    "Hello",
+		HTML:       "<p>Hi</p>",
+		PlainText:  "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if resp.ID != "ses-123" {
+		t.Errorf("Send() ID = %q, want ses-123", resp.ID)
+	}
+}
@@ -0,0 +1,338 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"mailgloss/config"
+
+	"github.com/smallstep/pkcs7"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// oidEmailAddress is the emailAddress attribute of certificate subjects
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// pkcs7Mu serializes encryption, since pkcs7.Encrypt takes its content
+// encryption algorithm from a package variable
+var pkcs7Mu sync.Mutex
+
+// errSMIMEBcc is returned for encrypted messages with Bcc recipients, whose
+// certificates would be visible to every other recipient
+var errSMIMEBcc = errors.New("S/MIME encryption would reveal the Bcc recipients to everyone, send them a separate message")
+
+// smimeOptions is how a message is protected with S/MIME (RFC 8551)
+type smimeOptions struct {
+	signer     *smimeIdentity      // nil to not sign
+	recipients []*x509.Certificate // Empty to not encrypt
+}
+
+// smimeIdentity is a signing key with its certificate and the rest of the
+// chain, which is sent along for recipients to verify the certificate
+type smimeIdentity struct {
+	key   crypto.Signer
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+}
+
+// loadSMIMEIdentity reads the PKCS#12 file of an S/MIME configuration
+func loadSMIMEIdentity(sc *config.SMIMEConfig) (*smimeIdentity, error) {
+	data, err := os.ReadFile(config.ExpandHome(sc.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S/MIME certificate: %w", err)
+	}
+	parsed, cert, chain, err := pkcs12.DecodeChain(data, sc.Password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return nil, fmt.Errorf("S/MIME certificate %s: wrong password or corrupt file", sc.Certificate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("S/MIME certificate %s: %w", sc.Certificate, err)
+	}
+	var key crypto.Signer
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key = k
+	case *ecdsa.PrivateKey:
+		key = k
+	default:
+		return nil, fmt.Errorf("S/MIME certificate %s: only RSA and ECDSA keys can sign", sc.Certificate)
+	}
+	if cert, chain, err = keyCertificate(key, cert, chain); err != nil {
+		return nil, fmt.Errorf("S/MIME certificate %s: %w", sc.Certificate, err)
+	}
+	return &smimeIdentity{key: key, cert: cert, chain: chain}, nil
+}
+
+// keyCertificate returns the certificate of a key and the other
+// certificates of a PKCS#12 file. Some exporters put the chain before the
+// key's certificate.
+func keyCertificate(key crypto.Signer, first *x509.Certificate, rest []*x509.Certificate) (*x509.Certificate, []*x509.Certificate, error) {
+	certs := append([]*x509.Certificate{first}, rest...)
+	for i, cert := range certs {
+		if pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(key.Public()) {
+			return cert, append(certs[:i:i], certs[i+1:]...), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no certificate for the private key")
+}
+
+// ParseSMIMECertificate reads a PEM or DER encoded certificate
+func ParseSMIMECertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("expected a certificate, found %s", strings.ToLower(block.Type))
+		}
+		data = block.Bytes
+	}
+	return x509.ParseCertificate(data)
+}
+
+// SMIMEAddresses returns the email addresses a certificate is issued to
+func SMIMEAddresses(cert *x509.Certificate) []string {
+	addresses := append([]string{}, cert.EmailAddresses...)
+	for _, name := range cert.Subject.Names {
+		if s, ok := name.Value.(string); ok && name.Type.Equal(oidEmailAddress) {
+			addresses = append(addresses, s)
+		}
+	}
+	return addresses
+}
+
+// FindSMIMECertificate returns the first certificate issued to an address
+// that can encrypt, or nil. Certificates are not verified against a CA:
+// they are trusted as the user stored them.
+func FindSMIMECertificate(certs []*x509.Certificate, address string) *x509.Certificate {
+	now := time.Now()
+	for _, cert := range certs {
+		if !smimeCanEncrypt(cert, now) {
+			continue
+		}
+		for _, addr := range SMIMEAddresses(cert) {
+			if strings.EqualFold(addr, address) {
+				return cert
+			}
+		}
+	}
+	return nil
+}
+
+// smimeCanEncrypt reports whether a certificate is valid and has an RSA
+// key allowed to encrypt keys
+func smimeCanEncrypt(cert *x509.Certificate, now time.Time) bool {
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+		return false
+	}
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return false
+	}
+	return cert.KeyUsage == 0 || cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0
+}
+
+// senderSMIMEIdentity returns the sender's certificate and key, reading
+// them the first time. It is nil without an error if none is configured.
+func (m *Mailer) senderSMIMEIdentity() (*smimeIdentity, error) {
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+	if m.smime != nil {
+		return m.smime, nil
+	}
+	sc := m.providerConfig.SMIMEFor(m.providerConfig.FromAddress)
+	if sc == nil {
+		return nil, nil
+	}
+	identity, err := loadSMIMEIdentity(sc)
+	if err != nil {
+		return nil, err
+	}
+	m.smime = identity
+	return identity, nil
+}
+
+// smimeOptions resolves the certificates to sign and encrypt an email
+// with. Every recipient needs a certificate in data.SMIMECerts to encrypt.
+// The sender's certificate is added when it can encrypt, so the sent
+// message stays readable for the sender. Bcc recipients are refused, since
+// enveloped data names the certificate of every recipient.
+func (m *Mailer) smimeOptions(data EmailData) (*smimeOptions, error) {
+	opts := &smimeOptions{}
+	identity, identityErr := m.senderSMIMEIdentity()
+	if data.SMIMESign {
+		if identityErr != nil {
+			return nil, identityErr
+		}
+		if identity == nil {
+			return nil, fmt.Errorf("no S/MIME certificate is configured for %s", m.providerConfig.FromAddress)
+		}
+		if now := time.Now(); now.Before(identity.cert.NotBefore) || now.After(identity.cert.NotAfter) {
+			return nil, fmt.Errorf("S/MIME certificate of %s expired on %s", m.providerConfig.FromAddress, identity.cert.NotAfter.Format("2006-01-02"))
+		}
+		opts.signer = identity
+	}
+
+	if data.SMIMEEncrypt {
+		if len(data.BCC) > 0 {
+			return nil, errSMIMEBcc
+		}
+		selfEncrypt := identity != nil && smimeCanEncrypt(identity.cert, time.Now())
+		var missing []string
+		for _, rcpt := range append(append([]string{}, data.To...), data.CC...) {
+			addr, err := mail.ParseAddress(rcpt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient '%s': %w", rcpt, err)
+			}
+			if selfEncrypt && strings.EqualFold(addr.Address, m.providerConfig.FromAddress) {
+				continue
+			}
+			cert := FindSMIMECertificate(data.SMIMECerts, addr.Address)
+			if cert == nil {
+				// The sender's certificate failing to load is the better error
+				if identityErr != nil && strings.EqualFold(addr.Address, m.providerConfig.FromAddress) {
+					return nil, identityErr
+				}
+				missing = append(missing, addr.Address)
+				continue
+			}
+			opts.recipients = append(opts.recipients, cert)
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("no S/MIME certificate for %s", strings.Join(missing, ", "))
+		}
+		if selfEncrypt {
+			opts.recipients = append(opts.recipients, identity.cert)
+		}
+	}
+	return opts, nil
+}
+
+// writeSMIME writes a message signed, encrypted or both, signing first.
+// The header fields stay readable; only the content is protected.
+func writeSMIME(w io.Writer, header textproto.MIMEHeader, msg *message) error {
+	var entity bytes.Buffer
+	if err := writeEntity(&entity, textproto.MIMEHeader{}, msg); err != nil {
+		return err
+	}
+	content := entity.Bytes()
+
+	s := msg.smime
+	if s.signer == nil {
+		return writeSMIMEEnveloped(w, header, content, s.recipients)
+	}
+	if len(s.recipients) == 0 {
+		return writeSMIMESigned(w, header, content, s.signer)
+	}
+	var signed bytes.Buffer
+	if err := writeSMIMESigned(&signed, textproto.MIMEHeader{}, content, s.signer); err != nil {
+		return err
+	}
+	return writeSMIMEEnveloped(w, header, signed.Bytes(), s.recipients)
+}
+
+// writeSMIMESigned writes content as the first part of a multipart/signed
+// entity, followed by a detached signature over it
+func writeSMIMESigned(w io.Writer, header textproto.MIMEHeader, content []byte, signer *smimeIdentity) error {
+	sig, err := smimeSign(signer, content)
+	if err != nil {
+		return fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	// The content is written as is, since it is what the signature covers
+	boundary := randomBoundary()
+	header.Set("Content-Type", `multipart/signed; protocol="application/pkcs7-signature"; micalg=sha-256; boundary=`+boundary)
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "--%s\r\n", boundary); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "\r\n--%s\r\n", boundary); err != nil {
+		return err
+	}
+
+	sigHeader := textproto.MIMEHeader{}
+	sigHeader.Set("Content-Type", `application/pkcs7-signature; name="smime.p7s"`)
+	sigHeader.Set("Content-Transfer-Encoding", "base64")
+	sigHeader.Set("Content-Description", "S/MIME Cryptographic Signature")
+	sigHeader.Set("Content-Disposition", `attachment; filename="smime.p7s"`)
+	if err := writeHeader(w, sigHeader); err != nil {
+		return err
+	}
+	if err := writeBase64(w, sig); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "--%s--\r\n", boundary)
+	return err
+}
+
+// writeSMIMEEnveloped writes content encrypted to the recipients as an
+// application/pkcs7-mime entity
+func writeSMIMEEnveloped(w io.Writer, header textproto.MIMEHeader, content []byte, recipients []*x509.Certificate) error {
+	enveloped, err := smimeEncrypt(content, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	header.Set("Content-Type", `application/pkcs7-mime; smime-type=enveloped-data; name="smime.p7m"`)
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", `attachment; filename="smime.p7m"`)
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+	return writeBase64(w, enveloped)
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	return filePart{data: data}.writeBase64(w)
+}
+
+// smimeSign returns a detached CMS signature (RFC 5652) over content,
+// including the signer's certificate chain
+func smimeSign(signer *smimeIdentity, content []byte) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSigner(signer.cert, signer.key, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	for _, cert := range signer.chain {
+		sd.AddCertificate(cert)
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+// smimeEncrypt encrypts content to the recipients' RSA keys with a random
+// AES-256 key as CMS enveloped data
+func smimeEncrypt(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	for _, cert := range recipients {
+		if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("certificate of %s has no RSA key", cert.Subject.CommonName)
+		}
+	}
+
+	// The package variable defaults to DES and is restored for other users
+	pkcs7Mu.Lock()
+	defer pkcs7Mu.Unlock()
+	previous := pkcs7.ContentEncryptionAlgorithm
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+	defer func() { pkcs7.ContentEncryptionAlgorithm = previous }()
+	return pkcs7.Encrypt(content, recipients)
+}
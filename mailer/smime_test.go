@@ -0,0 +1,322 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mailgloss/config"
+
+	"github.com/smallstep/pkcs7"
+)
+
+// An ECDSA key and certificate for carol@example.com as exported by
+// OpenSSL 3 (PBES2 with AES-256 and a SHA-256 MAC), password "secret"
+const testPKCS12 = `
+	MIIEHAIBAzCCA9IGCSqGSIb3DQEHAaCCA8MEggO/MIIDuzCCAnIGCSqGSIb3DQEH
+	BqCCAmMwggJfAgEAMIICWAYJKoZIhvcNAQcBMFcGCSqGSIb3DQEFDTBKMCkGCSqG
+	SIb3DQEFDDAcBAhT3tnbqfyhRAICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQME
+	ASoEEA4tC9by8NvQ169H6GSbd1iAggHwOACfpGwGcVgs+8QvHl+d2b5zXBHzioFt
+	uoBjWIPX4TbVkuH4eqQYoGic1MY99ug2/XS4yka3yKuuUbU9YcEbP3ahWukO024j
+	0h4qgEifnghkdlnNh81Or3gX/p6GFN7zswZTF8IxUdiwBziHbW40o3ZBhHX+3Xwh
+	zkuH4Usm2vDLnIJ5bJd+67yUsSOEFnIPN9/+xReJctxDhB/gKO16RkZUSQFNCTpm
+	N604yBBEPQ4Q9Dq9FZpfMh729SZNXgD3DCBqkZ4fzjJRaIXuh0eBEeh5YCQkNnml
+	6IC/+vEET1Vsc72TgafocV6UvO+R/pd0cb6KLYRB6YctJrjPvGGlwpRjd0RnL/66
+	IPjLH1emBlAalRbiElHuBC90DVTHgX7Vu1Z/ZKXNrVHmpfpDRMcwp7IL3ng0uC0i
+	NUUe+KE/MES5Z/6qWuRMTnQxLcXWlhP4r8y6Hwki6QJ3eohwIGI2gGf7/7oXcpDS
+	C8h4bnFFfJVqNQM1sWAxaS52Oh+sWL/B+vrjGef8AnzetXMlwEYIUxk9bIIp1H/c
+	uMNRgogouHkcXbedPfaHsd7mZ7HO5n1zMGLeXzzW2RkjJnnw8Yeeit3P9VXxqTYB
+	ZujtrPeUObMMUtEHUNglxie5GORXpc/VVmu2kUokOtDO7Ye4v6RgeDCCAUEGCSqG
+	SIb3DQEHAaCCATIEggEuMIIBKjCCASYGCyqGSIb3DQEMCgECoIHvMIHsMFcGCSqG
+	SIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAhCJWzrdxLHzwICCAAwDAYIKoZIhvcN
+	AgkFADAdBglghkgBZQMEASoEEH+rDv0zfETSjNTcvTzRj4UEgZBNn8nLIaMMtZiM
+	6On/MtBRpkXpGY1gBUlcUDCiOLCDvptXkGMLre+S3uIyG6KTMDxnNn+Sx2YSMeNI
+	yf1cMIUmD6G3bQAltzeFL7rtK/xQzbOx15o4jAsLSuOuEd7maURXnIhKL7wuCayA
+	9EbLGNc2VeMxNPFWBXMo0eWJ6V2vViDL89UqLTu45HygEHfnb+gxJTAjBgkqhkiG
+	9w0BCRUxFgQULuyW3c0WkQnDH+wSd1d5fBnUFIwwQTAxMA0GCWCGSAFlAwQCAQUA
+	BCBOBQ2so/GI2OWAhcUM4EltTGDWId5h7uvcBTX7GxOpdAQIuFVNOcWyBT4CAggA
+`
+
+// An ECDSA key and certificate for dave@example.com as exported by
+// "openssl pkcs12 -export -legacy" like older exporters do (3DES key, RC2
+// certificate and a SHA-1 MAC), password "secret"
+const testPKCS12Legacy = `
+	MIID4gIBAzCCA6gGCSqGSIb3DQEHAaCCA5kEggOVMIIDkTCCAocGCSqGSIb3DQEH
+	BqCCAngwggJ0AgEAMIICbQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQYwDgQIA6lp
+	SOURbh8CAggAgIICQNGLC3CrV+SOYnmgNyMPF0B2dl26sLLaOxzL5bnDaQ/S5apl
+	voLpaZVaVRBxLcWJJ+rOCEPyaRAI9pTN00cjUZhVRIqcD/8ZLuhdcvDVz8+ghm46
+	Z0xF2EDfNVzWgsVYFJ39YK8Rn2LNu0WggHZx9Sb5qAcknLiiOEAPSAl7d0G6Jyo2
+	WVRqA+8mwfgKxL05CzTP7jBc3k8yH4/3qnyUZiTBUzUZNakbviC4/3nPD71hI/7a
+	VO/xcjT0tAeMd74aux1954mEFOigzRvKNRXQpRRSFDt2FMsNj1oQHjhGvAlt03Hx
+	K0jKmppLbz6pFNOeW+kLAVej3ydhOjxeeWFtKeB2wjKaUrQoHvZ9+O+Xx1OhBea5
+	XiePCHqiEm1Jp/iVaZSs+/IJH+A4pzr0aB1N7FgC0C6mqMoZkYVMfim0p0y4E+PJ
+	2UJx5zk1mH5UvnGrqpSFTkC87QNghWeA8tkB7Oph3Xyx54iSfoDXsrMnoZHYSkZp
+	DYTVBtpJyiAWdn5x+81YiqJVIMBvQj9dzgmMCNHShaar6LqB0UE5hD0uNo16hKIN
+	bBDvIwTpRuPfvfPwbXSJtq62RpBX7peE9tC0y/r2Ifju9sB/YcigwssGPpilwo4x
+	/UiLpb+y/YdHaewHX+SojDdHHer+So18EiNau3qX2I5NlGklb4wBeqaYBlvT4MLv
+	ntcoHx7IMZJn34D5+MSGYY9XsmYA0QIb6RRFdUNqhxrGG87Q+jrgDt0E6K0hJb9b
+	kgcLm2OQQzFbS/+SMTCCAQIGCSqGSIb3DQEHAaCB9ASB8TCB7jCB6wYLKoZIhvcN
+	AQwKAQKggbQwgbEwHAYKKoZIhvcNAQwBAzAOBAhA8OQzaIRcaQICCAAEgZAsmzbI
+	BZOB1TMdFNReREoxxJ1aa5bXoRXqdzcgJy3bNnIlePoFoS1FMEE4flx+WoCn/yH/
+	9Ibn53pRdK6r3dIMJP1lQy1dyZab5drLD1CzrbuiXxI+0hVUGzh/F4i016irFjuJ
+	ZBX1fxlcT9cDYgigr+ZiBj7UGka2c1KT7ZKDkOB15/zQo6gEvyDNWgHlsBAxJTAj
+	BgkqhkiG9w0BCRUxFgQUwSzGLZlP4U9kAVME6Bi0BYsGnn8wMTAhMAkGBSsOAwIa
+	BQAEFHFoWa40+HK/KSF4qJT2NadqrxaLBAgwLIx/l4k8XgICCAA=
+`
+
+func TestLoadSMIMEIdentity(t *testing.T) {
+	for _, tt := range []struct {
+		name, pfx, address string
+	}{
+		{"openssl 3", testPKCS12, "carol@example.com"},
+		{"legacy", testPKCS12Legacy, "dave@example.com"},
+	} {
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(tt.pfx), ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "cert.p12")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := loadSMIMEIdentity(&config.SMIMEConfig{Certificate: path, Password: "wrong"}); err == nil || !strings.Contains(err.Error(), "wrong password") {
+			t.Errorf("%s: loadSMIMEIdentity() with a wrong password: %v", tt.name, err)
+		}
+		id, err := loadSMIMEIdentity(&config.SMIMEConfig{Certificate: path, Password: "secret"})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := SMIMEAddresses(id.cert); len(got) == 0 || got[0] != tt.address || len(id.chain) != 0 {
+			t.Errorf("%s: SMIMEAddresses() = %v, chain = %d", tt.name, got, len(id.chain))
+		}
+	}
+
+	// The key's certificate is found after its chain
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, cert := testSMIMECertificate(t, caKey), testSMIMECertificate(t, key, "me@example.com")
+	if got, chain, err := keyCertificate(key, ca, []*x509.Certificate{cert}); err != nil || got != cert || len(chain) != 1 || chain[0] != ca {
+		t.Errorf("keyCertificate() = %v, %v, %v", got, chain, err)
+	}
+	if _, _, err := keyCertificate(key, ca, nil); err == nil {
+		t.Error("keyCertificate() without the key's certificate succeeded")
+	}
+}
+
+func TestSMIME(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testSMIMECertificate(t, key, "me@example.com")
+	if FindSMIMECertificate([]*x509.Certificate{cert}, "ME@example.com") != cert {
+		t.Error("FindSMIMECertificate() didn't find the certificate")
+	}
+	content := []byte("Content-Type: text/plain\r\n\r\nHello\r\n")
+
+	signed, err := smimeSign(&smimeIdentity{key: key, cert: cert}, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7.Content = content
+	if err := p7.Verify(); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	if len(p7.Signers) != 1 || !p7.Signers[0].DigestAlgorithm.Algorithm.Equal(pkcs7.OIDDigestAlgorithmSHA256) {
+		t.Errorf("signers = %+v, want one with SHA-256 for micalg=sha-256", p7.Signers)
+	}
+
+	// Lengths of 16 MiB and more take four length octets in DER
+	large := append(bytes.Repeat([]byte("0123456789abcdef"), 1<<20), 'x')
+	for _, content := range [][]byte{content, large} {
+		enveloped, err := smimeEncrypt(content, []*x509.Certificate{cert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		aes256, _ := asn1.Marshal(pkcs7.OIDEncryptionAlgorithmAES256CBC)
+		if !bytes.Contains(enveloped, aes256) {
+			t.Error("content is not encrypted with AES-256-CBC")
+		}
+		p7, err := pkcs7.Parse(enveloped)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(content), err)
+		}
+		got, err := p7.Decrypt(cert, key)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(content), err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("%d bytes: decrypted %d bytes that differ", len(content), len(got))
+		}
+	}
+	if pkcs7.ContentEncryptionAlgorithm != pkcs7.EncryptionAlgorithmDESCBC {
+		t.Errorf("pkcs7.ContentEncryptionAlgorithm = %d after encrypting, want the package default", pkcs7.ContentEncryptionAlgorithm)
+	}
+}
+
+func TestSMIMEIdentityLoadedOnUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.p12")
+	pc := &config.ProviderConfig{
+		Name:        "local",
+		Type:        config.ProviderSendmail,
+		FromAddress: "carol@example.com",
+		SMIME:       &config.SMIMEConfig{Certificate: path, Password: "secret"},
+	}
+
+	// A missing certificate doesn't stop messages that aren't signed
+	m, err := New(pc)
+	if err != nil {
+		t.Fatalf("New() with a missing S/MIME certificate error = %v", err)
+	}
+	if _, err := m.smimeOptions(EmailData{To: []string{"carol@example.com"}}); err != nil {
+		t.Errorf("smimeOptions() without signing error = %v", err)
+	}
+	if _, err := m.smimeOptions(EmailData{To: []string{"carol@example.com"}, SMIMESign: true}); err == nil || !strings.Contains(err.Error(), "failed to read S/MIME certificate") {
+		t.Errorf("smimeOptions() signing with a missing certificate error = %v", err)
+	}
+
+	// Once the certificate is in place it is read on the next signed message
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(testPKCS12), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := m.smimeOptions(EmailData{To: []string{"carol@example.com"}, SMIMESign: true})
+	if err != nil {
+		t.Fatalf("smimeOptions() error = %v", err)
+	}
+	if opts.signer == nil || SMIMEAddresses(opts.signer.cert)[0] != "carol@example.com" {
+		t.Errorf("smimeOptions() = %+v, want carol's certificate as signer", opts)
+	}
+}
+
+// Check the messages with OpenSSL, as a receiving client would
+func TestSMIMEOpenSSL(t *testing.T) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl not installed")
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testSMIMECertificate(t, key, "me@example.com")
+	dir := t.TempDir()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath, certPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("Content-Type: text/plain\r\n\r\nHello\r\n")
+
+	openssl := func(msg []byte, args ...string) []byte {
+		t.Helper()
+		path := filepath.Join(dir, "msg.eml")
+		if err := os.WriteFile(path, msg, 0600); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("openssl", append(append([]string{"smime"}, args...), "-binary", "-in", path)...).Output()
+		if err != nil {
+			t.Fatalf("openssl %s: %v", args[0], err)
+		}
+		return out
+	}
+
+	var signed bytes.Buffer
+	if err := writeSMIMESigned(&signed, textproto.MIMEHeader{}, content, &smimeIdentity{key: key, cert: cert}); err != nil {
+		t.Fatal(err)
+	}
+	if got := openssl(signed.Bytes(), "-verify", "-noverify"); !bytes.Equal(got, content) {
+		t.Errorf("openssl verified %q, want %q", got, content)
+	}
+
+	var enveloped bytes.Buffer
+	if err := writeSMIMEEnveloped(&enveloped, textproto.MIMEHeader{}, content, []*x509.Certificate{cert}); err != nil {
+		t.Fatal(err)
+	}
+	if got := openssl(enveloped.Bytes(), "-decrypt", "-recip", certPath, "-inkey", keyPath); !bytes.Equal(got, content) {
+		t.Errorf("openssl decrypted %q, want %q", got, content)
+	}
+}
+
+func TestSMIMEOptionsBcc(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := testSMIMECertificate(t, key, "you@example.org", "hidden@example.org")
+	m := &Mailer{providerConfig: &config.ProviderConfig{FromAddress: "me@example.com"}}
+	data := EmailData{
+		To:           []string{"you@example.org"},
+		SMIMEEncrypt: true,
+		SMIMECerts:   []*x509.Certificate{cert},
+	}
+
+	opts, err := m.smimeOptions(data)
+	if err != nil || len(opts.recipients) != 1 {
+		t.Fatalf("smimeOptions() = %+v, %v", opts, err)
+	}
+	data.BCC = []string{"hidden@example.org"}
+	if _, err := m.smimeOptions(data); !errors.Is(err, errSMIMEBcc) {
+		t.Errorf("smimeOptions() with Bcc error = %v, want %v", err, errSMIMEBcc)
+	}
+	data.SMIMEEncrypt, data.SMIMESign = false, true
+	m.smime = &smimeIdentity{key: key, cert: cert}
+	if _, err := m.smimeOptions(data); err != nil {
+		t.Errorf("smimeOptions() signing only with Bcc error = %v", err)
+	}
+}
+
+// testSMIMECertificate returns a self-signed certificate for addresses
+func testSMIMECertificate(t *testing.T, key *rsa.PrivateKey, addresses ...string) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(42),
+		Subject:        pkix.Name{CommonName: "Me"},
+		EmailAddresses: addresses,
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,494 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"mailgloss/config"
+	"mailgloss/logger"
+)
+
+// verifyTimeout bounds every network operation of a connection test
+const verifyTimeout = 15 * time.Second
+
+// Diagnostic is the outcome of a single connection test step
+type Diagnostic struct {
+	Step   string
+	OK     bool
+	Detail string
+}
+
+// diagnostics collects the steps of a connection test
+type diagnostics []Diagnostic
+
+func (d *diagnostics) pass(step, detail string) {
+	*d = append(*d, Diagnostic{Step: step, OK: true, Detail: detail})
+}
+
+func (d *diagnostics) fail(step string, err error) error {
+	*d = append(*d, Diagnostic{Step: step, OK: false, Detail: err.Error()})
+	return fmt.Errorf("%s: %w", strings.ToLower(step), err)
+}
+
+// Verify checks that a provider is reachable and accepts its credentials
+// without sending any mail. Every step taken is returned, also on failure.
+func Verify(pc *config.ProviderConfig) ([]Diagnostic, error) {
+	var d diagnostics
+
+	if err := pc.Validate(); err != nil {
+		return d, d.fail("Configuration", err)
+	}
+	d.pass("Configuration", "valid")
+
+	var err error
+	switch pc.Type {
+	case config.ProviderSMTP:
+		err = verifySMTP(pc.SMTP, &d)
+	case config.ProviderMailgun:
+		err = verifyMailgun(pc.Mailgun, &d)
+	case config.ProviderSendGrid:
+		err = verifySendGrid(pc.SendGrid, &d)
+	case config.ProviderPostmark:
+		err = verifyPostmark(pc.Postmark, &d)
+	case config.ProviderSparkPost:
+		err = verifySparkPost(pc.SparkPost, &d)
+	case config.ProviderPostal:
+		err = verifyPostal(pc.Postal, &d)
+	case config.ProviderWebhook:
+		err = verifyWebhook(pc.Webhook, &d)
+	case config.ProviderSES:
+		err = verifySES(pc, &d)
+	case config.ProviderSendmail:
+		err = verifySendmail(pc.Sendmail, &d)
+	default:
+		err = d.fail("Provider", fmt.Errorf("unsupported provider type: %s", pc.Type))
+	}
+	if err == nil && (pc.Type == config.ProviderSMTP || pc.Type == config.ProviderSendmail) {
+		err = verifyDKIM(pc, &d)
+	}
+
+	if err != nil {
+		logger.Warn("Provider verification failed", "provider", pc.Name, "error", err)
+	} else {
+		logger.Info("Provider verified", "provider", pc.Name)
+	}
+	return d, err
+}
+
+// SendTestMessage sends a short test email to the provider's own From address
+func SendTestMessage(pc *config.ProviderConfig, maxAttachmentMB int) (Diagnostic, error) {
+	step := "Test message"
+	ml, err := NewWithLimits(pc, maxAttachmentMB)
+	if err != nil {
+		return Diagnostic{Step: step, Detail: err.Error()}, err
+	}
+
+	_, err = ml.Send(EmailData{
+		To:      []string{pc.FromAddress},
+		Subject: "mailgloss test message",
+		Body: fmt.Sprintf("This is a test message sent by mailgloss to verify the provider '%s' (%s).\n\nSent at %s.",
+			pc.Name, pc.Type, time.Now().Format(time.RFC1123)),
+	})
+	if err != nil {
+		return Diagnostic{Step: step, Detail: err.Error()}, err
+	}
+	return Diagnostic{Step: step, OK: true, Detail: "sent to " + pc.FromAddress}, nil
+}
+
+// verifySMTP connects, says EHLO, upgrades to TLS and authenticates
+func verifySMTP(sc *config.SMTPConfig, d *diagnostics) error {
+	addr := net.JoinHostPort(sc.Host, strconv.Itoa(sc.Port))
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: verifyTimeout}
+	implicitTLS := sc.Port == 465
+	if implicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: sc.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return d.fail("Connect", err)
+	}
+	conn.SetDeadline(time.Now().Add(verifyTimeout))
+	d.pass("Connect", addr)
+
+	client, err := smtp.NewClient(conn, sc.Host)
+	if err != nil {
+		conn.Close()
+		return d.fail("Greeting", err)
+	}
+	defer client.Close()
+
+	if err := client.Hello("localhost"); err != nil {
+		return d.fail("EHLO", err)
+	}
+	d.pass("EHLO", "accepted")
+
+	if !implicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: sc.Host}); err != nil {
+				return d.fail("STARTTLS", err)
+			}
+			d.pass("STARTTLS", "connection encrypted")
+		} else if sc.RequireTLS {
+			return d.fail("STARTTLS", fmt.Errorf("not offered by server, sending is refused with require_tls"))
+		} else {
+			d.pass("STARTTLS", "not offered by server, continuing unencrypted")
+		}
+	} else {
+		d.pass("TLS", "implicit TLS on port 465")
+	}
+
+	if sc.Username == "" {
+		d.pass("AUTH", "skipped, no username configured")
+	} else if ok, mechanisms := client.Extension("AUTH"); ok {
+		auth, err := smtpAuth(sc, mechanisms)
+		if err != nil {
+			return d.fail("AUTH", err)
+		}
+		if err := client.Auth(auth); err != nil {
+			return d.fail("AUTH", err)
+		}
+		d.pass("AUTH", "authenticated as "+sc.Username+" (server offers "+mechanisms+")")
+	} else {
+		d.pass("AUTH", "not offered by server")
+	}
+
+	client.Quit()
+	return nil
+}
+
+// verifyMailgun checks the API key and the state of the sending domain
+func verifyMailgun(mc *config.MailgunConfig, d *diagnostics) error {
+	baseURL := mc.URL
+	if baseURL == "" {
+		baseURL = "https://api.mailgun.net"
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(baseURL, "/")+"/v3/domains/"+mc.Domain, nil)
+	if err != nil {
+		return d.fail("API", err)
+	}
+	req.SetBasicAuth("api", mc.APIKey)
+
+	var result struct {
+		Domain struct {
+			State string `json:"state"`
+		} `json:"domain"`
+	}
+	if err := verifyRequest(req, &result); err != nil {
+		return d.fail("Credentials", err)
+	}
+	d.pass("Credentials", "API key accepted")
+
+	if result.Domain.State != "active" {
+		return d.fail("Domain", fmt.Errorf("%s is %s", mc.Domain, result.Domain.State))
+	}
+	d.pass("Domain", mc.Domain+" is active")
+	return nil
+}
+
+// verifySendGrid checks the API key and that it may send mail
+func verifySendGrid(sc *config.SendGridConfig, d *diagnostics) error {
+	req, err := http.NewRequest("GET", "https://api.sendgrid.com/v3/scopes", nil)
+	if err != nil {
+		return d.fail("API", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+sc.APIKey)
+
+	var result struct {
+		Scopes []string `json:"scopes"`
+	}
+	if err := verifyRequest(req, &result); err != nil {
+		return d.fail("Credentials", err)
+	}
+	d.pass("Credentials", "API key accepted")
+
+	for _, scope := range result.Scopes {
+		if scope == "mail.send" {
+			d.pass("Permissions", "mail.send granted")
+			return nil
+		}
+	}
+	return d.fail("Permissions", fmt.Errorf("API key lacks the mail.send scope"))
+}
+
+// verifyPostmark checks the server token
+func verifyPostmark(pc *config.PostmarkConfig, d *diagnostics) error {
+	req, err := http.NewRequest("GET", "https://api.postmarkapp.com/server", nil)
+	if err != nil {
+		return d.fail("API", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Postmark-Server-Token", pc.APIKey)
+
+	var result struct {
+		Name string `json:"Name"`
+	}
+	if err := verifyRequest(req, &result); err != nil {
+		return d.fail("Credentials", err)
+	}
+	d.pass("Credentials", "server token accepted for "+result.Name)
+	return nil
+}
+
+// verifySparkPost checks the API key against the account endpoint
+func verifySparkPost(sc *config.SparkPostConfig, d *diagnostics) error {
+	baseURL := sc.URL
+	if baseURL == "" {
+		baseURL = "https://api.sparkpost.com"
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(baseURL, "/")+"/api/v1/account", nil)
+	if err != nil {
+		return d.fail("API", err)
+	}
+	req.Header.Set("Authorization", sc.APIKey)
+
+	var result struct {
+		Results struct {
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	if err := verifyRequest(req, &result); err != nil {
+		return d.fail("Credentials", err)
+	}
+	d.pass("Credentials", "API key accepted")
+
+	if result.Results.Status != "" && result.Results.Status != "active" {
+		return d.fail("Account", fmt.Errorf("account is %s", result.Results.Status))
+	}
+	d.pass("Account", "active")
+	return nil
+}
+
+// verifyPostal submits an empty message, which Postal rejects with a
+// parameter error once the API key has been accepted
+func verifyPostal(pc *config.PostalConfig, d *diagnostics) error {
+	req, err := http.NewRequest("POST", strings.TrimRight(pc.URL, "/")+"/api/v1/send/message", strings.NewReader("{}"))
+	if err != nil {
+		return d.fail("API", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Server-API-Key", pc.APIKey)
+
+	var result struct {
+		Status string `json:"status"`
+		Data   struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"data"`
+	}
+	if err := verifyRequest(req, &result); err != nil {
+		return d.fail("Connect", err)
+	}
+	d.pass("Connect", pc.URL)
+
+	switch result.Data.Code {
+	case "InvalidServerAPIKey", "AccessDenied":
+		return d.fail("Credentials", fmt.Errorf("%s", result.Data.Message))
+	}
+	d.pass("Credentials", "API key accepted")
+	return nil
+}
+
+// verifyWebhook checks that the gateway is reachable and accepts the credentials
+func verifyWebhook(wc *config.WebhookConfig, d *diagnostics) error {
+	req, err := http.NewRequest("HEAD", wc.URL, nil)
+	if err != nil {
+		return d.fail("Connect", err)
+	}
+	for k, v := range wc.Headers {
+		req.Header.Set(k, v)
+	}
+	if wc.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+wc.Token)
+	} else if wc.Username != "" {
+		req.SetBasicAuth(wc.Username, wc.Password)
+	}
+
+	resp, err := (&http.Client{Timeout: verifyTimeout}).Do(req)
+	if err != nil {
+		return d.fail("Connect", err)
+	}
+	resp.Body.Close()
+	d.pass("Connect", fmt.Sprintf("%s responded with %s", wc.URL, resp.Status))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return d.fail("Credentials", fmt.Errorf("rejected with %s", resp.Status))
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		// Gateways often route POST only; the server is reachable, but the
+		// credentials are only checked by sending a test message
+		d.pass("Credentials", "not checked, the gateway does not accept HEAD")
+		return nil
+	case resp.StatusCode >= 500:
+		return d.fail("Gateway", fmt.Errorf("server error %s", resp.Status))
+	}
+	d.pass("Credentials", "not rejected (HEAD request)")
+	return nil
+}
+
+// verifySendmail checks that the sendmail program exists and is executable.
+// It isn't run, as sendmail has no way to check its setup without sending.
+func verifySendmail(sc *config.SendmailConfig, d *diagnostics) error {
+	path, err := exec.LookPath(sc.GetPath())
+	if err != nil {
+		return d.fail("Program", err)
+	}
+	d.pass("Program", path)
+	return nil
+}
+
+// verifySES checks the account status and whether the sender is verified
+func verifySES(pc *config.ProviderConfig, d *diagnostics) error {
+	driver, err := newSES(pc)
+	if err != nil {
+		return d.fail("Credentials", err)
+	}
+	ses := driver.(*sesDriver)
+	d.pass("Credentials", "access key "+ses.creds.AccessKeyID)
+
+	resp, body, err := ses.do("GET", "/v2/email/account", nil)
+	if err != nil {
+		return d.fail("Account", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return d.fail("Account", fmt.Errorf("%s: %s", resp.Status, sesErrorMessage(body)))
+	}
+
+	var account struct {
+		ProductionAccessEnabled bool `json:"ProductionAccessEnabled"`
+		SendingEnabled          bool `json:"SendingEnabled"`
+		SendQuota               struct {
+			Max24HourSend   float64 `json:"Max24HourSend"`
+			SentLast24Hours float64 `json:"SentLast24Hours"`
+		} `json:"SendQuota"`
+	}
+	json.Unmarshal(body, &account)
+	if !account.SendingEnabled {
+		return d.fail("Account", fmt.Errorf("sending is disabled for this account"))
+	}
+	detail := fmt.Sprintf("sent %.0f of %.0f in the last 24h", account.SendQuota.SentLast24Hours, account.SendQuota.Max24HourSend)
+	if !account.ProductionAccessEnabled {
+		detail += ", sandbox mode (verified recipients only)"
+	}
+	d.pass("Account", detail)
+
+	// The sender may be verified as an address or as a whole domain
+	for _, identity := range []string{pc.FromAddress, config.AddressDomain(pc.FromAddress)} {
+		if identity == "" {
+			continue
+		}
+		resp, body, err := ses.do("GET", "/v2/email/identities/"+identity, nil)
+		if err != nil {
+			return d.fail("Identity", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+		var result struct {
+			VerifiedForSendingStatus bool `json:"VerifiedForSendingStatus"`
+		}
+		json.Unmarshal(body, &result)
+		if result.VerifiedForSendingStatus {
+			d.pass("Identity", identity+" is verified for sending")
+			return nil
+		}
+	}
+	return d.fail("Identity", fmt.Errorf("%s is not verified for sending", pc.FromAddress))
+}
+
+// verifyRequest performs a request, failing on non-2xx responses and
+// decoding the JSON response body into result
+func verifyRequest(req *http.Request, result interface{}) error {
+	resp, err := (&http.Client{Timeout: verifyTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("credentials rejected (%s)", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	if result != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("unexpected response body: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyDKIM checks that the DKIM keys of the provider and its identities
+// load and match the public keys published in DNS
+func verifyDKIM(pc *config.ProviderConfig, d *diagnostics) error {
+	checked := map[string]bool{}
+	for _, identity := range pc.GetIdentities() {
+		dc := pc.DKIMFor(identity.Address)
+		if dc == nil {
+			continue
+		}
+		signer, err := newDKIMSigner(dc, identity.Address)
+		if err != nil {
+			return d.fail("DKIM", fmt.Errorf("%s: %w", identity.Address, err))
+		}
+		if checked[signer.selector+"._domainkey."+signer.domain] {
+			continue
+		}
+		checked[signer.selector+"._domainkey."+signer.domain] = true
+		published, err := lookupDKIMKey(signer.selector + "._domainkey." + signer.domain)
+		if err != nil {
+			return d.fail("DKIM", err)
+		}
+		own, err := signer.publicKeyRecord()
+		if err != nil {
+			return d.fail("DKIM", err)
+		}
+		if published != own {
+			return d.fail("DKIM", fmt.Errorf("the key published at %s._domainkey.%s does not match %s", signer.selector, signer.domain, dc.PrivateKey))
+		}
+		d.pass("DKIM", fmt.Sprintf("%s signs as %s with selector %s", identity.Address, signer.domain, signer.selector))
+	}
+	return nil
+}
+
+// lookupDKIMKey returns the p= tag of the DKIM record at a name
+func lookupDKIMKey(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("no DKIM record at %s: %w", name, err)
+	}
+	for _, record := range records {
+		for _, tag := range strings.Split(record, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+			if key == "p" {
+				return strings.Join(strings.Fields(value), ""), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the record at %s has no public key", name)
+}
@@ -0,0 +1,232 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+
+	"mailgloss/config"
+
+	"github.com/ainsleyclark/go-mail/mail"
+)
+
+// defaultWebhookTemplate is used when no body template is configured
+const defaultWebhookTemplate = `{
+  "from": {{json .From}},
+  "from_name": {{json .FromName}},
+  "to": {{json .To}},
+  "cc": {{json .CC}},
+  "bcc": {{json .BCC}},
+  "subject": {{json .Subject}},
+  "html": {{json .HTML}},
+  "text": {{json .Text}},
+  "headers": {{json .Headers}},
+  "attachments": {{json .Attachments}},
+  "inline": {{json .Inline}}
+}`
+
+// webhookTimeout is the amount of time to wait for the gateway to respond
+const webhookTimeout = 30 * time.Second
+
+// webhookDriver sends mail through a generic HTTP mail gateway
+type webhookDriver struct {
+	cfg      *config.WebhookConfig
+	from     string
+	fromName string
+	tmpl     *template.Template
+	client   *http.Client
+}
+
+// webhookPayload is the data passed to the webhook body template
+type webhookPayload struct {
+	From        string              `json:"from"`
+	FromName    string              `json:"from_name"`
+	To          []string            `json:"to"`
+	CC          []string            `json:"cc"`
+	BCC         []string            `json:"bcc"`
+	Subject     string              `json:"subject"`
+	HTML        string              `json:"html"`
+	Text        string              `json:"text"`
+	Headers     map[string]string   `json:"headers"`
+	Attachments []webhookAttachment `json:"attachments"`
+	Inline      []webhookAttachment `json:"inline"` // Images referenced by cid: in HTML
+}
+
+// webhookAttachment is a single attachment passed to the body template
+type webhookAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`              // Base64 encoded
+	ContentID   string `json:"content_id,omitempty"` // Set for inline images
+}
+
+// newWebhook creates a new webhook driver from a provider configuration
+func newWebhook(pc *config.ProviderConfig) (mail.Mailer, error) {
+	body := pc.Webhook.BodyTemplate
+	if body == "" {
+		body = defaultWebhookTemplate
+	}
+
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": toJSON,
+	}).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook body template: %w", err)
+	}
+
+	return &webhookDriver{
+		cfg:      pc.Webhook,
+		from:     pc.FromAddress,
+		fromName: pc.FromName,
+		tmpl:     tmpl,
+		client:   &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+// Send renders the body template and posts it to the configured gateway
+func (d *webhookDriver) Send(t *mail.Transmission) (mail.Response, error) {
+	return d.sendMessage(&message{Transmission: t, Attachments: partsFromAttachments(t.Attachments)})
+}
+
+// sendMessage renders the body template for a message and posts it
+func (d *webhookDriver) sendMessage(msg *message) (mail.Response, error) {
+	if err := msg.Validate(); err != nil {
+		return mail.Response{}, err
+	}
+
+	body, err := d.render(msg)
+	if err != nil {
+		return mail.Response{}, err
+	}
+
+	req, err := http.NewRequest(d.cfg.GetMethod(), d.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return mail.Response{}, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range d.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if d.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.cfg.Token)
+	} else if d.cfg.Username != "" {
+		req.SetBasicAuth(d.cfg.Username, d.cfg.Password)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return mail.Response{}, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return mail.Response{StatusCode: resp.StatusCode}, fmt.Errorf("failed to read webhook response: %w", err)
+	}
+
+	response := mail.Response{
+		StatusCode: resp.StatusCode,
+		Body:       respBody,
+		Headers:    resp.Header,
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	// Pick up a message ID if the gateway returns one
+	var meta struct {
+		ID        string `json:"id"`
+		MessageID string `json:"message_id"`
+		Message   string `json:"message"`
+	}
+	if json.Unmarshal(respBody, &meta) == nil {
+		response.ID = meta.ID
+		if response.ID == "" {
+			response.ID = meta.MessageID
+		}
+		response.Message = meta.Message
+	}
+
+	return response, nil
+}
+
+// render executes the body template for a message
+func (d *webhookDriver) render(msg *message) ([]byte, error) {
+	t := msg.Transmission
+	payload := webhookPayload{
+		From:        d.from,
+		FromName:    d.fromName,
+		To:          nonNil(t.Recipients),
+		CC:          nonNil(t.CC),
+		BCC:         nonNil(t.BCC),
+		Subject:     t.Subject,
+		HTML:        t.HTML,
+		Text:        t.PlainText,
+		Headers:     t.Headers,
+		Attachments: make([]webhookAttachment, 0, len(msg.Attachments)),
+		Inline:      make([]webhookAttachment, 0, len(msg.Inline)),
+	}
+	if payload.Headers == nil {
+		payload.Headers = map[string]string{}
+	}
+
+	for _, a := range msg.Attachments {
+		attachment, err := newWebhookAttachment(a)
+		if err != nil {
+			return nil, err
+		}
+		payload.Attachments = append(payload.Attachments, attachment)
+	}
+
+	for _, img := range msg.Inline {
+		attachment, err := newWebhookAttachment(img)
+		if err != nil {
+			return nil, err
+		}
+		payload.Inline = append(payload.Inline, attachment)
+	}
+
+	var buf bytes.Buffer
+	if err := d.tmpl.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("failed to render webhook body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// newWebhookAttachment reads and encodes a part for the payload
+func newWebhookAttachment(p filePart) (webhookAttachment, error) {
+	data, err := p.readAll()
+	if err != nil {
+		return webhookAttachment{}, fmt.Errorf("failed to read %s: %w", p.Filename, err)
+	}
+	return webhookAttachment{
+		Filename:    p.Filename,
+		ContentType: p.ContentType,
+		Content:     base64.StdEncoding.EncodeToString(data),
+		ContentID:   p.ContentID,
+	}, nil
+}
+
+// toJSON encodes a value as JSON for use inside templates
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// nonNil returns an empty slice instead of nil so templates render [] not null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
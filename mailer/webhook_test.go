@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mailgloss/config"
+
+	"github.com/ainsleyclark/go-mail/mail"
+)
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		bodyTemplate string
+		status       int
+		wantErr      bool
+		check        func(t *testing.T, r *http.Request, body []byte)
+	}{
+		{
+			name:   "default body",
+			status: http.StatusOK,
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				var payload webhookPayload
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Fatalf("body is not valid JSON: %v\n%s", err, body)
+				}
+				if payload.From != "sender@example.com" || payload.Subject != "Hello" {
+					t.Errorf("unexpected payload: %+v", payload)
+				}
+				if len(payload.Attachments) != 1 || payload.Attachments[0].Content != "aGVsbG8=" {
+					t.Errorf("unexpected attachments: %+v", payload.Attachments)
+				}
+				if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+					t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+				}
+			},
+		},
+		{
+			name:         "custom template",
+			bodyTemplate: `{"recipient": {{json (index .To 0)}}, "title": {{json .Subject}}}`,
+			status:       http.StatusAccepted,
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				want := `{"recipient": "to@example.com", "title": "Hello"}`
+				if string(body) != want {
+					t.Errorf("body = %s, want %s", body, want)
+				}
+			},
+		},
+		{
+			name:    "gateway error",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if tt.check != nil {
+					tt.check(t, r, body)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"id": "msg-1"}`))
+			}))
+			defer server.Close()
+
+			driver, err := newWebhook(&config.ProviderConfig{
+				FromAddress: "sender@example.com",
+				Webhook: &config.WebhookConfig{
+					URL:          server.URL,
+					Username:     "user",
+					Password:     "secret",
+					BodyTemplate: tt.bodyTemplate,
+				},
+			})
+			if err != nil {
+				t.Fatalf("newWebhook() error = %v", err)
+			}
+
+			resp, err := driver.Send(&mail.Transmission{
+				Recipients:  []string{"to@example.com"},
+				Subject:     "Hello",
+				HTML:        "<p>Hi</p>",
+				PlainText:   "Hi",
+				Attachments: []mail.Attachment{{Filename: "a.txt", Bytes: []byte("hello")}},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && resp.ID != "msg-1" {
+				t.Errorf("Send() ID = %q, want msg-1", resp.ID)
+			}
+		})
+	}
+}
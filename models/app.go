@@ -2,11 +2,14 @@ package models
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"mailgloss/config"
+	"mailgloss/events"
+	"mailgloss/logger"
 	"mailgloss/mailer"
 	"mailgloss/storage"
 	"mailgloss/ui"
@@ -18,9 +21,12 @@ type Tab int
 const (
 	TabCompose Tab = iota
 	TabHistory
+	TabStats
 	TabContacts
 	TabTemplates
 	TabSettings
+
+	numTabs = int(TabSettings) + 1
 )
 
 // AppModel is the main application model
@@ -28,6 +34,7 @@ type AppModel struct {
 	activeTab      Tab
 	composeModel   ComposeModel
 	historyModel   HistoryModel
+	statsModel     StatsModel
 	contactsModel  ContactsModel
 	templatesModel TemplatesModel
 	settingsModel  SettingsModel
@@ -35,11 +42,15 @@ type AppModel struct {
 	history        *storage.History
 	contacts       *storage.Contacts
 	templates      *storage.Templates
+	snapshots      *storage.Snapshots // nil if the attachments directory is unavailable
+	sendQueue      *sendQueue
+	receiver       *events.Receiver // nil unless delivery events are enabled
 	width          int
 	height         int
 	statusMsg      string
 	errorMsg       string
 	quitting       bool
+	confirmQuit    bool // q was pressed with messages in the send queue
 }
 
 // NewAppModel creates a new app model
@@ -76,9 +87,17 @@ func NewAppModel() (*AppModel, error) {
 		return nil, fmt.Errorf("failed to load templates: %w", err)
 	}
 
+	// Attachment snapshots are optional, sending works without them
+	snapshots, err := storage.NewSnapshots(configDir, limits.MaxSnapshotStorageMB, limits.SnapshotRetentionDays)
+	if err != nil {
+		logger.Warn("Attachment snapshots disabled", "error", err)
+		snapshots = nil
+	}
+
 	// Create models
 	composeModel := NewComposeModel(cfg, contacts, templates)
-	historyModel := NewHistoryModel(hist)
+	historyModel := NewHistoryModel(hist, snapshots)
+	statsModel := NewStatsModel(hist)
 	contactsModel := NewContactsModel(contacts)
 	templatesModel := NewTemplatesModel(templates)
 	settingsModel := NewSettingsModel(cfg)
@@ -89,10 +108,11 @@ func NewAppModel() (*AppModel, error) {
 		activeTab = TabSettings
 	}
 
-	return &AppModel{
+	m := &AppModel{
 		activeTab:      activeTab,
 		composeModel:   composeModel,
 		historyModel:   historyModel,
+		statsModel:     statsModel,
 		contactsModel:  contactsModel,
 		templatesModel: templatesModel,
 		settingsModel:  settingsModel,
@@ -100,12 +120,23 @@ func NewAppModel() (*AppModel, error) {
 		history:        hist,
 		contacts:       contacts,
 		templates:      templates,
-	}, nil
+		snapshots:      snapshots,
+		sendQueue:      &sendQueue{},
+	}
+	m.startReceiver()
+	return m, nil
+}
+
+// quit stops the delivery event receiver and ends the program
+func (m AppModel) quit() (tea.Model, tea.Cmd) {
+	m.quitting = true
+	m.stopReceiver()
+	return m, tea.Quit
 }
 
 // Init initializes the app model
 func (m AppModel) Init() tea.Cmd {
-	return nil
+	return waitForDeliveryEvents(m.receiver)
 }
 
 // Update handles messages for the app model
@@ -115,12 +146,24 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// A second q confirms quitting with queued messages, any other key
+		// goes on as usual
+		if m.confirmQuit {
+			m.confirmQuit = false
+			m.errorMsg = ""
+			if msg.String() == "q" {
+				return m.quit()
+			}
+		}
+
 		// Only handle global shortcuts if we're not in an active input/textarea
 		isTyping := false
 		switch m.activeTab {
 		case TabCompose:
 			// Check if we're in an input field or textarea (not on provider selector or send button)
-			isTyping = m.composeModel.FocusIndex > providerSelector && m.composeModel.FocusIndex < sendButton
+			isTyping = m.composeModel.FocusIndex >= inputOffset && m.composeModel.FocusIndex <= bodyInput
+			// The preview closes with q
+			isTyping = isTyping || m.composeModel.showPreview
 		case TabContacts:
 			// Check if we're in the add/edit view
 			isTyping = (m.contactsModel.currentView == ContactsViewAdd || m.contactsModel.currentView == ContactsViewEdit) &&
@@ -129,6 +172,8 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Check if we're in the add/edit view
 			isTyping = (m.templatesModel.currentView == TemplatesViewAdd || m.templatesModel.currentView == TemplatesViewEdit) &&
 				m.templatesModel.FocusIndex >= templateName && m.templatesModel.FocusIndex < templateSaveButton
+		case TabHistory:
+			isTyping = m.historyModel.searching
 		case TabSettings:
 			// Check if we're in an input field (not in list view or on buttons)
 			isTyping = m.settingsModel.currentView != SettingsViewList &&
@@ -138,16 +183,27 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		switch msg.String() {
 		case "ctrl+c":
-			m.quitting = true
-			return m, tea.Quit
+			return m.quit()
 
 		case "q":
 			if !isTyping {
-				m.quitting = true
-				return m, tea.Quit
+				// Quitting drops queued messages, so it needs confirming
+				if m.sendQueue.active() {
+					m.confirmQuit = true
+					m.statusMsg = ""
+					m.errorMsg = "Messages are still waiting to be sent - press q again to quit and drop them, any other key to stay"
+					return m, nil
+				}
+				if m.sendQueue.lastHeld() != nil {
+					m.confirmQuit = true
+					m.statusMsg = ""
+					m.errorMsg = "Cancelled messages are kept in the queue - press q again to quit and discard them, any other key to stay"
+					return m, nil
+				}
+				return m.quit()
 			}
 
-		// Function keys F1-F6 should always work, even when typing
+		// Function keys F1-F7 should always work, even when typing
 		case "f1":
 			m.activeTab = TabCompose
 			m.statusMsg = ""
@@ -161,39 +217,51 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case "f3":
-			m.activeTab = TabContacts
+			m.activeTab = TabStats
 			m.statusMsg = ""
 			m.errorMsg = ""
 			return m, nil
 
 		case "f4":
-			m.activeTab = TabTemplates
+			m.activeTab = TabContacts
 			m.statusMsg = ""
 			m.errorMsg = ""
 			return m, nil
 
 		case "f5":
-			m.activeTab = TabSettings
+			m.activeTab = TabTemplates
 			m.statusMsg = ""
 			m.errorMsg = ""
 			return m, nil
 
 		case "f6":
-			// F6 cycles to the next tab
-			m.activeTab = Tab((int(m.activeTab) + 1) % 5)
+			m.activeTab = TabSettings
+			m.statusMsg = ""
+			m.errorMsg = ""
+			return m, nil
+
+		case "f7":
+			// F7 cycles to the next tab
+			m.activeTab = Tab((int(m.activeTab) + 1) % numTabs)
 			m.statusMsg = ""
 			m.errorMsg = ""
 			return m, nil
 
+		case "ctrl+x":
+			// Cancel the most recently queued message while it waits
+			if m.cancelSend() {
+				return m, nil
+			}
+
 		// Ctrl+Tab / Ctrl+Shift+Tab should also always work
 		case "ctrl+tab":
-			m.activeTab = Tab((int(m.activeTab) + 1) % 5)
+			m.activeTab = Tab((int(m.activeTab) + 1) % numTabs)
 			m.statusMsg = ""
 			m.errorMsg = ""
 			return m, nil
 
 		case "ctrl+shift+tab":
-			m.activeTab = Tab((int(m.activeTab) + 4) % 5)
+			m.activeTab = Tab((int(m.activeTab) + numTabs - 1) % numTabs)
 			m.statusMsg = ""
 			m.errorMsg = ""
 			return m, nil
@@ -202,94 +270,88 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		// The charts fit the width even when the tab opens later
+		m.statsModel, _ = m.statsModel.Update(msg)
 
-	case SendEmailMsg:
-		// Handle email sending
+	case PreviewEmailMsg:
+		// Build the message the way it will be sent, without sending it
 		m.statusMsg = ""
 		m.errorMsg = ""
 
-		// Get provider config
 		if msg.ProviderName == "" {
 			m.errorMsg = "Please select a provider"
-			m.composeModel.isSending = false
 			return m, nil
 		}
 
-		providerConfig, err := m.config.GetProvider(msg.ProviderName)
+		ml, err := m.newMailer(msg.ProviderName, msg.Data)
 		if err != nil {
-			m.errorMsg = fmt.Sprintf("Provider error: %v", err)
-			m.composeModel.isSending = false
+			m.errorMsg = fmt.Sprintf("Failed to initialize mailer: %v", err)
 			return m, nil
 		}
 
-		// If a custom From address is provided, create a modified config with that address
-		if msg.Data.From != "" {
-			// Clone the provider config to avoid modifying the original
-			modifiedConfig := *providerConfig
-			modifiedConfig.FromAddress = msg.Data.From
-			// Override FromName if provided, otherwise keep config default
-			if msg.Data.FromName != "" {
-				modifiedConfig.FromName = msg.Data.FromName
-			}
-			providerConfig = &modifiedConfig
+		preview, err := ml.Preview(mailerData(msg.Data))
+		if err != nil {
+			m.errorMsg = fmt.Sprintf("Preview failed: %v", err)
+			return m, nil
 		}
+		m.composeModel.OpenPreview(preview)
+		return m, nil
 
-		// Create mailer for this provider
-		limits := m.config.GetLimits()
-		ml, err := mailer.NewWithLimits(providerConfig, limits.MaxAttachmentSizeMB)
-		if err != nil {
-			m.errorMsg = fmt.Sprintf("Failed to initialize mailer: %v", err)
+	case SendEmailMsg:
+		// Handle email sending
+		m.statusMsg = ""
+		m.errorMsg = ""
+
+		// Get provider config
+		if msg.ProviderName == "" {
+			m.errorMsg = "Please select a provider"
 			m.composeModel.isSending = false
 			return m, nil
 		}
 
-		// Send email
-		emailData := mailer.EmailData{
-			From:        msg.Data.From,
-			FromName:    msg.Data.FromName,
-			To:          msg.Data.To,
-			CC:          msg.Data.CC,
-			BCC:         msg.Data.BCC,
-			Subject:     msg.Data.Subject,
-			Body:        msg.Data.Body,
-			Attachments: msg.Data.Attachments,
-		}
+		// Queue the email, it is sent in the background once the undo
+		// delay has passed
+		return m, m.queueSend(msg)
 
-		err = ml.Send(emailData)
-
-		// Save to history
-		historyEntry := storage.SentEmail{
-			From:         msg.Data.From,
-			To:           msg.Data.To,
-			CC:           msg.Data.CC,
-			BCC:          msg.Data.BCC,
-			Subject:      msg.Data.Subject,
-			Body:         msg.Data.Body,
-			Attachments:  msg.Data.Attachments,
-			Provider:     ml.GetProviderType(),
-			ProviderName: msg.ProviderName,
-		}
+	case sendTickMsg:
+		m.sendQueue.ticking = false
+		return m, m.startDueSends()
 
-		if err != nil {
-			m.errorMsg = fmt.Sprintf("Failed to send email: %v", err)
-			historyEntry.Status = "failed"
-			historyEntry.Error = err.Error()
-			m.composeModel.isSending = false
+	case sendDoneMsg:
+		job := m.sendQueue.remove(msg.id)
+		if job == nil {
+			return m, nil
+		}
+		m.recordSend(job.send, msg.result, msg.err, msg.snapshots)
+		if msg.err != nil && len(msg.result.Batches) > msg.result.Failed() {
+			// Some batches went out, so resending everything would send
+			// duplicates. History resends only to the failed batches.
+			m.statusMsg = ""
+			m.errorMsg = fmt.Sprintf("Partially sent: %v. Press r on it in History to retry the failed recipients", msg.err)
+		} else if msg.err != nil {
+			m.statusMsg = ""
+			m.errorMsg = fmt.Sprintf("Failed to send email: %v", msg.err)
+			// Return the message to Compose unless a new one is in progress,
+			// it can still be resent from History
+			if m.composeModel.isEmpty() {
+				m.restoreDraft(job.draft)
+			}
 		} else {
+			m.errorMsg = ""
 			m.statusMsg = "Email sent successfully!"
-			historyEntry.Status = "success"
-			m.composeModel.Clear()
-			m.composeModel.isSending = false
+			if msg.result.ProviderName != job.send.ProviderName {
+				m.statusMsg = fmt.Sprintf("Email sent successfully via %s!", msg.result.ProviderName)
+			}
 		}
 
-		// Save to history regardless of success/failure
-		m.history.Add(historyEntry)
-
 		// Refresh history view
 		return m, func() tea.Msg {
 			return RefreshHistoryMsg{}
 		}
 
+	case DeliveryEventsMsg:
+		return m, m.recordDeliveryEvents(msg.Events)
+
 	case EmailValidationErrorMsg:
 		// Handle email validation errors
 		m.statusMsg = ""
@@ -300,10 +362,38 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case ConfigSavedMsg:
 		// Reload config and update compose model with new provider list
 		if cfg, err := config.Load(); err == nil {
+			cmds = append(cmds, m.restartReceiver(cfg))
 			m.config = cfg
 			m.composeModel.UpdateProviders(cfg)
 		}
 
+	case attachmentInspectedMsg, fileIndexMsg:
+		// Deliver to compose even if the user switched tabs while inspecting
+		// or indexing files
+		m.composeModel, cmd = m.composeModel.Update(msg)
+		return m, cmd
+
+	case ConnectionTestMsg:
+		// Deliver to settings even if the user switched tabs while testing
+		m.settingsModel, cmd = m.settingsModel.Update(msg)
+		return m, cmd
+
+	case ResendEmailMsg:
+		// Load the email into compose for review before sending again,
+		// using the stored copies of files that were moved or changed
+		email, restored := m.restoreSnapshots(msg.Email.RetryRecipients())
+		inspect := m.composeModel.LoadEmail(email)
+		m.activeTab = TabCompose
+		m.statusMsg = "Loaded email from history - review and send"
+		if msg.Email.Status == "partial" {
+			m.statusMsg = "Loaded the failed recipients of a partially sent email - review and send"
+		}
+		if restored > 0 {
+			m.statusMsg = fmt.Sprintf("Loaded email from history with %d stored attachment(s) - review and send", restored)
+		}
+		m.errorMsg = ""
+		return m, inspect
+
 	case RefreshHistoryMsg:
 		// Pass to history model
 		m.historyModel, cmd = m.historyModel.Update(msg)
@@ -320,6 +410,10 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.historyModel, cmd = m.historyModel.Update(msg)
 		cmds = append(cmds, cmd)
 
+	case TabStats:
+		m.statsModel, cmd = m.statsModel.Update(msg)
+		cmds = append(cmds, cmd)
+
 	case TabContacts:
 		m.contactsModel, cmd = m.contactsModel.Update(msg)
 		cmds = append(cmds, cmd)
@@ -336,6 +430,221 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// newMailer creates a mailer for a provider or failover chain, applying the
+// From override of the email to every provider involved
+func (m AppModel) newMailer(name string, data EmailData) (*mailer.Mailer, error) {
+	if chain, err := m.config.GetChain(name); err == nil {
+		members := make([]*mailer.Mailer, 0, len(chain.Providers))
+		for _, providerName := range chain.Providers {
+			ml, err := m.newMailer(providerName, data)
+			if err != nil {
+				return nil, fmt.Errorf("provider '%s': %w", providerName, err)
+			}
+			members = append(members, ml)
+		}
+		return mailer.NewFailover(name, members)
+	}
+
+	providerConfig, err := m.config.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// If a custom From address is provided, create a modified config with that address
+	if data.From != "" {
+		// Clone the provider config to avoid modifying the original
+		modifiedConfig := *providerConfig
+		modifiedConfig.FromAddress = data.From
+		// Override FromName if provided, otherwise keep config default
+		if data.FromName != "" {
+			modifiedConfig.FromName = data.FromName
+		}
+		providerConfig = &modifiedConfig
+	}
+
+	limits := m.config.GetLimits()
+	return mailer.NewWithLimits(providerConfig, limits.MaxAttachmentSizeMB)
+}
+
+// mailerData converts compose data to the mailer's email data
+func mailerData(data EmailData) mailer.EmailData {
+	emailData := mailer.EmailData{
+		From:            data.From,
+		FromName:        data.FromName,
+		ReplyTo:         data.ReplyTo,
+		Headers:         data.Headers,
+		Priority:        data.Priority,
+		ReadReceipt:     data.ReadReceipt,
+		PGPSign:         data.PGPSign,
+		PGPEncrypt:      data.PGPEncrypt,
+		PGPKeys:         data.PGPKeys,
+		SMIMESign:       data.SMIMESign,
+		SMIMEEncrypt:    data.SMIMEEncrypt,
+		SMIMECerts:      data.SMIMECerts,
+		To:              data.To,
+		CC:              data.CC,
+		BCC:             data.BCC,
+		Subject:         data.Subject,
+		Body:            data.Body,
+		Signature:       data.Signature,
+		HTMLSignature:   data.HTMLSignature,
+		Attachments:     data.Attachments,
+		InlineImages:    data.InlineImages,
+		ArchivePassword: data.ArchivePassword,
+	}
+	if len(data.Bundle) > 0 {
+		emailData.Archives = []mailer.Archive{{Name: bundleName, Paths: data.Bundle}}
+	}
+	return emailData
+}
+
+// recordSend saves a sent or failed email to history
+func (m AppModel) recordSend(msg SendEmailMsg, result *mailer.Result, err error, snapshots []storage.AttachmentSnapshot) {
+	historyEntry := storage.SentEmail{
+		From:           msg.Data.From,
+		To:             msg.Data.To,
+		CC:             msg.Data.CC,
+		BCC:            msg.Data.BCC,
+		Subject:        msg.Data.Subject,
+		Body:           msg.Data.Body,
+		Attachments:    msg.Data.Attachments,
+		InlineImages:   msg.Data.InlineImages,
+		Bundle:         msg.Data.Bundle,
+		ReplyTo:        msg.Data.ReplyTo,
+		Headers:        msg.Data.Headers,
+		Priority:       string(msg.Data.Priority),
+		ReadReceipt:    msg.Data.ReadReceipt,
+		PGPSigned:      msg.Data.PGPSign,
+		PGPEncrypted:   msg.Data.PGPEncrypt,
+		SMIMESigned:    msg.Data.SMIMESign,
+		SMIMEEncrypted: msg.Data.SMIMEEncrypt,
+		Template:       msg.Data.Template,
+		Provider:       result.ProviderType,
+		ProviderName:   msg.ProviderName,
+		DeliveredBy:    result.ProviderName,
+		MessageID:      result.MessageID,
+		StatusCode:     result.StatusCode,
+		Response:       result.Body,
+	}
+
+	// Keep the individual attempts for failover chains
+	if m.config.IsChain(msg.ProviderName) {
+		for _, attempt := range result.Attempts {
+			historyEntry.Attempts = append(historyEntry.Attempts, storage.DeliveryAttempt{
+				ProviderName: attempt.ProviderName,
+				Error:        attempt.Error,
+				MessageID:    attempt.MessageID,
+				StatusCode:   attempt.StatusCode,
+				Response:     attempt.Body,
+			})
+		}
+	}
+
+	// Keep the outcome of each batch when the recipients were split
+	for _, batch := range result.Batches {
+		entry := storage.DeliveryBatch{
+			Recipients:   batch.Recipients,
+			ProviderName: batch.ProviderName,
+			Error:        batch.Error,
+			MessageID:    batch.MessageID,
+		}
+		if batch.Error != "" {
+			entry.FailedBCC = batch.BCC
+		}
+		historyEntry.Batches = append(historyEntry.Batches, entry)
+	}
+
+	switch {
+	case err == nil:
+		historyEntry.Status = "success"
+	case len(result.Batches) > result.Failed():
+		historyEntry.Status = "partial"
+		historyEntry.Error = err.Error()
+	default:
+		historyEntry.Status = "failed"
+		historyEntry.Error = err.Error()
+	}
+
+	historyEntry.Snapshots = snapshots
+
+	// Save to history regardless of success/failure
+	m.history.Add(historyEntry)
+}
+
+// snapshotAttachments stores copies of the files of an email for history.
+// It runs in the send command just before sending, so the copies are what
+// was sent. Files that can't be copied are left out rather than failing
+// the send.
+func snapshotAttachments(store *storage.Snapshots, data EmailData) []storage.AttachmentSnapshot {
+	attachments := append(append([]string{}, data.Attachments...), data.Bundle...)
+	if store == nil || len(attachments)+len(data.InlineImages) == 0 {
+		return nil
+	}
+
+	var snapshots []storage.AttachmentSnapshot
+	for _, files := range []struct {
+		paths  []string
+		inline bool
+	}{{attachments, false}, {data.InlineImages, true}} {
+		for _, path := range files.paths {
+			// Folders are zipped on send and not kept
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				continue
+			}
+			snap, err := store.Store(path, files.inline)
+			if err != nil {
+				logger.Warn("Failed to snapshot attachment", "path", path, "error", err)
+				continue
+			}
+			snapshots = append(snapshots, snap)
+		}
+	}
+
+	if err := store.Prune(); err != nil {
+		logger.Warn("Failed to prune attachment snapshots", "error", err)
+	}
+	return snapshots
+}
+
+// restoreSnapshots replaces the paths of files that were moved or changed
+// since the email was sent with their stored copies
+func (m AppModel) restoreSnapshots(email storage.SentEmail) (storage.SentEmail, int) {
+	if m.snapshots == nil || len(email.Snapshots) == 0 {
+		return email, 0
+	}
+
+	email.Attachments = append([]string{}, email.Attachments...)
+	email.InlineImages = append([]string{}, email.InlineImages...)
+	email.Bundle = append([]string{}, email.Bundle...)
+	restored := 0
+	for _, snap := range email.Snapshots {
+		if m.snapshots.Matches(snap) {
+			continue
+		}
+		path, err := m.snapshots.Restore(snap)
+		if err != nil {
+			logger.Warn("Failed to restore attachment snapshot", "path", snap.Path, "error", err)
+			continue
+		}
+
+		lists := [][]string{email.Attachments, email.Bundle}
+		if snap.Inline {
+			lists = [][]string{email.InlineImages}
+		}
+	replace:
+		for _, paths := range lists {
+			for i, p := range paths {
+				if p == snap.Path {
+					paths[i] = path
+					restored++
+					break replace
+				}
+			}
+		}
+	}
+	return email, restored
+}
+
 // View renders the app model
 func (m AppModel) View() string {
 	if m.quitting {
@@ -343,7 +652,7 @@ func (m AppModel) View() string {
 	}
 
 	// Render tabs with icons
-	tabs := []string{"✉ Compose", "📜 History", "👤 Contacts", "📝 Templates", "⚙ Settings"}
+	tabs := []string{"✉ Compose", "📜 History", "📊 Stats", "👤 Contacts", "📝 Templates", "⚙ Settings"}
 	tabBar := ui.RenderTabs(tabs, int(m.activeTab))
 
 	// Render active tab content
@@ -353,6 +662,8 @@ func (m AppModel) View() string {
 		content = m.composeModel.View()
 	case TabHistory:
 		content = m.historyModel.View()
+	case TabStats:
+		content = m.statsModel.View()
 	case TabContacts:
 		content = m.contactsModel.View()
 	case TabTemplates:
@@ -361,16 +672,19 @@ func (m AppModel) View() string {
 		content = m.settingsModel.View()
 	}
 
-	// Render status messages
+	// Render status messages, after the messages waiting to be sent
 	var status string
+	if len(m.sendQueue.jobs) > 0 {
+		status = "\n" + m.sendQueue.View()
+	}
 	if m.statusMsg != "" {
-		status = "\n" + ui.SuccessStyle.Render(m.statusMsg)
+		status += "\n" + ui.SuccessStyle.Render(m.statusMsg)
 	} else if m.errorMsg != "" {
-		status = "\n" + ui.ErrorStyle.Render(m.errorMsg)
+		status += "\n" + ui.ErrorStyle.Render(m.errorMsg)
 	}
 
 	// Render footer
-	footer := ui.HelpStyle.Render("Press q or Ctrl+C to quit | F1-F6 switch tabs; Ctrl+Tab/Ctrl+Shift+Tab also switch tabs")
+	footer := ui.HelpStyle.Render("Press q or Ctrl+C to quit | F1-F6 switch tabs, F7 next tab; Ctrl+Tab/Ctrl+Shift+Tab also switch tabs")
 
 	return tabBar + "\n\n" + content + status + "\n\n" + footer
 }
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"mailgloss/config"
+)
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+	wordPattern        = regexp.MustCompile(`\pL+`)
+	lettersPattern     = regexp.MustCompile(`^\pL*`)
+)
+
+// SendCheck inspects an email before it is sent. Run returns a message for
+// each problem found; the level decides whether the user may send anyway.
+type SendCheck struct {
+	Name  string
+	Level config.CheckLevel // Default level, overridden by send_checks.levels
+	Run   func(data EmailData, cfg *config.SendChecks) []string
+}
+
+// CheckFinding is a problem found by a send check
+type CheckFinding struct {
+	Check   string
+	Message string
+	Block   bool
+}
+
+// SendChecksMsg is sent instead of SendEmailMsg when send checks found
+// problems, so they can be confirmed or fixed first
+type SendChecksMsg struct {
+	Send     SendEmailMsg
+	Findings []CheckFinding
+}
+
+// sendChecks run in order before every send
+var sendChecks = []SendCheck{
+	{Name: "empty_subject", Level: config.CheckBlock, Run: checkEmptySubject},
+	{Name: "placeholders", Level: config.CheckWarn, Run: checkPlaceholders},
+	{Name: "forgotten_attachment", Level: config.CheckWarn, Run: checkForgottenAttachment},
+	{Name: "visible_recipients", Level: config.CheckWarn, Run: checkVisibleRecipients},
+	{Name: "duplicate_recipients", Level: config.CheckWarn, Run: checkDuplicateRecipients},
+}
+
+// RegisterSendCheck adds a check to run before every send
+func RegisterSendCheck(check SendCheck) {
+	sendChecks = append(sendChecks, check)
+}
+
+// runSendChecks runs the enabled send checks on an email
+func runSendChecks(data EmailData, cfg *config.SendChecks) []CheckFinding {
+	var findings []CheckFinding
+	for _, check := range sendChecks {
+		level := cfg.Level(check.Name, check.Level)
+		if level == config.CheckOff {
+			continue
+		}
+		for _, message := range check.Run(data, cfg) {
+			findings = append(findings, CheckFinding{
+				Check:   check.Name,
+				Message: message,
+				Block:   level == config.CheckBlock,
+			})
+		}
+	}
+	return findings
+}
+
+// blocksSend reports whether any finding prevents sending
+func blocksSend(findings []CheckFinding) bool {
+	for _, f := range findings {
+		if f.Block {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEmptySubject flags a missing or blank subject
+func checkEmptySubject(data EmailData, _ *config.SendChecks) []string {
+	if strings.TrimSpace(data.Subject) == "" {
+		return []string{"The subject is empty"}
+	}
+	return nil
+}
+
+// checkPlaceholders flags template placeholders left without a value
+func checkPlaceholders(data EmailData, _ *config.SendChecks) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, text := range []string{data.Subject, data.Body} {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, "{{"+m[1]+"}}")
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return []string{"Unfilled template placeholders: " + strings.Join(names, ", ")}
+}
+
+// checkForgottenAttachment flags a message that mentions an attachment but
+// has none. Quoted lines and the signature are ignored.
+func checkForgottenAttachment(data EmailData, cfg *config.SendChecks) []string {
+	if len(data.Attachments)+len(data.Bundle) > 0 || len(cfg.AttachmentKeywords) == 0 {
+		return nil
+	}
+
+	var lines []string
+	body := strings.TrimSuffix(data.Body, data.Signature)
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			lines = append(lines, line)
+		}
+	}
+
+	for _, text := range []string{data.Subject, strings.Join(lines, "\n")} {
+		if word := findKeyword(text, cfg.AttachmentKeywords); word != "" {
+			return []string{fmt.Sprintf("The message mentions %q but has no attachments", word)}
+		}
+	}
+	return nil
+}
+
+// findKeyword returns the first word of text that starts with one of the
+// keywords, ignoring case, or ""
+func findKeyword(text string, keywords []string) string {
+	for _, loc := range wordPattern.FindAllStringIndex(text, -1) {
+		rest := text[loc[0]:]
+		for _, keyword := range keywords {
+			if keyword == "" || len(rest) < len(keyword) || !strings.EqualFold(rest[:len(keyword)], keyword) {
+				continue
+			}
+			return rest[:len(keyword)] + lettersPattern.FindString(rest[len(keyword):])
+		}
+	}
+	return ""
+}
+
+// checkVisibleRecipients suggests Bcc when many recipients would see each
+// other's addresses
+func checkVisibleRecipients(data EmailData, cfg *config.SendChecks) []string {
+	visible := len(data.To) + len(data.CC)
+	if cfg.MaxVisibleRecipients <= 0 || visible <= cfg.MaxVisibleRecipients {
+		return nil
+	}
+	return []string{fmt.Sprintf("%d recipients in To and Cc will see each other's addresses, consider Bcc", visible)}
+}
+
+// checkDuplicateRecipients flags addresses listed more than once across
+// To, Cc and Bcc
+func checkDuplicateRecipients(data EmailData, _ *config.SendChecks) []string {
+	fields := map[string][]string{}
+	var order []string
+	for _, list := range []struct {
+		name  string
+		addrs []string
+	}{{"To", data.To}, {"Cc", data.CC}, {"Bcc", data.BCC}} {
+		for _, addr := range list.addrs {
+			key := strings.ToLower(strings.TrimSpace(addr))
+			if parsed, err := mail.ParseAddress(addr); err == nil {
+				key = strings.ToLower(parsed.Address)
+			}
+			if _, ok := fields[key]; !ok {
+				order = append(order, key)
+			}
+			fields[key] = append(fields[key], list.name)
+		}
+	}
+
+	var messages []string
+	for _, addr := range order {
+		if len(fields[addr]) > 1 {
+			messages = append(messages, fmt.Sprintf("%s is listed more than once (%s)", addr, strings.Join(fields[addr], ", ")))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"mailgloss/config"
+)
+
+func TestCheckForgottenAttachment(t *testing.T) {
+	cfg := &config.SendChecks{AttachmentKeywords: config.DefaultAttachmentKeywords}
+	tests := []struct {
+		name string
+		data EmailData
+		want []string
+	}{
+		{
+			name: "mentioned in the body",
+			data: EmailData{Subject: "Report", Body: "Please find the report Attached."},
+			want: []string{`The message mentions "Attached" but has no attachments`},
+		},
+		{
+			name: "mentioned in the subject",
+			data: EmailData{Subject: "Anhang: Rechnung", Body: "Hallo"},
+			want: []string{`The message mentions "Anhang" but has no attachments`},
+		},
+		{
+			name: "non-ASCII keyword",
+			data: EmailData{Subject: "Rechnung", Body: "Die Rechnung ist angehängt."},
+			want: []string{`The message mentions "angehängt" but has no attachments`},
+		},
+		{
+			name: "with an attachment",
+			data: EmailData{Body: "See attached", Attachments: []string{"report.pdf"}},
+		},
+		{
+			name: "with a bundle",
+			data: EmailData{Body: "See attached", Bundle: []string{"report.pdf"}},
+		},
+		{
+			name: "keyword inside a word",
+			data: EmailData{Body: "The reattachment is scheduled"},
+		},
+		{
+			name: "quoted line",
+			data: EmailData{Body: "Thanks!\n> I attached the file"},
+		},
+		{
+			name: "signature",
+			data: EmailData{Body: "Hi\n-- \nDocuments are enclosed by post", Signature: "-- \nDocuments are enclosed by post"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkForgottenAttachment(tt.data, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkForgottenAttachment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	tests := []struct {
+		name string
+		data EmailData
+		want []string
+	}{
+		{
+			name: "none",
+			data: EmailData{Subject: "Hello", Body: "Hi Ada, {not a placeholder}"},
+		},
+		{
+			name: "subject and body, reported once",
+			data: EmailData{Subject: "Offer for {{ company }}", Body: "Hi {{name}}, {{company}} {{name}}"},
+			want: []string{"Unfilled template placeholders: {{company}}, {{name}}"},
+		},
+		{
+			name: "empty name",
+			data: EmailData{Body: "Hi {{}}"},
+			want: []string{"Unfilled template placeholders: {{}}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkPlaceholders(tt.data, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkPlaceholders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRecipients(t *testing.T) {
+	cfg := &config.SendChecks{MaxVisibleRecipients: 2}
+	tests := []struct {
+		name      string
+		data      EmailData
+		visible   []string
+		duplicate []string
+	}{
+		{
+			name: "few recipients",
+			data: EmailData{To: []string{"ada@example.com"}, CC: []string{"bob@example.com"}},
+		},
+		{
+			name:    "too many visible",
+			data:    EmailData{To: []string{"ada@example.com", "bob@example.com"}, CC: []string{"eve@example.com"}},
+			visible: []string{"3 recipients in To and Cc will see each other's addresses, consider Bcc"},
+		},
+		{
+			name: "many in bcc",
+			data: EmailData{To: []string{"me@example.com"}, BCC: []string{"ada@example.com", "bob@example.com", "eve@example.com"}},
+		},
+		{
+			name:      "duplicate across fields",
+			data:      EmailData{To: []string{"Ada <ADA@example.com>"}, BCC: []string{"ada@example.com"}},
+			duplicate: []string{"ada@example.com is listed more than once (To, Bcc)"},
+		},
+		{
+			name:      "duplicate in one field",
+			data:      EmailData{To: []string{"bob@example.com", " bob@example.com"}},
+			duplicate: []string{"bob@example.com is listed more than once (To, To)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkVisibleRecipients(tt.data, cfg); !reflect.DeepEqual(got, tt.visible) {
+				t.Errorf("checkVisibleRecipients() = %q, want %q", got, tt.visible)
+			}
+			if got := checkDuplicateRecipients(tt.data, cfg); !reflect.DeepEqual(got, tt.duplicate) {
+				t.Errorf("checkDuplicateRecipients() = %q, want %q", got, tt.duplicate)
+			}
+		})
+	}
+}
+
+func TestRunSendChecks(t *testing.T) {
+	data := EmailData{To: []string{"ada@example.com"}, Body: "Hi {{name}}"}
+
+	findings := runSendChecks(data, &config.SendChecks{})
+	want := []CheckFinding{
+		{Check: "empty_subject", Message: "The subject is empty", Block: true},
+		{Check: "placeholders", Message: "Unfilled template placeholders: {{name}}"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("runSendChecks() = %+v, want %+v", findings, want)
+	}
+	if !blocksSend(findings) {
+		t.Error("blocksSend() = false with an empty subject")
+	}
+
+	// Levels override the defaults
+	cfg := &config.SendChecks{Levels: map[string]config.CheckLevel{"empty_subject": config.CheckOff, "placeholders": config.CheckBlock}}
+	findings = runSendChecks(data, cfg)
+	want = []CheckFinding{{Check: "placeholders", Message: "Unfilled template placeholders: {{name}}", Block: true}}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("runSendChecks() = %+v, want %+v", findings, want)
+	}
+}
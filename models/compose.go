@@ -1,8 +1,11 @@
 package models
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net/mail"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -12,6 +15,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"mailgloss/config"
+	"mailgloss/mailer"
 	"mailgloss/storage"
 	"mailgloss/ui"
 )
@@ -22,11 +26,27 @@ type ComposeModel struct {
 	textarea         textarea.Model
 	FocusIndex       int
 	attachments      []string
+	inlineImages     []string                // Attachments embedded in the HTML body
+	zipped           []string                // Attachments bundled into attachments.zip
+	files            map[string]attachedFile // Detected type and size of attached files by path, shared with clones
+	completions      []string                // Path completion candidates for the attachment input
+	attachmentErr    string                  // Problem with the last attachments added
+	editorErr        string                  // Problem with the external editor
+	draftPath        string                  // Edited draft that failed to load, reopened by Ctrl+O
 	width            int
 	height           int
-	providers        []string // List of available provider names
-	selectedProvider string   // Currently selected provider
-	providerIdx      int      // Index in providers list
+	providers        []string          // List of available provider names
+	selectedProvider string            // Currently selected provider
+	providerIdx      int               // Index in providers list
+	identities       []config.Identity // Sender identities of the selected provider
+	identityIdx      int               // Index in identities list
+	signature        string            // Signature block currently appended to the body
+	htmlSignature    string            // HTML version of the current signature
+	priorityIdx      int               // Index in composePriorities
+	readReceipt      bool              // Whether to request a read receipt
+	pgpIdx           int               // Index in composeSecurityModes
+	smimeIdx         int               // Index in composeSecurityModes
+	template         string            // Name of the template the message started from
 	config           *config.Config
 	fileSelector     *FileSelectModel     // File selector for attachments
 	showFileSelector bool                 // Whether to show file selector
@@ -34,27 +54,74 @@ type ComposeModel struct {
 	showPicker       bool                 // Whether to show picker
 	variablePrompt   *VariablePromptModel // Variable prompt for templates
 	showVarPrompt    bool                 // Whether to show variable prompt
+	preview          *PreviewModel        // Preview of the message as it will be sent
+	showPreview      bool                 // Whether to show the preview
 	contacts         *storage.Contacts
 	templates        *storage.Templates
-	spinner          spinner.Model // Loading spinner
-	isSending        bool          // Whether email is being sent
+	spinner          spinner.Model  // Loading spinner
+	isSending        bool           // Whether email is being sent
+	checks           *SendChecksMsg // Send check findings waiting to be confirmed or fixed
 }
 
 const (
 	providerSelector = iota
+	identitySelector
 	fromInput
+	replyToInput
 	toInput
 	ccInput
 	bccInput
 	subjectInput
+	headersInput
 	attachmentInput
+	archivePasswordInput
 	bodyInput
+	prioritySelector
+	receiptToggle
+	pgpSelector
+	smimeSelector
 	sendButton
 )
 
+// composePriorities are the priorities selectable in compose
+var composePriorities = []mailer.Priority{mailer.PriorityNormal, mailer.PriorityHigh, mailer.PriorityLow}
+
+// composeSecurityModes are the PGP/MIME and S/MIME protections selectable
+// in compose
+var composeSecurityModes = []struct {
+	name          string
+	sign, encrypt bool
+}{
+	{"off", false, false},
+	{"sign", true, false},
+	{"encrypt", false, true},
+	{"sign + encrypt", true, true},
+}
+
+// inputOffset is the focus index of the first text input
+const inputOffset = fromInput
+
+// attachedFile is the detected type and size of an attached file, or why
+// it couldn't be inspected
+type attachedFile struct {
+	info       mailer.FileInfo
+	err        error
+	inspecting bool // Still being inspected, folders can take a while
+}
+
+// attachmentInspectedMsg carries the detected type and size of a file
+type attachmentInspectedMsg struct {
+	path string
+	info mailer.FileInfo
+	err  error
+}
+
+// bundleName is the file name of the zip that bundles attachments
+const bundleName = "attachments.zip"
+
 // NewComposeModel creates a new compose model
 func NewComposeModel(cfg *config.Config, contacts *storage.Contacts, templates *storage.Templates) ComposeModel {
-	providers := cfg.ListProviders()
+	providers := cfg.ListSendTargets()
 	selectedProvider := cfg.DefaultProvider
 	providerIdx := 0
 
@@ -70,47 +137,66 @@ func NewComposeModel(cfg *config.Config, contacts *storage.Contacts, templates *
 	limits := cfg.GetLimits()
 
 	// Create text inputs
-	inputs := make([]textinput.Model, 6)
+	inputs := make([]textinput.Model, archivePasswordInput-inputOffset+1)
 
 	// From field
-	inputs[fromInput-1] = textinput.New()
-	inputs[fromInput-1].Placeholder = "Name <email@example.com> or user@ (optional)"
-	inputs[fromInput-1].CharLimit = 200
-	inputs[fromInput-1].Width = 60
+	inputs[fromInput-inputOffset] = textinput.New()
+	inputs[fromInput-inputOffset].Placeholder = "Name <email@example.com> or user@ (optional)"
+	inputs[fromInput-inputOffset].CharLimit = 200
+	inputs[fromInput-inputOffset].Width = 60
+
+	// Reply-To field
+	inputs[replyToInput-inputOffset] = textinput.New()
+	inputs[replyToInput-inputOffset].Placeholder = "reply@example.com (optional, defaults to identity)"
+	inputs[replyToInput-inputOffset].CharLimit = 200
+	inputs[replyToInput-inputOffset].Width = 60
 
 	// To field
-	inputs[toInput-1] = textinput.New()
-	inputs[toInput-1].Placeholder = "recipient@example.com (Ctrl+P for contacts)"
-	inputs[toInput-1].CharLimit = limits.MaxEmailsPerField
-	inputs[toInput-1].Width = 60
+	inputs[toInput-inputOffset] = textinput.New()
+	inputs[toInput-inputOffset].Placeholder = "recipient@example.com (Ctrl+P for contacts)"
+	inputs[toInput-inputOffset].CharLimit = limits.MaxEmailsPerField
+	inputs[toInput-inputOffset].Width = 60
 
 	// CC field
-	inputs[ccInput-1] = textinput.New()
-	inputs[ccInput-1].Placeholder = "cc@example.com (optional, Ctrl+P for contacts)"
-	inputs[ccInput-1].CharLimit = limits.MaxEmailsPerField
-	inputs[ccInput-1].Width = 60
+	inputs[ccInput-inputOffset] = textinput.New()
+	inputs[ccInput-inputOffset].Placeholder = "cc@example.com (optional, Ctrl+P for contacts)"
+	inputs[ccInput-inputOffset].CharLimit = limits.MaxEmailsPerField
+	inputs[ccInput-inputOffset].Width = 60
 
 	// BCC field
-	inputs[bccInput-1] = textinput.New()
-	inputs[bccInput-1].Placeholder = "bcc@example.com (optional, Ctrl+P for contacts)"
-	inputs[bccInput-1].CharLimit = limits.MaxEmailsPerField
-	inputs[bccInput-1].Width = 60
+	inputs[bccInput-inputOffset] = textinput.New()
+	inputs[bccInput-inputOffset].Placeholder = "bcc@example.com (optional, Ctrl+P for contacts)"
+	inputs[bccInput-inputOffset].CharLimit = limits.MaxEmailsPerField
+	inputs[bccInput-inputOffset].Width = 60
 
 	// Subject field
-	inputs[subjectInput-1] = textinput.New()
-	inputs[subjectInput-1].Placeholder = "Email subject (Ctrl+T for templates)"
-	inputs[subjectInput-1].CharLimit = 200
-	inputs[subjectInput-1].Width = 60
+	inputs[subjectInput-inputOffset] = textinput.New()
+	inputs[subjectInput-inputOffset].Placeholder = "Email subject (Ctrl+T for templates)"
+	inputs[subjectInput-inputOffset].CharLimit = 200
+	inputs[subjectInput-inputOffset].Width = 60
+
+	// Custom headers field
+	inputs[headersInput-inputOffset] = textinput.New()
+	inputs[headersInput-inputOffset].Placeholder = "X-Campaign: spring; X-Team: ops (optional)"
+	inputs[headersInput-inputOffset].CharLimit = 1000
+	inputs[headersInput-inputOffset].Width = 60
 
 	// Attachment field
-	inputs[attachmentInput-1] = textinput.New()
-	inputs[attachmentInput-1].Placeholder = "/path/to/file.pdf (Enter to add, Ctrl+F for browser)"
-	inputs[attachmentInput-1].CharLimit = 500
-	inputs[attachmentInput-1].Width = 60
+	inputs[attachmentInput-inputOffset] = textinput.New()
+	inputs[attachmentInput-inputOffset].Placeholder = "~/file.pdf, folder or *.pdf (Tab completes, Ctrl+F browses)"
+	inputs[attachmentInput-inputOffset].CharLimit = 500
+	inputs[attachmentInput-inputOffset].Width = 60
+
+	// Zip password field
+	inputs[archivePasswordInput-inputOffset] = textinput.New()
+	inputs[archivePasswordInput-inputOffset].Placeholder = "Password for zipped folders and bundles (optional)"
+	inputs[archivePasswordInput-inputOffset].CharLimit = 200
+	inputs[archivePasswordInput-inputOffset].Width = 60
+	inputs[archivePasswordInput-inputOffset].EchoMode = textinput.EchoPassword
 
 	// Create textarea for body
 	ta := textarea.New()
-	ta.Placeholder = "Email body... (Ctrl+T for templates)"
+	ta.Placeholder = "Email body... (Ctrl+T for templates, Ctrl+O for $EDITOR)"
 	ta.SetWidth(64)
 	ta.SetHeight(8)
 	ta.CharLimit = limits.MaxBodyLength
@@ -120,11 +206,12 @@ func NewComposeModel(cfg *config.Config, contacts *storage.Contacts, templates *
 	s.Spinner = spinner.Dot
 	s.Style = ui.SpinnerStyle
 
-	return ComposeModel{
+	m := ComposeModel{
 		inputs:           inputs,
 		textarea:         ta,
 		FocusIndex:       providerSelector,
 		attachments:      []string{},
+		files:            make(map[string]attachedFile),
 		providers:        providers,
 		selectedProvider: selectedProvider,
 		providerIdx:      providerIdx,
@@ -140,6 +227,8 @@ func NewComposeModel(cfg *config.Config, contacts *storage.Contacts, templates *
 		spinner:          s,
 		isSending:        false,
 	}
+	m.loadIdentities()
+	return m
 }
 
 // Init initializes the compose model
@@ -156,6 +245,11 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 	m.spinner, spinnerCmd = m.spinner.Update(msg)
 	cmds = append(cmds, spinnerCmd)
 
+	if msg, ok := msg.(attachmentInspectedMsg); ok {
+		m.files[msg.path] = attachedFile{info: msg.info, err: msg.err}
+		return m, nil
+	}
+
 	// If variable prompt is open, route messages to it
 	if m.showVarPrompt && m.variablePrompt != nil {
 		switch msg := msg.(type) {
@@ -175,8 +269,9 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 
 			subject, body := storage.RenderTemplate(msg.Template, finalVars)
 
-			m.inputs[subjectInput-1].SetValue(subject)
-			m.textarea.SetValue(body)
+			m.inputs[subjectInput-inputOffset].SetValue(subject)
+			m.setBody(body)
+			m.template = msg.Template.Name
 
 			m.showVarPrompt = false
 			m.variablePrompt = nil
@@ -203,11 +298,11 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 			contact := msg.Contact
 			emailStr := fmt.Sprintf("%s <%s>", contact.Name, contact.Email)
 
-			currentValue := m.inputs[msg.TargetField-1].Value()
+			currentValue := m.inputs[msg.TargetField-inputOffset].Value()
 			if currentValue != "" {
 				emailStr = currentValue + ", " + emailStr
 			}
-			m.inputs[msg.TargetField-1].SetValue(emailStr)
+			m.inputs[msg.TargetField-inputOffset].SetValue(emailStr)
 
 			m.showPicker = false
 			m.picker = nil
@@ -228,20 +323,18 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 				}
 				defaults["date"] = time.Now().Format(dateFormat)
 
-				// from_name/from_email defaults from provider config or compose From field
-				if m.selectedProvider != "" && m.config != nil {
-					if pc, err := m.config.GetProvider(m.selectedProvider); err == nil {
-						if pc.FromName != "" {
-							defaults["from_name"] = pc.FromName
-						}
-						if pc.FromAddress != "" {
-							defaults["from_email"] = pc.FromAddress
-						}
+				// from_name/from_email defaults from the selected identity or compose From field
+				if identity := m.selectedIdentity(); identity != nil {
+					if identity.DisplayName != "" {
+						defaults["from_name"] = identity.DisplayName
+					}
+					if identity.Address != "" {
+						defaults["from_email"] = identity.Address
 					}
 				}
 
 				// Also consider the From input override (Name <email>)
-				fromInput := strings.TrimSpace(m.inputs[fromInput-1].Value())
+				fromInput := strings.TrimSpace(m.inputs[fromInput-inputOffset].Value())
 				if fromInput != "" {
 					// Parse as name and email if possible
 					if addr, err := mail.ParseAddress(fromInput); err == nil {
@@ -265,8 +358,9 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 			// No variables, just insert template as-is
 			subject, body := storage.RenderTemplate(template, map[string]string{})
 
-			m.inputs[subjectInput-1].SetValue(subject)
-			m.textarea.SetValue(body)
+			m.inputs[subjectInput-inputOffset].SetValue(subject)
+			m.setBody(body)
+			m.template = template.Name
 
 			m.showPicker = false
 			m.picker = nil
@@ -284,24 +378,50 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 		return m, cmd
 	}
 
+	// If preview is open, route messages to it
+	if m.showPreview && m.preview != nil {
+		switch msg.(type) {
+		case PreviewClosedMsg:
+			m.closePreview()
+			return m, nil
+
+		case PreviewSendMsg:
+			m.closePreview()
+			m.isSending = true
+			return m, m.sendEmail()
+		}
+
+		// Update preview
+		var cmd tea.Cmd
+		*m.preview, cmd = m.preview.Update(msg)
+		return m, cmd
+	}
+
 	// If file selector is open, route messages to it
 	if m.showFileSelector && m.fileSelector != nil {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			if msg.String() == "esc" {
+			if msg.String() == "esc" && !m.fileSelector.IsSearching() {
 				// Close file selector
 				m.showFileSelector = false
 				m.fileSelector = nil
 				return m, nil
 			}
 		case FileSelectedMsg:
-			// File was selected, add to attachments
-			if msg.Path != "" {
-				m.attachments = append(m.attachments, msg.Path)
+			// Files were selected, add the valid ones to attachments
+			m.attachmentErr = ""
+			var added []string
+			for _, path := range msg.Paths {
+				if err := validateAttachmentPath(path, m.config.GetLimits().MaxAttachmentSizeMB); err != nil {
+					m.attachmentErr = fmt.Sprintf("%s: %v", path, err)
+					continue
+				}
+				added = append(added, path)
 			}
+			m.attachments = append(m.attachments, added...)
 			m.showFileSelector = false
 			m.fileSelector = nil
-			return m, nil
+			return m, m.inspectAttachments(added...)
 		}
 
 		// Update file selector
@@ -310,9 +430,70 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 		return m, cmd
 	}
 
+	// Send check findings are confirmed with Enter and dismissed with Esc or
+	// any other key, which goes on to edit the message
+	if m.checks != nil {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			checks := m.checks
+			m.checks = nil
+			switch msg.String() {
+			case "enter":
+				if !blocksSend(checks.Findings) {
+					m.isSending = true
+					send := checks.Send
+					return m, func() tea.Msg { return send }
+				}
+				return m, nil
+			case "esc":
+				return m, nil
+			}
+		}
+	}
+
 	switch msg := msg.(type) {
+	case SendChecksMsg:
+		m.isSending = false
+		m.checks = &msg
+		return m, nil
+
+	case EditorFinishedMsg:
+		// Read the edited draft back and clean up. A draft that can't be
+		// loaded is kept for the next Ctrl+O, so the edits aren't lost.
+		m.editorErr = ""
+		if msg.Err != nil {
+			m.editorErr = fmt.Sprintf("Editor failed: %v", msg.Err)
+			m.removeDraft(msg.Path)
+			return m, nil
+		}
+		content, err := os.ReadFile(msg.Path)
+		if err != nil {
+			m.editorErr = fmt.Sprintf("Failed to read draft: %v", err)
+			m.removeDraft(msg.Path)
+			return m, nil
+		}
+		if err := m.loadDraft(string(content)); err != nil {
+			m.editorErr = fmt.Sprintf("Draft not loaded: %v. Press Ctrl+O to fix it", err)
+			m.draftPath = msg.Path
+			return m, nil
+		}
+		m.removeDraft(msg.Path)
+		return m, nil
+
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "ctrl+r":
+			// Preview the message as it will be sent
+			return m, m.previewEmail()
+
+		case "ctrl+o":
+			// Edit the headers and body in $VISUAL/$EDITOR
+			cmd, err := m.openEditor()
+			if err != nil {
+				m.editorErr = err.Error()
+				return m, nil
+			}
+			return m, cmd
+
 		case "ctrl+p":
 			// Open contact picker if on email fields
 			if m.FocusIndex >= toInput && m.FocusIndex <= bccInput {
@@ -334,6 +515,21 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 		case "tab", "shift+tab", "up", "down":
 			s := msg.String()
 
+			// Tab completes a typed attachment path, like a shell
+			if s == "tab" && m.FocusIndex == attachmentInput {
+				input := m.inputs[attachmentInput-inputOffset].Value()
+				if input != "" && !hasGlob(input) {
+					completed, candidates := completePath(input)
+					m.completions = candidates
+					if completed != input || len(candidates) > 0 {
+						m.inputs[attachmentInput-inputOffset].SetValue(completed)
+						m.inputs[attachmentInput-inputOffset].CursorEnd()
+						return m, nil
+					}
+				}
+			}
+			m.completions = nil
+
 			// Handle navigation
 			if s == "up" || s == "shift+tab" {
 				m.FocusIndex--
@@ -352,8 +548,8 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 				m.inputs[i].Blur()
 			}
 
-			if m.FocusIndex > providerSelector && m.FocusIndex < bodyInput {
-				cmds = append(cmds, m.inputs[m.FocusIndex-1].Focus())
+			if m.FocusIndex >= inputOffset && m.FocusIndex < bodyInput {
+				cmds = append(cmds, m.inputs[m.FocusIndex-inputOffset].Focus())
 			} else if m.FocusIndex == bodyInput {
 				cmds = append(cmds, m.textarea.Focus())
 			} else {
@@ -378,13 +574,72 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 				}
 				if len(m.providers) > 0 {
 					m.selectedProvider = m.providers[m.providerIdx]
+					m.loadIdentities()
+				}
+			}
+
+			// Change sender identity if on identity selector
+			if m.FocusIndex == identitySelector && len(m.identities) > 0 {
+				if msg.String() == "left" {
+					m.identityIdx--
+					if m.identityIdx < 0 {
+						m.identityIdx = len(m.identities) - 1
+					}
+				} else {
+					m.identityIdx++
+					if m.identityIdx >= len(m.identities) {
+						m.identityIdx = 0
+					}
+				}
+				m.applySignature()
+			}
+
+			// Change priority if on priority selector
+			if m.FocusIndex == prioritySelector {
+				if msg.String() == "left" {
+					m.priorityIdx = (m.priorityIdx + len(composePriorities) - 1) % len(composePriorities)
+				} else {
+					m.priorityIdx = (m.priorityIdx + 1) % len(composePriorities)
+				}
+			}
+
+			// Toggle read receipt
+			if m.FocusIndex == receiptToggle {
+				m.readReceipt = !m.readReceipt
+			}
+
+			// Change PGP mode if on PGP selector
+			if m.FocusIndex == pgpSelector {
+				if msg.String() == "left" {
+					m.pgpIdx = (m.pgpIdx + len(composeSecurityModes) - 1) % len(composeSecurityModes)
+				} else {
+					m.pgpIdx = (m.pgpIdx + 1) % len(composeSecurityModes)
+				}
+			}
+
+			// Change S/MIME mode if on S/MIME selector
+			if m.FocusIndex == smimeSelector {
+				if msg.String() == "left" {
+					m.smimeIdx = (m.smimeIdx + len(composeSecurityModes) - 1) % len(composeSecurityModes)
+				} else {
+					m.smimeIdx = (m.smimeIdx + 1) % len(composeSecurityModes)
 				}
 			}
 
+		case "ctrl+n":
+			// Complete or cycle the sender domain in the From field
+			if m.FocusIndex == fromInput {
+				m.completeFromDomain()
+				return m, nil
+			}
+
 		case "ctrl+f":
 			// Open file selector if on attachment field
 			if m.FocusIndex == attachmentInput {
 				fs := NewFileSelectModel("")
+				if m.width > 0 {
+					fs.SetSize(m.width, m.height)
+				}
 				m.fileSelector = &fs
 				m.showFileSelector = true
 				return m, nil
@@ -392,15 +647,32 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 
 		case "enter":
 			// Add attachment if on attachment field
+			// Add attachments, expanding ~ and glob patterns
 			if m.FocusIndex == attachmentInput {
-				path := strings.TrimSpace(m.inputs[attachmentInput-1].Value())
-				if path != "" {
-					m.attachments = append(m.attachments, path)
-					m.inputs[attachmentInput-1].SetValue("")
+				input := strings.TrimSpace(m.inputs[attachmentInput-inputOffset].Value())
+				if input != "" {
+					paths, err := expandAttachments(input, m.config.GetLimits().MaxAttachmentSizeMB)
+					m.attachments = append(m.attachments, paths...)
+					m.attachmentErr = ""
+					if err != nil {
+						m.attachmentErr = err.Error()
+					}
+					// Keep the input to fix it if nothing could be added
+					if len(paths) > 0 {
+						m.inputs[attachmentInput-inputOffset].SetValue("")
+					}
+					m.completions = nil
+					return m, m.inspectAttachments(paths...)
 				}
 				return m, nil
 			}
 
+			// Toggle read receipt
+			if m.FocusIndex == receiptToggle {
+				m.readReceipt = !m.readReceipt
+				return m, nil
+			}
+
 			// Send email if on send button
 			if m.FocusIndex == sendButton {
 				m.isSending = true
@@ -408,9 +680,44 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 			}
 
 		case "ctrl+d":
-			// Delete last attachment
-			if m.FocusIndex == attachmentInput && len(m.attachments) > 0 {
-				m.attachments = m.attachments[:len(m.attachments)-1]
+			// Delete last attachment, inline images first and bundled files last
+			if m.FocusIndex == attachmentInput {
+				if len(m.inlineImages) > 0 {
+					m.inlineImages = m.inlineImages[:len(m.inlineImages)-1]
+				} else if len(m.attachments) > 0 {
+					m.attachments = m.attachments[:len(m.attachments)-1]
+				} else if len(m.zipped) > 0 {
+					m.zipped = m.zipped[:len(m.zipped)-1]
+				}
+				return m, nil
+			}
+
+		case "ctrl+z":
+			// Bundle the attachments into one zip, or unbundle them
+			if m.FocusIndex == attachmentInput {
+				if len(m.attachments) > 0 {
+					m.zipped = append(m.zipped, m.attachments...)
+					m.attachments = []string{}
+				} else {
+					m.attachments = append(m.attachments, m.zipped...)
+					m.zipped = nil
+				}
+				return m, nil
+			}
+
+		case "ctrl+l":
+			// Mark the last attachment as an inline image, or move the last
+			// inline image back to the attachments
+			if m.FocusIndex == attachmentInput {
+				if len(m.attachments) > 0 {
+					last := m.attachments[len(m.attachments)-1]
+					m.attachments = m.attachments[:len(m.attachments)-1]
+					m.inlineImages = append(m.inlineImages, last)
+				} else if len(m.inlineImages) > 0 {
+					last := m.inlineImages[len(m.inlineImages)-1]
+					m.inlineImages = m.inlineImages[:len(m.inlineImages)-1]
+					m.attachments = append(m.attachments, last)
+				}
 				return m, nil
 			}
 		}
@@ -425,10 +732,13 @@ func (m ComposeModel) Update(msg tea.Msg) (ComposeModel, tea.Cmd) {
 		var cmd tea.Cmd
 		m.textarea, cmd = m.textarea.Update(msg)
 		cmds = append(cmds, cmd)
-	} else if m.FocusIndex > providerSelector && m.FocusIndex < bodyInput {
+	} else if m.FocusIndex >= inputOffset && m.FocusIndex < bodyInput {
 		var cmd tea.Cmd
-		m.inputs[m.FocusIndex-1], cmd = m.inputs[m.FocusIndex-1].Update(msg)
+		m.inputs[m.FocusIndex-inputOffset], cmd = m.inputs[m.FocusIndex-inputOffset].Update(msg)
 		cmds = append(cmds, cmd)
+		if _, ok := msg.(tea.KeyMsg); ok && m.FocusIndex == attachmentInput {
+			m.completions = nil
+		}
 	}
 
 	return m, tea.Batch(cmds...)
@@ -451,6 +761,11 @@ func (m ComposeModel) View() string {
 		return m.fileSelector.View()
 	}
 
+	// If preview is open, show it instead
+	if m.showPreview && m.preview != nil {
+		return m.preview.View()
+	}
+
 	var b strings.Builder
 
 	b.WriteString(ui.TitleStyle.Render("Compose Email"))
@@ -488,13 +803,42 @@ func (m ComposeModel) View() string {
 		} else {
 			b.WriteString(providerDisplay)
 		}
+
+		// Show the providers a failover chain will try
+		if chain, err := m.config.GetChain(m.selectedProvider); err == nil {
+			b.WriteString(" ")
+			b.WriteString(ui.HelpKeyStyle.Render("⇢ " + strings.Join(chain.Providers, " → ")))
+		}
+	}
+	b.WriteString("\n\n")
+
+	// Identity selector
+	focused = m.FocusIndex == identitySelector
+	label = ui.LabelStyle
+	if focused {
+		label = label.Foreground(ui.Primary)
+	}
+	b.WriteString(label.Render("Identity:"))
+	b.WriteString("\n")
+	identityDisplay := "(none)"
+	if identity := m.selectedIdentity(); identity != nil {
+		identityDisplay = formatIdentity(*identity)
+	}
+	if focused {
+		b.WriteString(ui.FocusedInputStyle.Render("< " + identityDisplay + " >"))
+	} else {
+		b.WriteString(identityDisplay)
+	}
+	if identity := m.selectedIdentity(); identity != nil && identity.ReplyTo != "" {
+		b.WriteString(" ")
+		b.WriteString(ui.HelpKeyStyle.Render("↩ " + identity.ReplyTo))
 	}
 	b.WriteString("\n\n")
 
 	// Render input fields
-	labels := []string{"From", "To", "CC", "BCC", "Subject", "Attachments"}
+	labels := []string{"From", "Reply-To", "To", "CC", "BCC", "Subject", "Headers", "Attachments", "Zip Password"}
 	for i, label := range labels {
-		fieldIdx := i + 1 // Offset by 1 because providerSelector is 0
+		fieldIdx := i + inputOffset
 		focused := fieldIdx == m.FocusIndex
 
 		labelStyle := ui.LabelStyle
@@ -511,20 +855,50 @@ func (m ComposeModel) View() string {
 			b.WriteString(m.inputs[i].View())
 		}
 		b.WriteString("\n")
+
+		if fieldIdx == attachmentInput {
+			if len(m.completions) > 0 {
+				b.WriteString(ui.MutedTextStyle.Render(formatCompletions(m.completions)))
+				b.WriteString("\n")
+			}
+			if m.attachmentErr != "" {
+				b.WriteString(ui.ErrorTextStyle.Render("✗ " + m.attachmentErr))
+				b.WriteString("\n")
+			}
+		}
 	}
 
 	// Show attachments list
-	if len(m.attachments) > 0 {
+	if len(m.attachments) > 0 || len(m.inlineImages) > 0 || len(m.zipped) > 0 {
 		b.WriteString("\n")
 		b.WriteString(ui.ListTitleStyle.Render("Attached Files:"))
 		b.WriteString("\n")
-		for i, att := range m.attachments {
-			b.WriteString(ui.ListItemStyle.Render("  " + att))
-			if i < len(m.attachments)-1 {
+		items := make([]string, 0, len(m.attachments)+len(m.inlineImages)+len(m.zipped)+1)
+		for _, att := range m.attachments {
+			items = append(items, "  "+att+" "+m.describeFile(att))
+		}
+		for _, img := range m.inlineImages {
+			items = append(items, "  "+img+" (inline, cid:"+filepath.Base(img)+") "+m.describeFile(img))
+		}
+		if len(m.zipped) > 0 {
+			bundle := fmt.Sprintf("  %s (zip of %d)", bundleName, len(m.zipped))
+			if m.inputs[archivePasswordInput-inputOffset].Value() != "" {
+				bundle = fmt.Sprintf("  %s (password-protected zip of %d)", bundleName, len(m.zipped))
+			}
+			items = append(items, bundle)
+			for _, path := range m.zipped {
+				items = append(items, "    "+path+" "+m.describeFile(path))
+			}
+		}
+		for i, item := range items {
+			b.WriteString(ui.ListItemStyle.Render(item))
+			if i < len(items)-1 {
 				b.WriteString("\n")
 			}
 		}
 		b.WriteString("\n")
+		b.WriteString(m.renderMessageSize())
+		b.WriteString("\n")
 	}
 
 	// Body field
@@ -534,6 +908,10 @@ func (m ComposeModel) View() string {
 		bodyLabel = bodyLabel.Foreground(ui.Primary)
 	}
 	b.WriteString(bodyLabel.Render("Body:"))
+	if m.editorErr != "" {
+		b.WriteString(" ")
+		b.WriteString(ui.ErrorTextStyle.Render("✗ " + m.editorErr))
+	}
 	b.WriteString("\n")
 
 	textareaView := m.textarea.View()
@@ -543,6 +921,66 @@ func (m ComposeModel) View() string {
 	b.WriteString(textareaView)
 	b.WriteString("\n\n")
 
+	// Priority and read receipt
+	priorityLabel := ui.LabelStyle
+	if m.FocusIndex == prioritySelector {
+		priorityLabel = priorityLabel.Foreground(ui.Primary)
+	}
+	b.WriteString(priorityLabel.Render("Priority:"))
+	b.WriteString(" ")
+	priorityDisplay := priorityName(composePriorities[m.priorityIdx])
+	if m.FocusIndex == prioritySelector {
+		b.WriteString(ui.FocusedInputStyle.Render("< " + priorityDisplay + " >"))
+	} else {
+		b.WriteString(priorityDisplay)
+	}
+	b.WriteString("\n")
+
+	receiptLabel := ui.LabelStyle
+	if m.FocusIndex == receiptToggle {
+		receiptLabel = receiptLabel.Foreground(ui.Primary)
+	}
+	b.WriteString(receiptLabel.Render("Read Receipt:"))
+	b.WriteString(" ")
+	receiptDisplay := "[ ] off"
+	if m.readReceipt {
+		receiptDisplay = "[x] on"
+	}
+	if m.FocusIndex == receiptToggle {
+		b.WriteString(ui.FocusedInputStyle.Render(receiptDisplay))
+	} else {
+		b.WriteString(receiptDisplay)
+	}
+	b.WriteString("\n")
+
+	pgpLabel := ui.LabelStyle
+	if m.FocusIndex == pgpSelector {
+		pgpLabel = pgpLabel.Foreground(ui.Primary)
+	}
+	b.WriteString(pgpLabel.Render("PGP:"))
+	b.WriteString(" ")
+	pgpDisplay := composeSecurityModes[m.pgpIdx].name
+	if m.FocusIndex == pgpSelector {
+		b.WriteString(ui.FocusedInputStyle.Render("< " + pgpDisplay + " >"))
+	} else {
+		b.WriteString(pgpDisplay)
+	}
+	b.WriteString("\n")
+
+	smimeLabel := ui.LabelStyle
+	if m.FocusIndex == smimeSelector {
+		smimeLabel = smimeLabel.Foreground(ui.Primary)
+	}
+	b.WriteString(smimeLabel.Render("S/MIME:"))
+	b.WriteString(" ")
+	smimeDisplay := composeSecurityModes[m.smimeIdx].name
+	if m.FocusIndex == smimeSelector {
+		b.WriteString(ui.FocusedInputStyle.Render("< " + smimeDisplay + " >"))
+	} else {
+		b.WriteString(smimeDisplay)
+	}
+	b.WriteString("\n\n")
+
 	// Send button
 	buttonText := "[ Send Email ]"
 	if m.FocusIndex == sendButton {
@@ -558,13 +996,23 @@ func (m ComposeModel) View() string {
 		b.WriteString(" Sending email...")
 	}
 
+	if m.checks != nil {
+		b.WriteString("\n\n")
+		b.WriteString(renderFindings(m.checks.Findings))
+	}
+
 	b.WriteString("\n\n")
 	b.WriteString(ui.RenderHelp(
 		"Tab", "next field",
-		"←/→", "change provider",
+		"←/→", "provider/identity",
+		"Ctrl+N", "sender domain",
 		"Ctrl+P", "contacts",
 		"Ctrl+T", "templates",
 		"Ctrl+F", "file browser",
+		"Ctrl+L", "inline image",
+		"Ctrl+Z", "zip attachments",
+		"Ctrl+O", "open in $EDITOR",
+		"Ctrl+R", "preview",
 	))
 
 	return b.String()
@@ -572,46 +1020,237 @@ func (m ComposeModel) View() string {
 
 // GetEmailData returns the current email data
 func (m ComposeModel) GetEmailData() (EmailData, error) {
-	to, err := splitEmails(m.inputs[toInput-1].Value())
+	to, err := splitEmails(m.inputs[toInput-inputOffset].Value())
 	if err != nil {
 		return EmailData{}, fmt.Errorf("To field: %w", err)
 	}
 
-	cc, err := splitEmails(m.inputs[ccInput-1].Value())
+	cc, err := splitEmails(m.inputs[ccInput-inputOffset].Value())
 	if err != nil {
 		return EmailData{}, fmt.Errorf("CC field: %w", err)
 	}
 
-	bcc, err := splitEmails(m.inputs[bccInput-1].Value())
+	bcc, err := splitEmails(m.inputs[bccInput-inputOffset].Value())
 	if err != nil {
 		return EmailData{}, fmt.Errorf("BCC field: %w", err)
 	}
 
-	// Parse From address and name if provided
+	// The selected identity provides the sender unless the From field overrides it
 	fromAddr := ""
 	fromName := ""
-	fromInput := m.inputs[fromInput-1].Value()
-	if fromInput != "" {
-		providerConfig, _ := m.config.GetProvider(m.selectedProvider)
+	replyTo := ""
+	identity := m.selectedIdentity()
+	if identity != nil {
+		if identity.Name != config.DefaultIdentityName {
+			fromAddr = identity.Address
+			fromName = identity.DisplayName
+		}
+		replyTo = identity.ReplyTo
+	}
+
+	// The Reply-To field overrides the identity's Reply-To
+	if v := strings.TrimSpace(m.inputs[replyToInput-inputOffset].Value()); v != "" {
+		addr, err := mail.ParseAddress(v)
+		if err != nil {
+			return EmailData{}, fmt.Errorf("Reply-To field: invalid email '%s': %w", v, err)
+		}
+		replyTo = addr.String()
+	}
+
+	headers, err := mailer.ParseHeaders(m.inputs[headersInput-inputOffset].Value())
+	if err != nil {
+		return EmailData{}, fmt.Errorf("Headers field: %w", err)
+	}
+
+	// Parse From address and name if provided
+	fromInput := m.inputs[fromInput-inputOffset].Value()
+	if strings.TrimSpace(fromInput) != "" {
+		providerConfig, _ := m.config.PrimaryProvider(m.selectedProvider)
 		var err error
 		fromAddr, fromName, err = parseFromField(fromInput, providerConfig)
 		if err != nil {
 			return EmailData{}, fmt.Errorf("From field: %w", err)
 		}
+		if providerConfig != nil && !providerConfig.IsAllowedAddress(fromAddr) {
+			return EmailData{}, fmt.Errorf("From field: %s is not in the allowed domains of %s", fromAddr, providerConfig.Name)
+		}
+	}
+
+	pgpMode := composeSecurityModes[m.pgpIdx]
+	smimeMode := composeSecurityModes[m.smimeIdx]
+	if m.pgpIdx != 0 && m.smimeIdx != 0 {
+		return EmailData{}, fmt.Errorf("PGP and S/MIME can't be combined, turn one of them off")
+	}
+	providerConfig, _ := m.config.PrimaryProvider(m.selectedProvider)
+	sender := fromAddr
+	if sender == "" && providerConfig != nil {
+		sender = providerConfig.FromAddress
+	}
+	var pgpKeys []*mailer.PGPKey
+	if pgpMode.sign || pgpMode.encrypt {
+		if pgpMode.sign && (providerConfig == nil || providerConfig.PGPFor(sender) == nil) {
+			return EmailData{}, fmt.Errorf("PGP: no secret key is configured for %s - add a pgp key to the provider or identity, or turn off signing", sender)
+		}
+		if pgpMode.encrypt {
+			var err error
+			if pgpKeys, err = m.pgpRecipientKeys(append(append(append([]string{}, to...), cc...), bcc...), sender, providerConfig); err != nil {
+				return EmailData{}, fmt.Errorf("PGP: %w", err)
+			}
+		}
+	}
+	var smimeCerts []*x509.Certificate
+	if smimeMode.sign && (providerConfig == nil || providerConfig.SMIMEFor(sender) == nil) {
+		return EmailData{}, fmt.Errorf("S/MIME: no certificate is configured for %s - add an smime certificate to the provider or identity, or turn off signing", sender)
+	}
+	if smimeMode.encrypt {
+		// Enveloped data names every recipient's certificate
+		if len(bcc) > 0 {
+			return EmailData{}, fmt.Errorf("S/MIME: encrypting would reveal the Bcc recipients to everyone - send them a separate message or turn off encryption")
+		}
+		var err error
+		if smimeCerts, err = m.smimeRecipientCerts(append(append([]string{}, to...), cc...), sender, providerConfig); err != nil {
+			return EmailData{}, fmt.Errorf("S/MIME: %w", err)
+		}
+	}
+
+	// Only pass the signature on while it is still intact at the end of the
+	// body, so the mailer can render it in the HTML version
+	body := m.textarea.Value()
+	signature, htmlSignature := "", ""
+	if strings.HasSuffix(body, m.signature) {
+		signature, htmlSignature = m.signature, m.htmlSignature
 	}
 
 	return EmailData{
-		From:        fromAddr,
-		FromName:    fromName,
-		To:          to,
-		CC:          cc,
-		BCC:         bcc,
-		Subject:     m.inputs[subjectInput-1].Value(),
-		Body:        m.textarea.Value(),
-		Attachments: m.attachments,
+		From:            fromAddr,
+		FromName:        fromName,
+		ReplyTo:         replyTo,
+		To:              to,
+		CC:              cc,
+		BCC:             bcc,
+		Subject:         m.inputs[subjectInput-inputOffset].Value(),
+		Body:            body,
+		Signature:       signature,
+		HTMLSignature:   htmlSignature,
+		Attachments:     m.attachments,
+		InlineImages:    m.inlineImages,
+		Bundle:          m.zipped,
+		ArchivePassword: m.inputs[archivePasswordInput-inputOffset].Value(),
+		Headers:         headers,
+		Priority:        composePriorities[m.priorityIdx],
+		ReadReceipt:     m.readReceipt,
+		PGPSign:         pgpMode.sign,
+		PGPEncrypt:      pgpMode.encrypt,
+		PGPKeys:         pgpKeys,
+		SMIMESign:       smimeMode.sign,
+		SMIMEEncrypt:    smimeMode.encrypt,
+		SMIMECerts:      smimeCerts,
+		Template:        m.template,
 	}, nil
 }
 
+// pgpRecipientKeys finds the public key of every recipient, in their
+// contact first and then in the configured keyring. The sender's own key
+// is left to the mailer, which has it loaded to sign.
+func (m ComposeModel) pgpRecipientKeys(recipients []string, sender string, pc *config.ProviderConfig) ([]*mailer.PGPKey, error) {
+	var keyring []*mailer.PGPKey
+	if m.config.PGPKeyring != "" {
+		var err error
+		if keyring, err = mailer.LoadPGPKeyring(m.config.PGPKeyring); err != nil {
+			return nil, err
+		}
+	}
+
+	var keys []*mailer.PGPKey
+	var missing []string
+	for _, rcpt := range recipients {
+		addr, err := mail.ParseAddress(rcpt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient '%s': %w", rcpt, err)
+		}
+		if contact := m.contacts.GetByEmail(addr.Address); contact != nil && contact.PGPKey != "" {
+			contactKeys, err := mailer.ReadPGPKeys([]byte(contact.PGPKey))
+			if err != nil {
+				return nil, fmt.Errorf("key of contact %s: %w", contact.Name, err)
+			}
+			if key := mailer.FindPGPKey(contactKeys, addr.Address); key != nil {
+				keys = append(keys, key)
+				continue
+			}
+		}
+		if key := mailer.FindPGPKey(keyring, addr.Address); key != nil {
+			keys = append(keys, key)
+			continue
+		}
+		if strings.EqualFold(addr.Address, sender) && pc != nil && pc.PGPFor(sender) != nil {
+			continue
+		}
+		missing = append(missing, addr.Address)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no public key for %s - add one to the contact or the keyring, or turn off encryption", strings.Join(missing, ", "))
+	}
+	return keys, nil
+}
+
+// smimeRecipientCerts finds the certificate of every recipient in their
+// contact. The sender's own certificate is left to the mailer.
+func (m ComposeModel) smimeRecipientCerts(recipients []string, sender string, pc *config.ProviderConfig) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	var missing []string
+	for _, rcpt := range recipients {
+		addr, err := mail.ParseAddress(rcpt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient '%s': %w", rcpt, err)
+		}
+		if contact := m.contacts.GetByEmail(addr.Address); contact != nil && contact.SMIMECertificate != "" {
+			cert, err := mailer.ParseSMIMECertificate([]byte(contact.SMIMECertificate))
+			if err != nil {
+				return nil, fmt.Errorf("certificate of contact %s: %w", contact.Name, err)
+			}
+			if mailer.FindSMIMECertificate([]*x509.Certificate{cert}, addr.Address) != nil {
+				certs = append(certs, cert)
+				continue
+			}
+		}
+		if strings.EqualFold(addr.Address, sender) && pc != nil && pc.SMIMEFor(sender) != nil {
+			continue
+		}
+		missing = append(missing, addr.Address)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no valid certificate for %s - add one to the contact, or turn off encryption", strings.Join(missing, ", "))
+	}
+	return certs, nil
+}
+
+// clone returns a copy of the compose state that later edits to m don't change
+func (m ComposeModel) clone() ComposeModel {
+	m.inputs = append([]textinput.Model(nil), m.inputs...)
+	m.attachments = append([]string{}, m.attachments...)
+	m.inlineImages = append([]string(nil), m.inlineImages...)
+	m.zipped = append([]string(nil), m.zipped...)
+	m.fileSelector, m.showFileSelector = nil, false
+	m.picker, m.showPicker = nil, false
+	m.variablePrompt, m.showVarPrompt = nil, false
+	m.preview, m.showPreview = nil, false
+	m.checks = nil
+	m.isSending = false
+	m.draftPath = ""
+	return m
+}
+
+// isEmpty reports whether nothing has been entered since the form was cleared
+func (m ComposeModel) isEmpty() bool {
+	for _, input := range m.inputs {
+		if input.Value() != "" {
+			return false
+		}
+	}
+	body := strings.TrimSuffix(m.textarea.Value(), m.signature)
+	return strings.TrimSpace(body) == "" && len(m.attachments)+len(m.inlineImages)+len(m.zipped) == 0
+}
+
 // Clear resets all fields
 func (m *ComposeModel) Clear() {
 	for i := range m.inputs {
@@ -619,15 +1258,152 @@ func (m *ComposeModel) Clear() {
 	}
 	m.textarea.SetValue("")
 	m.attachments = []string{}
+	m.inlineImages = nil
+	m.zipped = nil
+	m.completions = nil
+	m.attachmentErr = ""
+	m.editorErr = ""
+	m.removeDraft(m.draftPath)
 	m.FocusIndex = providerSelector
 	m.fileSelector = nil
 	m.showFileSelector = false
+	m.priorityIdx = 0
+	m.readReceipt = false
+	m.pgpIdx = 0
+	m.smimeIdx = 0
+	m.template = ""
+	m.signature = ""
+	m.applySignature()
+}
+
+// LoadEmail fills the form with an email from history so it can be resent.
+// The returned command inspects the attached files.
+func (m *ComposeModel) LoadEmail(email storage.SentEmail) tea.Cmd {
+	m.Clear()
+
+	for i, p := range m.providers {
+		if p == email.ProviderName {
+			m.providerIdx = i
+			m.selectedProvider = p
+			break
+		}
+	}
+	m.loadIdentities()
+
+	m.inputs[fromInput-inputOffset].SetValue(email.From)
+	m.inputs[replyToInput-inputOffset].SetValue(email.ReplyTo)
+	m.inputs[toInput-inputOffset].SetValue(strings.Join(email.To, ", "))
+	m.inputs[ccInput-inputOffset].SetValue(strings.Join(email.CC, ", "))
+	m.inputs[bccInput-inputOffset].SetValue(strings.Join(email.BCC, ", "))
+	m.inputs[subjectInput-inputOffset].SetValue(email.Subject)
+	m.inputs[headersInput-inputOffset].SetValue(mailer.FormatHeaders(email.Headers))
+	m.attachments = append([]string{}, email.Attachments...)
+	m.inlineImages = append([]string(nil), email.InlineImages...)
+	m.zipped = append([]string(nil), email.Bundle...)
+
+	// The stored body already contains its signature
+	m.signature = ""
+	m.htmlSignature = ""
+	m.textarea.SetValue(email.Body)
+
+	for i, p := range composePriorities {
+		if string(p) == email.Priority {
+			m.priorityIdx = i
+		}
+	}
+	m.readReceipt = email.ReadReceipt
+	for i, mode := range composeSecurityModes {
+		if mode.sign == email.PGPSigned && mode.encrypt == email.PGPEncrypted {
+			m.pgpIdx = i
+		}
+		if mode.sign == email.SMIMESigned && mode.encrypt == email.SMIMEEncrypted {
+			m.smimeIdx = i
+		}
+	}
+	m.template = email.Template
+	return m.inspectAttachments(m.attachedPaths()...)
+}
+
+// formatCompletions lists path completion candidates, up to a screenful
+func formatCompletions(names []string) string {
+	const maxShown = 12
+	if len(names) > maxShown {
+		return strings.Join(names[:maxShown], "  ") + fmt.Sprintf("  … %d more", len(names)-maxShown)
+	}
+	return strings.Join(names, "  ")
+}
+
+// inspectAttachments detects the type and size of newly attached files in
+// the background, so neither Update nor rendering walks folders. Results
+// arrive as attachmentInspectedMsg and replace what was known about a path.
+func (m *ComposeModel) inspectAttachments(paths ...string) tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(paths))
+	for _, path := range paths {
+		m.files[path] = attachedFile{inspecting: true}
+		cmds = append(cmds, func() tea.Msg {
+			info, err := mailer.InspectFile(path)
+			return attachmentInspectedMsg{path: path, info: info, err: err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// attachedPaths returns all attachments, inline images and bundled files
+func (m ComposeModel) attachedPaths() []string {
+	return append(append(append([]string{}, m.attachments...), m.inlineImages...), m.zipped...)
+}
+
+// describeFile renders the detected type and size of an attached file
+func (m ComposeModel) describeFile(path string) string {
+	file, ok := m.files[path]
+	switch {
+	case !ok:
+		return ""
+	case file.inspecting:
+		return ui.MutedTextStyle.Render("(inspecting…)")
+	case file.err != nil:
+		return ui.ErrorTextStyle.Render("(" + file.err.Error() + ")")
+	case file.info.Folder:
+		return ui.MutedTextStyle.Render(fmt.Sprintf("(folder of %d files, %s, sent as %s)", file.info.Files, mailer.FormatSize(file.info.Size), mailer.ArchiveName(path)))
+	}
+	return ui.MutedTextStyle.Render("(" + file.info.ContentType + ", " + mailer.FormatSize(file.info.Size) + ")")
+}
+
+// renderMessageSize renders the estimated encoded message size against the
+// selected provider's limit
+func (m ComposeModel) renderMessageSize() string {
+	paths := m.attachedPaths()
+	files := make([]mailer.FileInfo, 0, len(paths))
+	for _, path := range paths {
+		file, ok := m.files[path]
+		if !ok || file.inspecting || file.err != nil {
+			return ""
+		}
+		files = append(files, file.info)
+	}
+	size := mailer.EstimateMessageSize(len(m.textarea.Value()), files)
+
+	limitMB := 0
+	if m.config != nil {
+		if pc, err := m.config.PrimaryProvider(m.selectedProvider); err == nil {
+			limitMB = pc.GetMaxMessageSizeMB()
+		}
+	}
+	if limitMB == 0 {
+		return ui.MutedTextStyle.Render("  Total: ~" + mailer.FormatSize(size) + " encoded")
+	}
+
+	text := fmt.Sprintf("  Total: ~%s of %d MB encoded", mailer.FormatSize(size), limitMB)
+	if size > int64(limitMB)*1024*1024 {
+		return ui.ErrorTextStyle.Render(text + " (over the provider limit)")
+	}
+	return ui.MutedTextStyle.Render(text)
 }
 
 // UpdateProviders updates the provider list from config
 func (m *ComposeModel) UpdateProviders(cfg *config.Config) {
 	m.config = cfg
-	m.providers = cfg.ListProviders()
+	m.providers = cfg.ListSendTargets()
 	m.selectedProvider = cfg.DefaultProvider
 
 	// Find index of default provider
@@ -638,6 +1414,153 @@ func (m *ComposeModel) UpdateProviders(cfg *config.Config) {
 			break
 		}
 	}
+	m.loadIdentities()
+}
+
+// loadIdentities loads the sender identities of the selected provider.
+// Failover chains use the identities of their first provider.
+func (m *ComposeModel) loadIdentities() {
+	m.identities = nil
+	m.identityIdx = 0
+	if m.config != nil && m.selectedProvider != "" {
+		if pc, err := m.config.PrimaryProvider(m.selectedProvider); err == nil {
+			m.identities = pc.GetIdentities()
+		}
+	}
+	m.applySignature()
+}
+
+// selectedIdentity returns the currently selected identity, if any
+func (m ComposeModel) selectedIdentity() *config.Identity {
+	if m.identityIdx < 0 || m.identityIdx >= len(m.identities) {
+		return nil
+	}
+	return &m.identities[m.identityIdx]
+}
+
+// applySignature replaces the signature block at the end of the body with
+// the signature of the selected identity
+func (m *ComposeModel) applySignature() {
+	body := m.textarea.Value()
+	if m.signature != "" {
+		body = strings.TrimSuffix(body, m.signature)
+	}
+
+	m.signature = ""
+	m.htmlSignature = ""
+	if sig := m.resolveSignature(); sig != nil {
+		variables := m.signatureVariables()
+		if sig.Text != "" {
+			m.signature = "\n\n-- \n" + storage.RenderText(sig.Text, variables)
+		}
+		m.htmlSignature = storage.RenderText(sig.HTML, variables)
+	}
+	m.textarea.SetValue(body + m.signature)
+}
+
+// setBody replaces the body and appends the current signature
+func (m *ComposeModel) setBody(body string) {
+	m.signature = ""
+	m.textarea.SetValue(body)
+	m.applySignature()
+}
+
+// resolveSignature returns the signature of the selected identity and provider
+func (m ComposeModel) resolveSignature() *config.Signature {
+	if m.config == nil || m.selectedProvider == "" {
+		return nil
+	}
+	pc, err := m.config.PrimaryProvider(m.selectedProvider)
+	if err != nil {
+		return nil
+	}
+	return m.config.ResolveSignature(pc, m.selectedIdentity())
+}
+
+// signatureVariables returns the values available to signature templates
+func (m ComposeModel) signatureVariables() map[string]string {
+	dateFormat := "02.01.2006"
+	if m.config != nil && m.config.DateFormat != "" {
+		dateFormat = m.config.DateFormat
+	}
+	variables := map[string]string{
+		"date":     time.Now().Format(dateFormat),
+		"provider": m.selectedProvider,
+	}
+	if identity := m.selectedIdentity(); identity != nil {
+		variables["identity"] = identity.Name
+		variables["from_name"] = identity.DisplayName
+		variables["from_email"] = identity.Address
+	}
+	return variables
+}
+
+// completeFromDomain appends a sender domain to a From value ending in "@",
+// or replaces a completed domain with the next allowed one
+func (m *ComposeModel) completeFromDomain() {
+	pc, err := m.config.PrimaryProvider(m.selectedProvider)
+	if err != nil {
+		return
+	}
+	domains := pc.Domains()
+	if len(domains) == 0 {
+		return
+	}
+
+	value := m.inputs[fromInput-inputOffset].Value()
+	at := strings.LastIndex(value, "@")
+	if at == -1 {
+		return
+	}
+	prefix, current := value[:at+1], strings.TrimSuffix(value[at+1:], ">")
+
+	// Cycle to the next domain if one is already complete, otherwise
+	// complete the first domain matching what has been typed
+	next := ""
+	for i, domain := range domains {
+		if strings.EqualFold(current, domain) {
+			next = domains[(i+1)%len(domains)]
+			break
+		}
+	}
+	if next == "" {
+		for _, domain := range domains {
+			if strings.HasPrefix(strings.ToLower(domain), strings.ToLower(current)) {
+				next = domain
+				break
+			}
+		}
+	}
+	if next == "" {
+		return
+	}
+
+	completed := prefix + next
+	if strings.Contains(value[:at], "<") {
+		completed += ">"
+	}
+	m.inputs[fromInput-inputOffset].SetValue(completed)
+	m.inputs[fromInput-inputOffset].CursorEnd()
+}
+
+// priorityName returns the display name of a priority
+func priorityName(p mailer.Priority) string {
+	if p == mailer.PriorityNormal {
+		return "normal"
+	}
+	return string(p)
+}
+
+// formatIdentity renders an identity for the identity selector
+func formatIdentity(identity config.Identity) string {
+	address := identity.Address
+	if identity.DisplayName != "" {
+		address = identity.DisplayName + " <" + identity.Address + ">"
+	}
+	if identity.Name == config.DefaultIdentityName {
+		return address
+	}
+	return identity.Name + ": " + address
 }
 
 // sendEmail creates a command to send the email
@@ -647,23 +1570,97 @@ func (m ComposeModel) sendEmail() tea.Cmd {
 		if err != nil {
 			return EmailValidationErrorMsg{Error: err.Error()}
 		}
-		return SendEmailMsg{
+		send := SendEmailMsg{
+			Data:         data,
+			ProviderName: m.selectedProvider,
+		}
+		if findings := runSendChecks(data, m.config.GetSendChecks()); len(findings) > 0 {
+			return SendChecksMsg{Send: send, Findings: findings}
+		}
+		return send
+	}
+}
+
+// renderFindings lists send check findings with what to do about them
+func renderFindings(findings []CheckFinding) string {
+	var b strings.Builder
+	for _, f := range findings {
+		if f.Block {
+			b.WriteString(ui.ErrorTextStyle.Render("✗ " + f.Message))
+		} else {
+			b.WriteString(ui.WarningTextStyle.Render("⚠ " + f.Message))
+		}
+		b.WriteString("\n")
+	}
+	if blocksSend(findings) {
+		b.WriteString(ui.MutedTextStyle.Render("Fix the problems marked ✗ to send."))
+	} else {
+		b.WriteString(ui.MutedTextStyle.Render("Press Enter to send anyway, or Esc to go back and edit."))
+	}
+	return b.String()
+}
+
+// previewEmail creates a command to preview the email
+func (m ComposeModel) previewEmail() tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.GetEmailData()
+		if err != nil {
+			return EmailValidationErrorMsg{Error: err.Error()}
+		}
+		return PreviewEmailMsg{
 			Data:         data,
 			ProviderName: m.selectedProvider,
 		}
 	}
 }
 
+// OpenPreview shows a built message
+func (m *ComposeModel) OpenPreview(preview *mailer.Preview) {
+	p := NewPreviewModel(preview, m.width, m.height)
+	m.preview = &p
+	m.showPreview = true
+}
+
+// closePreview hides the preview and removes its temporary files
+func (m *ComposeModel) closePreview() {
+	if m.preview != nil {
+		m.preview.Close()
+	}
+	m.preview = nil
+	m.showPreview = false
+}
+
 // EmailData represents the email composition data
 type EmailData struct {
-	From        string
-	FromName    string
-	To          []string
-	CC          []string
-	BCC         []string
-	Subject     string
-	Body        string
-	Attachments []string
+	From     string
+	FromName string
+	ReplyTo  string
+	To       []string
+	CC       []string
+	BCC      []string
+	Subject  string
+	Body     string
+	// Signature is the plain-text signature block at the end of Body, which
+	// the HTML version renders separately, using HTMLSignature if set
+	Signature     string
+	HTMLSignature string
+	Attachments   []string
+	InlineImages  []string
+	// Bundle lists files sent together as attachments.zip
+	Bundle          []string
+	ArchivePassword string
+	Headers         map[string]string
+	Priority        mailer.Priority
+	ReadReceipt     bool
+	PGPSign         bool
+	PGPEncrypt      bool
+	// PGPKeys are the public keys of the recipients to encrypt to
+	PGPKeys      []*mailer.PGPKey
+	SMIMESign    bool
+	SMIMEEncrypt bool
+	// SMIMECerts are the certificates of the recipients to encrypt to
+	SMIMECerts []*x509.Certificate
+	Template   string // Name of the template the message started from
 }
 
 // SendEmailMsg is sent when the user wants to send an email
@@ -672,6 +1669,12 @@ type SendEmailMsg struct {
 	ProviderName string
 }
 
+// PreviewEmailMsg is sent when the user wants to preview an email
+type PreviewEmailMsg struct {
+	Data         EmailData
+	ProviderName string
+}
+
 // EmailValidationErrorMsg is sent when email validation fails
 type EmailValidationErrorMsg struct {
 	Error string
@@ -708,11 +1711,9 @@ func parseFromField(input string, providerConfig *config.ProviderConfig) (email,
 		return "", "", nil
 	}
 
-	// Auto-append domain if input ends with @ and provider has a domain field
+	// Auto-append the provider's first sender domain if input ends with @
 	if strings.HasSuffix(input, "@") {
-		if providerConfig != nil && providerConfig.Mailgun != nil && providerConfig.Mailgun.Domain != "" {
-			input += providerConfig.Mailgun.Domain
-		}
+		input += senderDomain(providerConfig)
 	}
 
 	// Try to parse as a standard email address with optional name
@@ -741,9 +1742,7 @@ func parseFromField(input string, providerConfig *config.ProviderConfig) (email,
 
 			// Auto-append domain to email part if needed
 			if strings.HasSuffix(emailPart, "@") {
-				if providerConfig != nil && providerConfig.Mailgun != nil && providerConfig.Mailgun.Domain != "" {
-					emailPart += providerConfig.Mailgun.Domain
-				}
+				emailPart += senderDomain(providerConfig)
 			}
 
 			// Validate the email part
@@ -756,3 +1755,14 @@ func parseFromField(input string, providerConfig *config.ProviderConfig) (email,
 	// Return the original parse error
 	return "", "", fmt.Errorf("invalid email address '%s': %w", input, parseErr)
 }
+
+// senderDomain returns the domain used to complete "user@" addresses
+func senderDomain(providerConfig *config.ProviderConfig) string {
+	if providerConfig == nil {
+		return ""
+	}
+	if domains := providerConfig.Domains(); len(domains) > 0 {
+		return domains[0]
+	}
+	return ""
+}
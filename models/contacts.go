@@ -1,12 +1,17 @@
 package models
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 
+	"mailgloss/config"
+	"mailgloss/mailer"
 	"mailgloss/storage"
 	"mailgloss/ui"
 )
@@ -51,6 +56,8 @@ const (
 	contactEmail
 	contactNotes
 	contactTags
+	contactPGPKey
+	contactSMIMECert
 	contactSaveButton
 	contactCancelButton
 )
@@ -258,6 +265,14 @@ func (m *ContactsModel) initializeForm(contact *storage.Contact) {
 	m.inputs[contactEmail] = createInput("john@example.com", 200, 60)
 	m.inputs[contactNotes] = createInput("Optional notes", 500, 60)
 	m.inputs[contactTags] = createInput("work, client (comma-separated)", 500, 60)
+	m.inputs[contactPGPKey] = createInput("~/keys/john.asc (optional public key file)", 500, 60)
+	m.inputs[contactSMIMECert] = createInput("~/keys/john.pem (optional certificate file)", 500, 60)
+	if contact != nil && contact.PGPKey != "" {
+		m.inputs[contactPGPKey].Placeholder = "Keep the current key, or - to remove it"
+	}
+	if contact != nil && contact.SMIMECertificate != "" {
+		m.inputs[contactSMIMECert].Placeholder = "Keep the current certificate, or - to remove it"
+	}
 
 	if contact != nil {
 		m.inputs[contactName].SetValue(contact.Name)
@@ -291,6 +306,55 @@ func (m *ContactsModel) saveContact() tea.Cmd {
 			contact.Tags = tags
 		}
 
+		var existing *storage.Contact
+		if m.isEditing {
+			existing = m.contacts.Get(m.editingID)
+		}
+
+		// Read the public key file, keeping the current key if none is given
+		switch keyPath := strings.TrimSpace(m.inputs[contactPGPKey].Value()); keyPath {
+		case "":
+			if existing != nil {
+				contact.PGPKey = existing.PGPKey
+			}
+		case "-":
+		default:
+			data, err := os.ReadFile(config.ExpandHome(keyPath))
+			if err != nil {
+				return ContactErrorMsg{Error: fmt.Sprintf("Failed to read PGP key: %v", err)}
+			}
+			armored, keys, err := mailer.ArmorPGPKeys(data)
+			if err != nil {
+				return ContactErrorMsg{Error: fmt.Sprintf("Invalid PGP key: %v", err)}
+			}
+			if mailer.FindPGPKey(keys, contact.Email) == nil {
+				return ContactErrorMsg{Error: fmt.Sprintf("The PGP key has no user ID for %s or can't encrypt", contact.Email)}
+			}
+			contact.PGPKey = armored
+		}
+
+		// Same for the S/MIME certificate
+		switch certPath := strings.TrimSpace(m.inputs[contactSMIMECert].Value()); certPath {
+		case "":
+			if existing != nil {
+				contact.SMIMECertificate = existing.SMIMECertificate
+			}
+		case "-":
+		default:
+			data, err := os.ReadFile(config.ExpandHome(certPath))
+			if err != nil {
+				return ContactErrorMsg{Error: fmt.Sprintf("Failed to read S/MIME certificate: %v", err)}
+			}
+			cert, err := mailer.ParseSMIMECertificate(data)
+			if err != nil {
+				return ContactErrorMsg{Error: fmt.Sprintf("Invalid S/MIME certificate: %v", err)}
+			}
+			if mailer.FindSMIMECertificate([]*x509.Certificate{cert}, contact.Email) == nil {
+				return ContactErrorMsg{Error: fmt.Sprintf("The S/MIME certificate isn't issued to %s, has expired or can't encrypt", contact.Email)}
+			}
+			contact.SMIMECertificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+		}
+
 		// Validation
 		if contact.Name == "" {
 			return ContactErrorMsg{Error: "Name is required"}
@@ -357,6 +421,9 @@ func (m ContactsModel) renderListView() string {
 			if len(contact.Tags) > 0 {
 				display += ui.LabelStyle.Render(fmt.Sprintf(" [%s]", strings.Join(contact.Tags, ", ")))
 			}
+			if contact.PGPKey != "" || contact.SMIMECertificate != "" {
+				display += " 🔒"
+			}
 
 			b.WriteString(style.Render(prefix + display))
 			b.WriteString("\n")
@@ -412,6 +479,39 @@ func (m ContactsModel) renderDetailView() string {
 		b.WriteString("\n\n")
 	}
 
+	if contact.PGPKey != "" {
+		b.WriteString(ui.LabelStyle.Render("PGP Key:"))
+		b.WriteString("\n")
+		if keys, err := mailer.ReadPGPKeys([]byte(contact.PGPKey)); err != nil || len(keys) == 0 {
+			b.WriteString(ui.ErrorStyle.Render("Unreadable key"))
+		} else {
+			for i, key := range keys {
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				b.WriteString(ui.DisplayLabelStyle.Render(key.Fingerprint()))
+				for _, uid := range key.UserIDs {
+					b.WriteString("\n")
+					b.WriteString(ui.LabelStyle.Render("  " + uid))
+				}
+			}
+		}
+		b.WriteString("\n\n")
+	}
+
+	if contact.SMIMECertificate != "" {
+		b.WriteString(ui.LabelStyle.Render("S/MIME Certificate:"))
+		b.WriteString("\n")
+		if cert, err := mailer.ParseSMIMECertificate([]byte(contact.SMIMECertificate)); err != nil {
+			b.WriteString(ui.ErrorStyle.Render("Unreadable certificate"))
+		} else {
+			b.WriteString(ui.DisplayLabelStyle.Render(strings.Join(mailer.SMIMEAddresses(cert), ", ")))
+			b.WriteString("\n")
+			b.WriteString(ui.LabelStyle.Render(fmt.Sprintf("  Issued by %s, valid until %s", cert.Issuer.CommonName, cert.NotAfter.Format("2006-01-02"))))
+		}
+		b.WriteString("\n\n")
+	}
+
 	b.WriteString(ui.LabelStyle.Render("Created:"))
 	b.WriteString("\n")
 	b.WriteString(ui.DisplayLabelStyle.Render(contact.CreatedAt.Format("2006-01-02 15:04:05")))
@@ -442,6 +542,8 @@ func (m ContactsModel) renderFormView(title string) string {
 	m.renderField(&b, "Email", contactEmail)
 	m.renderField(&b, "Notes", contactNotes)
 	m.renderField(&b, "Tags", contactTags)
+	m.renderField(&b, "PGP Key", contactPGPKey)
+	m.renderField(&b, "S/MIME Cert", contactSMIMECert)
 
 	b.WriteString("\n")
 
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"mailgloss/config"
+	"mailgloss/events"
+	"mailgloss/logger"
+	"mailgloss/storage"
+)
+
+// DeliveryEventsMsg carries the events of a provider webhook request
+type DeliveryEventsMsg struct {
+	Events []events.Event
+}
+
+// waitForDeliveryEvents returns a command that waits for the next webhook
+// request with delivery events, or until the receiver is closed
+func waitForDeliveryEvents(receiver *events.Receiver) tea.Cmd {
+	if receiver == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		select {
+		case received := <-receiver.Events():
+			return DeliveryEventsMsg{Events: received}
+		case <-receiver.Done():
+			return nil
+		}
+	}
+}
+
+// startReceiver starts the delivery event receiver if it is configured
+func (m *AppModel) startReceiver() {
+	if m.config.DeliveryEvents == nil {
+		return
+	}
+	receiver, err := events.NewReceiver(m.config.DeliveryEvents)
+	if err == nil {
+		err = receiver.Start()
+	}
+	if err != nil {
+		logger.Warn("Delivery events disabled", "error", err)
+		m.errorMsg = "Delivery events disabled: " + err.Error()
+		return
+	}
+	m.receiver = receiver
+}
+
+// stopReceiver closes the delivery event receiver if it is running
+func (m *AppModel) stopReceiver() {
+	if m.receiver == nil {
+		return
+	}
+	if err := m.receiver.Close(); err != nil {
+		logger.Warn("Failed to stop the delivery event receiver", "error", err)
+	}
+	m.receiver = nil
+}
+
+// restartReceiver starts the receiver again with the delivery event
+// settings of cfg if they changed or it isn't running, and returns the
+// command waiting for its events
+func (m *AppModel) restartReceiver(cfg *config.Config) tea.Cmd {
+	if m.receiver != nil && reflect.DeepEqual(m.config.DeliveryEvents, cfg.DeliveryEvents) {
+		return nil
+	}
+	m.stopReceiver()
+	m.config = cfg
+	m.startReceiver()
+	return waitForDeliveryEvents(m.receiver)
+}
+
+// recordDeliveryEvents adds delivery events to the history entries of the
+// emails they belong to
+func (m *AppModel) recordDeliveryEvents(received []events.Event) tea.Cmd {
+	entries := make([]storage.DeliveryEvent, len(received))
+	for i, e := range received {
+		entries[i] = storage.DeliveryEvent{
+			Type:      e.Type,
+			Recipient: e.Recipient,
+			Time:      e.Time,
+			Detail:    e.Detail,
+			MessageID: e.MessageID,
+		}
+	}
+
+	cmds := []tea.Cmd{waitForDeliveryEvents(m.receiver)}
+	added, err := m.history.AddEvents(entries)
+	if err != nil {
+		logger.Error("Failed to save delivery events", "error", err)
+	}
+	if added > 0 {
+		cmds = append(cmds, func() tea.Msg {
+			return RefreshHistoryMsg{}
+		})
+	} else {
+		logger.Debug("Delivery events without a matching history entry", "count", len(received))
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// draftHeaders are the header lines written above the body for the editor,
+// in order, with the compose field they map to
+var draftHeaders = []struct {
+	name  string
+	field int
+}{
+	{"From", fromInput},
+	{"Reply-To", replyToInput},
+	{"To", toInput},
+	{"Cc", ccInput},
+	{"Bcc", bccInput},
+	{"Subject", subjectInput},
+}
+
+// EditorFinishedMsg is sent when the external editor exits
+type EditorFinishedMsg struct {
+	Path string
+	Err  error
+}
+
+// editorCommand returns the user's editor from $VISUAL or $EDITOR, falling
+// back to vi. The variables may include arguments, e.g. "code --wait".
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if strings.TrimSpace(editor) == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// openEditor writes the draft to a temporary file and suspends the TUI
+// while the external editor runs. A draft that failed to load is reopened
+// instead, so it can be fixed.
+func (m ComposeModel) openEditor() (tea.Cmd, error) {
+	if m.draftPath != "" {
+		path := m.draftPath
+		return tea.ExecProcess(editorCommand(path), func(err error) tea.Msg {
+			return EditorFinishedMsg{Path: path, Err: err}
+		}), nil
+	}
+
+	f, err := os.CreateTemp("", "mailgloss-*.eml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create draft file: %w", err)
+	}
+	path := f.Name()
+	_, err = f.WriteString(m.draft())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write draft file: %w", err)
+	}
+
+	return tea.ExecProcess(editorCommand(path), func(err error) tea.Msg {
+		return EditorFinishedMsg{Path: path, Err: err}
+	}), nil
+}
+
+// draft renders the header fields and body as an editable message
+func (m ComposeModel) draft() string {
+	var b strings.Builder
+	for _, h := range draftHeaders {
+		b.WriteString(h.name + ": " + m.inputs[h.field-inputOffset].Value() + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(m.textarea.Value())
+	return b.String()
+}
+
+// loadDraft reads the edited draft back into the form. Header lines are
+// optional: without them the whole file is the body. Fields whose header
+// line was removed are cleared.
+func (m *ComposeModel) loadDraft(content string) error {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	fields, body, hasHeaders, err := parseDraft(content)
+	if err != nil {
+		return err
+	}
+	if !hasHeaders {
+		m.textarea.SetValue(strings.TrimSuffix(content, "\n"))
+		return nil
+	}
+
+	for _, h := range draftHeaders {
+		m.inputs[h.field-inputOffset].SetValue(fields[h.field])
+	}
+	m.textarea.SetValue(strings.TrimSuffix(body, "\n"))
+	return nil
+}
+
+// parseDraft splits a draft into the compose fields of its header lines and
+// the body. The draft has headers when its first block, up to a blank line,
+// contains a known header line; every line of the block must then be one,
+// or continue the previous one with leading whitespace. A body starting
+// with something like "Note: ..." is not mistaken for headers.
+func parseDraft(content string) (map[int]string, string, bool, error) {
+	headerBlock, body, found := strings.Cut(content, "\n\n")
+	if !found || strings.TrimSpace(headerBlock) == "" {
+		return nil, "", false, nil
+	}
+	lines := strings.Split(headerBlock, "\n")
+	if !slices.ContainsFunc(lines, func(line string) bool {
+		name, _, ok := strings.Cut(line, ":")
+		_, known := draftField(strings.TrimSpace(name))
+		return ok && known
+	}) {
+		return nil, "", false, nil
+	}
+
+	fields := map[int]string{}
+	last := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" && (line[0] == ' ' || line[0] == '\t') && last >= 0 {
+			fields[last] = strings.TrimSpace(fields[last] + " " + strings.TrimSpace(line))
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		field, known := draftField(strings.TrimSpace(name))
+		if !ok || !known {
+			return nil, "", false, fmt.Errorf("unknown header line %q", line)
+		}
+		fields[field] = strings.TrimSpace(value)
+		last = field
+	}
+	return fields, body, true, nil
+}
+
+// draftField returns the compose field of a draft header name
+func draftField(name string) (int, bool) {
+	for _, h := range draftHeaders {
+		if strings.EqualFold(name, h.name) {
+			return h.field, true
+		}
+	}
+	return 0, false
+}
+
+// removeDraft deletes an edited draft file and forgets it if it was kept
+func (m *ComposeModel) removeDraft(path string) {
+	if path == "" {
+		return
+	}
+	os.Remove(path)
+	if m.draftPath == path {
+		m.draftPath = ""
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"testing"
+
+	"mailgloss/config"
+)
+
+func TestParseDraft(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		fields     map[int]string
+		body       string
+		hasHeaders bool
+		wantErr    bool
+	}{
+		{
+			name:       "edited to and subject",
+			content:    "From: \nTo: ada@example.com, bob@example.com\nSubject: New plan\n\nHello\n",
+			fields:     map[int]string{fromInput: "", toInput: "ada@example.com, bob@example.com", subjectInput: "New plan"},
+			body:       "Hello\n",
+			hasHeaders: true,
+		},
+		{
+			name:       "case and continuation lines",
+			content:    "to: ada@example.com,\n  bob@example.com\nSUBJECT: Hi\n\nBody",
+			fields:     map[int]string{toInput: "ada@example.com, bob@example.com", subjectInput: "Hi"},
+			body:       "Body",
+			hasHeaders: true,
+		},
+		{
+			name:    "unknown header",
+			content: "To: ada@example.com\nX-Campaign: spring\n\nBody",
+			wantErr: true,
+		},
+		{
+			name:    "body without headers",
+			content: "Just a body\nover two lines\n",
+		},
+		{
+			name:    "body starting like a header",
+			content: "Note: bring snacks\n\nSee you there",
+		},
+		{
+			name:       "cleared field",
+			content:    "To: ada@example.com\nCc:\n\nBody",
+			fields:     map[int]string{toInput: "ada@example.com", ccInput: ""},
+			body:       "Body",
+			hasHeaders: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, body, hasHeaders, err := parseDraft(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDraft() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if hasHeaders != tt.hasHeaders {
+				t.Fatalf("parseDraft() hasHeaders = %v, want %v", hasHeaders, tt.hasHeaders)
+			}
+			if body != tt.body {
+				t.Errorf("parseDraft() body = %q, want %q", body, tt.body)
+			}
+			if len(fields) != len(tt.fields) {
+				t.Errorf("parseDraft() fields = %v, want %v", fields, tt.fields)
+			}
+			for field, value := range tt.fields {
+				if got, ok := fields[field]; !ok || got != value {
+					t.Errorf("parseDraft() field %d = %q, want %q", field, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDraft(t *testing.T) {
+	m := NewComposeModel(&config.Config{}, nil, nil)
+	m.inputs[toInput-inputOffset].SetValue("old@example.com")
+	m.inputs[ccInput-inputOffset].SetValue("cc@example.com")
+	m.inputs[bccInput-inputOffset].SetValue("bcc@example.com")
+	m.inputs[subjectInput-inputOffset].SetValue("Old subject")
+	m.textarea.SetValue("Old body")
+
+	// The Bcc line was removed and Cc emptied, both are cleared
+	if err := m.loadDraft("To: new@example.com\r\nCc: \r\nSubject: New subject\r\n\r\nNew body\r\n"); err != nil {
+		t.Fatalf("loadDraft() error = %v", err)
+	}
+	want := map[int]string{
+		toInput:      "new@example.com",
+		ccInput:      "",
+		bccInput:     "",
+		subjectInput: "New subject",
+	}
+	for field, value := range want {
+		if got := m.inputs[field-inputOffset].Value(); got != value {
+			t.Errorf("field %d = %q, want %q", field, got, value)
+		}
+	}
+	if got := m.textarea.Value(); got != "New body" {
+		t.Errorf("body = %q, want %q", got, "New body")
+	}
+
+	// Without headers the whole draft is the body and the fields are kept
+	if err := m.loadDraft("Only a body\n"); err != nil {
+		t.Fatalf("loadDraft() error = %v", err)
+	}
+	if got := m.inputs[toInput-inputOffset].Value(); got != "new@example.com" {
+		t.Errorf("To = %q, want it kept", got)
+	}
+	if got := m.textarea.Value(); got != "Only a body" {
+		t.Errorf("body = %q, want %q", got, "Only a body")
+	}
+
+	// An unknown header is reported and nothing changes
+	if err := m.loadDraft("To: other@example.com\nX-Campaign: spring\n\nBody"); err == nil {
+		t.Error("loadDraft() accepted an unknown header")
+	}
+	if got := m.inputs[toInput-inputOffset].Value(); got != "new@example.com" {
+		t.Errorf("To = %q after a failed load, want it kept", got)
+	}
+}
@@ -1,22 +1,58 @@
 package models
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
+	"mailgloss/mailer"
 	"mailgloss/ui"
 )
 
+const (
+	// maxIndexedFiles bounds the fuzzy finder's walk of large trees
+	maxIndexedFiles = 20000
+	// maxSearchResults is the number of fuzzy matches shown
+	maxSearchResults = 200
+	// previewBytes is how much of a text file the preview reads
+	previewBytes = 4096
+	// previewLines is the number of lines shown in the preview
+	previewLines = 15
+)
+
+// fileEntry is a file or directory listed in the file selector
+type fileEntry struct {
+	name string // Name relative to the current directory
+	path string
+	info fs.FileInfo
+}
+
+// filePreview is the preview of the entry under the cursor, loaded when
+// the cursor moves
+type filePreview struct {
+	path        string
+	dir         bool
+	entries     int    // Entries of a directory, -1 if unreadable
+	head        []byte // Start of a file
+	contentType string // Detected type of a binary file
+	err         error
+}
+
 // FileSelectModel represents the file selector interface
 type FileSelectModel struct {
 	currentPath string
-	entries     []fs.DirEntry
+	entries     []fileEntry
 	selectedIdx int
 	offset      int
 	height      int
@@ -24,6 +60,24 @@ type FileSelectModel struct {
 	showHidden  bool
 	filterExt   []string // Optional file extension filters (e.g., []string{".pdf", ".txt"})
 	err         error
+	preview     filePreview
+
+	// Multi-selection, in the order files were marked
+	marked []string
+
+	// Fuzzy finder across subdirectories of the current directory
+	searching bool
+	search    textinput.Model
+	indexing  bool        // Whether the index is still being built
+	index     []fileEntry // Files under currentPath, built when a search starts
+	truncated bool        // Whether the index hit maxIndexedFiles
+}
+
+// fileIndexMsg carries the fuzzy finder's index of a directory
+type fileIndexMsg struct {
+	root      string
+	index     []fileEntry
+	truncated bool
 }
 
 // NewFileSelectModel creates a new file selector model
@@ -37,6 +91,12 @@ func NewFileSelectModel(startPath string) FileSelectModel {
 		}
 	}
 
+	search := textinput.New()
+	search.Placeholder = "type to fuzzy find"
+	search.Prompt = "/ "
+	search.CharLimit = 200
+	search.Width = 50
+
 	m := FileSelectModel{
 		currentPath: startPath,
 		selectedIdx: 0,
@@ -44,9 +104,11 @@ func NewFileSelectModel(startPath string) FileSelectModel {
 		height:      20,
 		showHidden:  false,
 		filterExt:   []string{},
+		search:      search,
 	}
 
 	m.loadDirectory()
+	m.loadPreview()
 	return m
 }
 
@@ -55,58 +117,49 @@ func (m FileSelectModel) Init() tea.Cmd {
 	return nil
 }
 
-// Update handles messages for the file selector
+// Update handles messages for the file selector and loads the preview of
+// the entry the cursor moved to
 func (m FileSelectModel) Update(msg tea.Msg) (FileSelectModel, tea.Cmd) {
+	m, cmd := m.update(msg)
+	m.loadPreview()
+	return m, cmd
+}
+
+// update handles a message for Update
+func (m FileSelectModel) update(msg tea.Msg) (FileSelectModel, tea.Cmd) {
 	switch msg := msg.(type) {
+	case fileIndexMsg:
+		if m.searching && m.indexing && msg.root == m.currentPath {
+			m.indexing = false
+			m.index, m.truncated = msg.index, msg.truncated
+			m.applySearch()
+		}
+
 	case tea.KeyMsg:
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+
 		switch msg.String() {
 		case "up", "k":
-			if m.selectedIdx > 0 {
-				m.selectedIdx--
-				// Adjust offset for scrolling
-				if m.selectedIdx < m.offset {
-					m.offset = m.selectedIdx
-				}
-			}
+			m.moveSelection(-1)
 
 		case "down", "j":
-			if m.selectedIdx < len(m.entries)-1 {
-				m.selectedIdx++
-				// Adjust offset for scrolling
-				if m.selectedIdx >= m.offset+m.height {
-					m.offset = m.selectedIdx - m.height + 1
-				}
-			}
+			m.moveSelection(1)
 
-		case "enter", " ":
-			// Enter directory or select file
-			if len(m.entries) == 0 {
-				return m, nil
-			}
-			entry := m.entries[m.selectedIdx]
-			if entry.IsDir() {
-				// Navigate into directory
-				newPath := filepath.Join(m.currentPath, entry.Name())
-				m.currentPath = newPath
-				m.selectedIdx = 0
-				m.offset = 0
-				m.loadDirectory()
-			} else {
-				// File selected - return command with selected file path
-				fullPath := filepath.Join(m.currentPath, entry.Name())
-				return m, func() tea.Msg {
-					return FileSelectedMsg{Path: fullPath}
-				}
-			}
+		case " ":
+			// Mark or unmark the entry and move on
+			m.toggleMarked()
+			m.moveSelection(1)
+
+		case "enter":
+			return m, m.choose()
 
 		case "backspace", "h":
 			// Go up one directory
 			parent := filepath.Dir(m.currentPath)
 			if parent != m.currentPath {
-				m.currentPath = parent
-				m.selectedIdx = 0
-				m.offset = 0
-				m.loadDirectory()
+				m.changeDirectory(parent)
 			}
 
 		case ".":
@@ -120,18 +173,16 @@ func (m FileSelectModel) Update(msg tea.Msg) (FileSelectModel, tea.Cmd) {
 			// Go to home directory
 			home, err := os.UserHomeDir()
 			if err == nil {
-				m.currentPath = home
-				m.selectedIdx = 0
-				m.offset = 0
-				m.loadDirectory()
+				m.changeDirectory(home)
 			}
 
-		case "/":
+		case "r":
 			// Go to root directory
-			m.currentPath = "/"
-			m.selectedIdx = 0
-			m.offset = 0
-			m.loadDirectory()
+			m.changeDirectory("/")
+
+		case "/":
+			// Start the fuzzy finder
+			return m, tea.Batch(m.startSearch(), textinput.Blink)
 
 		case "g":
 			// Go to top
@@ -149,18 +200,273 @@ func (m FileSelectModel) Update(msg tea.Msg) (FileSelectModel, tea.Cmd) {
 		}
 
 	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height - 10 // Reserve space for header and footer
+		m.SetSize(msg.Width, msg.Height)
 	}
 
 	return m, nil
 }
 
+// updateSearch handles keys while the fuzzy finder is open
+func (m FileSelectModel) updateSearch(msg tea.KeyMsg) (FileSelectModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.stopSearch()
+		return m, nil
+	case "up", "ctrl+k":
+		m.moveSelection(-1)
+		return m, nil
+	case "down", "ctrl+j":
+		m.moveSelection(1)
+		return m, nil
+	case "tab":
+		m.toggleMarked()
+		m.moveSelection(1)
+		return m, nil
+	case "enter":
+		return m, m.choose()
+	}
+
+	var cmd tea.Cmd
+	query := m.search.Value()
+	m.search, cmd = m.search.Update(msg)
+	if m.search.Value() != query {
+		m.applySearch()
+	}
+	return m, cmd
+}
+
+// IsSearching reports whether the fuzzy finder has the keyboard, in which
+// case Esc closes the finder rather than the selector
+func (m FileSelectModel) IsSearching() bool {
+	return m.searching
+}
+
+// SetSize sets the size available to the file selector
+func (m *FileSelectModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height - 12 // Reserve space for header and footer
+	if m.height < 5 {
+		m.height = 5
+	}
+}
+
+// moveSelection moves the cursor and scrolls to keep it visible
+func (m *FileSelectModel) moveSelection(delta int) {
+	idx := m.selectedIdx + delta
+	if idx < 0 || idx >= len(m.entries) {
+		return
+	}
+	m.selectedIdx = idx
+	if m.selectedIdx < m.offset {
+		m.offset = m.selectedIdx
+	}
+	if m.selectedIdx >= m.offset+m.height {
+		m.offset = m.selectedIdx - m.height + 1
+	}
+}
+
+// choose returns the marked files, or opens or selects the entry under the
+// cursor when nothing is marked
+func (m *FileSelectModel) choose() tea.Cmd {
+	if len(m.marked) > 0 {
+		paths := append([]string(nil), m.marked...)
+		return func() tea.Msg {
+			return FileSelectedMsg{Paths: paths}
+		}
+	}
+	if len(m.entries) == 0 {
+		return nil
+	}
+
+	entry := m.entries[m.selectedIdx]
+	if entry.info.IsDir() {
+		m.stopSearch()
+		m.changeDirectory(entry.path)
+		return nil
+	}
+	return func() tea.Msg {
+		return FileSelectedMsg{Paths: []string{entry.path}}
+	}
+}
+
+// toggleMarked marks or unmarks the entry under the cursor
+func (m *FileSelectModel) toggleMarked() {
+	if len(m.entries) == 0 {
+		return
+	}
+	path := m.entries[m.selectedIdx].path
+	for i, p := range m.marked {
+		if p == path {
+			m.marked = append(m.marked[:i], m.marked[i+1:]...)
+			return
+		}
+	}
+	m.marked = append(m.marked, path)
+}
+
+// isMarked reports whether a path is marked
+func (m FileSelectModel) isMarked(path string) bool {
+	for _, p := range m.marked {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// changeDirectory lists another directory. Marks are kept.
+func (m *FileSelectModel) changeDirectory(path string) {
+	m.currentPath = path
+	m.selectedIdx = 0
+	m.offset = 0
+	m.loadDirectory()
+}
+
+// startSearch opens the fuzzy finder and returns the command indexing the
+// files below the current directory
+func (m *FileSelectModel) startSearch() tea.Cmd {
+	m.searching = true
+	m.indexing = true
+	m.search.SetValue("")
+	m.search.Focus()
+	m.index, m.truncated = nil, false
+	m.applySearch()
+
+	root := *m
+	return func() tea.Msg {
+		index, truncated := root.buildIndex()
+		return fileIndexMsg{root: root.currentPath, index: index, truncated: truncated}
+	}
+}
+
+// stopSearch closes the fuzzy finder and lists the current directory again
+func (m *FileSelectModel) stopSearch() {
+	if !m.searching {
+		return
+	}
+	m.searching = false
+	m.indexing = false
+	m.search.Blur()
+	m.index = nil
+	m.selectedIdx = 0
+	m.offset = 0
+	m.loadDirectory()
+}
+
+// buildIndex walks the current directory for the fuzzy finder, skipping
+// hidden entries unless shown
+func (m FileSelectModel) buildIndex() ([]fileEntry, bool) {
+	var index []fileEntry
+	truncated := false
+	filepath.WalkDir(m.currentPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == m.currentPath {
+			return nil // Skip unreadable directories
+		}
+		if !m.showHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !m.matchesFilter(d.Name()) {
+			return nil
+		}
+		if len(index) >= maxIndexedFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		name, _ := filepath.Rel(m.currentPath, path)
+		index = append(index, fileEntry{name: name, path: path, info: info})
+		return nil
+	})
+	return index, truncated
+}
+
+// applySearch ranks the indexed files against the query
+func (m *FileSelectModel) applySearch() {
+	query := strings.TrimSpace(m.search.Value())
+	m.selectedIdx = 0
+	m.offset = 0
+
+	type match struct {
+		entry fileEntry
+		score int
+	}
+	var matches []match
+	for _, entry := range m.index {
+		if score, ok := fuzzyMatch(query, entry.name); ok {
+			matches = append(matches, match{entry, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].entry.name < matches[j].entry.name
+	})
+	if len(matches) > maxSearchResults {
+		matches = matches[:maxSearchResults]
+	}
+
+	m.entries = make([]fileEntry, len(matches))
+	for i, match := range matches {
+		m.entries[i] = match.entry
+	}
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in order in
+// text, ignoring case. Higher scores favour consecutive characters, matches
+// at the start of path segments and words, and short names.
+func fuzzyMatch(pattern, text string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(text)
+	score := 0
+	pi := 0
+	prevMatch := -2
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if unicode.ToLower(t[ti]) != p[pi] {
+			continue
+		}
+		score++
+		if ti == prevMatch+1 {
+			score += 5
+		}
+		if ti == 0 || strings.ContainsRune("/\\_-. ", t[ti-1]) {
+			score += 8
+		} else if unicode.IsUpper(t[ti]) && unicode.IsLower(t[ti-1]) {
+			score += 4
+		}
+		prevMatch = ti
+		pi++
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+
+	// Prefer matches in the file name over matches in directory names
+	if base := strings.ToLower(filepath.Base(text)); strings.Contains(base, string(p)) {
+		score += 20
+	}
+	return score - len(t)/10, true
+}
+
 // View renders the file selector
 func (m FileSelectModel) View() string {
 	var b strings.Builder
 
-	b.WriteString(ui.TitleStyle.Render("Select File"))
+	title := "Select Files"
+	if len(m.marked) > 0 {
+		title = fmt.Sprintf("Select Files (%d marked)", len(m.marked))
+	}
+	b.WriteString(ui.TitleStyle.Render(title))
 	b.WriteString("\n\n")
 
 	// Show current path
@@ -169,102 +475,322 @@ func (m FileSelectModel) View() string {
 	b.WriteString(ui.InfoStyle.Render(m.currentPath))
 	b.WriteString("\n\n")
 
+	if m.searching {
+		b.WriteString(ui.FocusedInputStyle.Render(m.search.View()))
+		if m.indexing {
+			b.WriteString(" ")
+			b.WriteString(ui.MutedTextStyle.Render("(indexing…)"))
+		} else if m.truncated {
+			b.WriteString(" ")
+			b.WriteString(ui.WarningTextStyle.Render(fmt.Sprintf("(first %d files only)", maxIndexedFiles)))
+		}
+		b.WriteString("\n\n")
+	}
+
 	// Show error if any
 	if m.err != nil {
 		b.WriteString(ui.ErrorStyle.Render("Error: " + m.err.Error()))
 		b.WriteString("\n\n")
 	}
 
-	// Show entries
+	// Show entries next to a preview of the one under the cursor
 	if len(m.entries) == 0 {
-		b.WriteString(ui.WarningStyle.Render("Empty directory"))
+		if m.indexing {
+			b.WriteString(ui.MutedTextStyle.Render("Indexing files…"))
+		} else if m.searching {
+			b.WriteString(ui.WarningStyle.Render("No matches"))
+		} else {
+			b.WriteString(ui.WarningStyle.Render("Empty directory"))
+		}
 		b.WriteString("\n")
 	} else {
-		// Calculate visible range
-		start := m.offset
-		end := m.offset + m.height
-		if end > len(m.entries) {
-			end = len(m.entries)
+		listWidth, previewWidth := m.columnWidths()
+		list := m.renderList(listWidth)
+		if previewWidth > 0 {
+			preview := lipgloss.NewStyle().
+				Width(previewWidth).
+				PaddingLeft(2).
+				BorderStyle(lipgloss.NormalBorder()).
+				BorderLeft(true).
+				BorderForeground(ui.Muted).
+				Render(m.renderPreview(previewWidth - 3))
+			b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, list, preview))
+		} else {
+			b.WriteString(list)
 		}
+	}
 
-		// Render visible entries
-		for i := start; i < end; i++ {
-			entry := m.entries[i]
-			name := entry.Name()
+	b.WriteString("\n\n")
+	if m.searching {
+		b.WriteString(ui.RenderHelp(
+			"↑/↓", "navigate",
+			"Tab", "mark",
+			"Enter", "select/open",
+			"Esc", "close finder",
+		))
+	} else {
+		b.WriteString(ui.RenderHelp(
+			"↑/↓", "navigate",
+			"Space", "mark",
+			"Enter", "select/open",
+			"/", "fuzzy find",
+			"Backspace", "parent dir",
+			"~", "home",
+			"r", "root",
+			".", "toggle hidden",
+			"Esc", "cancel",
+		))
+	}
 
-			// Add indicator for directories
-			if entry.IsDir() {
-				name = name + "/"
-			}
+	return b.String()
+}
 
-			// Style based on selection
-			if i == m.selectedIdx {
-				b.WriteString(ui.SelectedItemStyle.Render("▸ " + name))
-			} else {
-				b.WriteString(ui.ListItemStyle.Render("  " + name))
-			}
-			b.WriteString("\n")
+// columnWidths splits the available width between the list and the preview
+func (m FileSelectModel) columnWidths() (int, int) {
+	width := m.width
+	if width <= 0 {
+		width = 100
+	}
+	if width < 80 {
+		return width, 0 // Too narrow for a preview
+	}
+	list := width * 3 / 5
+	return list, width - list - 4
+}
+
+// renderList renders the visible entries with size and modification time
+func (m FileSelectModel) renderList(width int) string {
+	var b strings.Builder
+
+	start := m.offset
+	end := m.offset + m.height
+	if end > len(m.entries) {
+		end = len(m.entries)
+	}
+
+	const detailsWidth = 28 // Size and modification time columns
+	nameWidth := width - detailsWidth - 6
+	if nameWidth < 10 {
+		nameWidth = 10
+	}
+
+	for i := start; i < end; i++ {
+		entry := m.entries[i]
+		name := entry.name
+		size := mailer.FormatSize(entry.info.Size())
+		if entry.info.IsDir() {
+			name += "/"
+			size = "-"
 		}
+		name = truncateLeft(name, nameWidth)
 
-		// Show scroll indicator
-		if len(m.entries) > m.height {
-			scrollPos := float64(m.selectedIdx) / float64(len(m.entries)-1)
-			b.WriteString("\n")
-			b.WriteString(ui.HelpStyle.Render(
-				fmt.Sprintf("Showing %d-%d of %d (%.0f%%)",
-					start+1, end, len(m.entries), scrollPos*100),
-			))
+		mark := " "
+		if m.isMarked(entry.path) {
+			mark = "●"
 		}
+		line := fmt.Sprintf("%s %-*s %9s  %s", mark, nameWidth, name, size, entry.info.ModTime().Format("2006-01-02 15:04"))
+
+		// Style based on selection
+		if i == m.selectedIdx {
+			b.WriteString(ui.SelectedItemStyle.Render("▸" + line))
+		} else {
+			b.WriteString(ui.ListItemStyle.Render(line))
+		}
+		b.WriteString("\n")
 	}
 
-	b.WriteString("\n\n")
-	b.WriteString(ui.RenderHelp(
-		"↑/↓", "navigate",
-		"Enter", "select/open",
-		"Backspace", "parent dir",
-		"~", "home",
-		"/", "root",
-		".", "toggle hidden",
-		"Esc", "cancel",
-	))
+	// Show scroll indicator
+	if len(m.entries) > m.height {
+		scrollPos := float64(m.selectedIdx) / float64(len(m.entries)-1)
+		b.WriteString("\n")
+		b.WriteString(ui.HelpStyle.Render(
+			fmt.Sprintf("Showing %d-%d of %d (%.0f%%)",
+				start+1, end, len(m.entries), scrollPos*100),
+		))
+	}
 
 	return b.String()
 }
 
+// loadPreview reads the entry under the cursor for the preview, unless it
+// is already loaded
+func (m *FileSelectModel) loadPreview() {
+	if m.selectedIdx >= len(m.entries) {
+		m.preview = filePreview{}
+		return
+	}
+	entry := m.entries[m.selectedIdx]
+	if m.preview.path == entry.path {
+		return
+	}
+
+	m.preview = filePreview{path: entry.path, dir: entry.info.IsDir()}
+	if m.preview.dir {
+		m.preview.entries = -1
+		if children, err := os.ReadDir(entry.path); err == nil {
+			m.preview.entries = len(children)
+		}
+		return
+	}
+
+	m.preview.head, m.preview.err = readHead(entry.path)
+	if m.preview.err != nil || isText(m.preview.head) {
+		return
+	}
+	m.preview.contentType = "unknown"
+	if info, err := mailer.InspectFile(entry.path); err == nil {
+		m.preview.contentType = info.ContentType
+	}
+}
+
+// renderPreview shows the start of a text file, or the metadata of a binary
+// file or directory, as loaded by loadPreview
+func (m FileSelectModel) renderPreview(width int) string {
+	if m.selectedIdx >= len(m.entries) {
+		return ""
+	}
+	entry := m.entries[m.selectedIdx]
+	p := m.preview
+	if p.path != entry.path {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(ui.DisplayLabelStyle.Render(filepath.Base(entry.path)))
+	b.WriteString("\n")
+
+	if p.dir {
+		count := "unreadable"
+		if p.entries >= 0 {
+			count = fmt.Sprintf("%d entries", p.entries)
+		}
+		b.WriteString(ui.MutedTextStyle.Render("Directory, " + count + "\nSent as " + mailer.ArchiveName(entry.path) + " when attached"))
+		return b.String()
+	}
+
+	if p.err != nil {
+		b.WriteString(ui.ErrorTextStyle.Render(p.err.Error()))
+		return b.String()
+	}
+
+	if p.contentType == "" {
+		lines := strings.Split(strings.ReplaceAll(string(p.head), "\t", "    "), "\n")
+		if len(lines) > previewLines {
+			lines = lines[:previewLines]
+		}
+		for i, line := range lines {
+			lines[i] = truncateRight(strings.TrimRight(line, "\r"), width)
+		}
+		b.WriteString(strings.Join(lines, "\n"))
+		return b.String()
+	}
+
+	b.WriteString(ui.MutedTextStyle.Render(fmt.Sprintf(
+		"Binary file\nType:     %s\nSize:     %s (%d bytes)\nModified: %s\nMode:     %s",
+		p.contentType,
+		mailer.FormatSize(entry.info.Size()),
+		entry.info.Size(),
+		entry.info.ModTime().Format("2006-01-02 15:04:05"),
+		entry.info.Mode(),
+	)))
+	return b.String()
+}
+
+// readHead reads the start of a file for the preview
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, previewBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// isText reports whether content looks like UTF-8 text
+func isText(content []byte) bool {
+	if bytes.IndexByte(content, 0) != -1 {
+		return false
+	}
+	// The read may have cut a multi-byte character at the end
+	for i := 0; i < utf8.UTFMax && len(content) > 0 && !utf8.Valid(content); i++ {
+		content = content[:len(content)-1]
+	}
+	return utf8.Valid(content)
+}
+
+// truncateLeft shortens s to width characters, keeping its end
+func truncateLeft(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return "…" + string(r[len(r)-width+1:])
+}
+
+// truncateRight shortens s to width characters, keeping its start
+func truncateRight(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
 // GetSelectedPath returns the currently selected file/directory path
 func (m FileSelectModel) GetSelectedPath() string {
 	if len(m.entries) == 0 || m.selectedIdx >= len(m.entries) {
 		return ""
 	}
-	return filepath.Join(m.currentPath, m.entries[m.selectedIdx].Name())
+	return m.entries[m.selectedIdx].path
 }
 
 // SetFilter sets file extension filters (e.g., []string{".pdf", ".txt"})
 func (m *FileSelectModel) SetFilter(extensions []string) {
 	m.filterExt = extensions
 	m.loadDirectory()
+	m.loadPreview()
 }
 
 // SetShowHidden sets whether to show hidden files
 func (m *FileSelectModel) SetShowHidden(show bool) {
 	m.showHidden = show
 	m.loadDirectory()
+	m.loadPreview()
+}
+
+// matchesFilter reports whether a file name passes the extension filter
+func (m FileSelectModel) matchesFilter(name string) bool {
+	if len(m.filterExt) == 0 {
+		return true
+	}
+	for _, ext := range m.filterExt {
+		if strings.HasSuffix(strings.ToLower(name), strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
 }
 
 // loadDirectory loads the current directory contents
 func (m *FileSelectModel) loadDirectory() {
-	entries, err := os.ReadDir(m.currentPath)
+	dirEntries, err := os.ReadDir(m.currentPath)
 	if err != nil {
 		m.err = err
-		m.entries = []fs.DirEntry{}
+		m.entries = []fileEntry{}
 		return
 	}
 
 	m.err = nil
 
 	// Filter entries
-	filtered := make([]fs.DirEntry, 0, len(entries))
-	for _, entry := range entries {
+	entries := make([]fileEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
 		name := entry.Name()
 
 		// Filter hidden files
@@ -273,37 +799,29 @@ func (m *FileSelectModel) loadDirectory() {
 		}
 
 		// Filter by extension (only for files)
-		if !entry.IsDir() && len(m.filterExt) > 0 {
-			hasValidExt := false
-			for _, ext := range m.filterExt {
-				if strings.HasSuffix(strings.ToLower(name), strings.ToLower(ext)) {
-					hasValidExt = true
-					break
-				}
-			}
-			if !hasValidExt {
-				continue
-			}
+		if !entry.IsDir() && !m.matchesFilter(name) {
+			continue
 		}
 
-		filtered = append(filtered, entry)
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since the directory was read
+		}
+		entries = append(entries, fileEntry{name: name, path: filepath.Join(m.currentPath, name), info: info})
 	}
 
 	// Sort: directories first, then files, alphabetically
-	sort.Slice(filtered, func(i, j int) bool {
-		if filtered[i].IsDir() && !filtered[j].IsDir() {
-			return true
-		}
-		if !filtered[i].IsDir() && filtered[j].IsDir() {
-			return false
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].info.IsDir() != entries[j].info.IsDir() {
+			return entries[i].info.IsDir()
 		}
-		return strings.ToLower(filtered[i].Name()) < strings.ToLower(filtered[j].Name())
+		return strings.ToLower(entries[i].name) < strings.ToLower(entries[j].name)
 	})
 
-	m.entries = filtered
+	m.entries = entries
 }
 
-// FileSelectedMsg is sent when a file is selected
+// FileSelectedMsg is sent when one or more files are selected
 type FileSelectedMsg struct {
-	Path string
+	Paths []string
 }
@@ -2,10 +2,16 @@ package models
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
+	"mailgloss/events"
+	"mailgloss/mailer"
 	"mailgloss/storage"
 	"mailgloss/ui"
 )
@@ -13,21 +19,39 @@ import (
 // HistoryModel represents the email history tab
 type HistoryModel struct {
 	history       *storage.History
+	snapshots     *storage.Snapshots // nil if attachment snapshots are unavailable
 	selectedIndex int
 	viewingEmail  bool
 	width         int
 	height        int
+
+	// The list shows the emails matching the search query
+	searching bool
+	search    textinput.Model
 }
 
 // NewHistoryModel creates a new history model
-func NewHistoryModel(history *storage.History) HistoryModel {
+func NewHistoryModel(history *storage.History, snapshots *storage.Snapshots) HistoryModel {
+	search := textinput.New()
+	search.Placeholder = "subject, address, message ID or response"
+	search.Prompt = "/ "
+	search.CharLimit = 200
+	search.Width = 50
+
 	return HistoryModel{
 		history:       history,
+		snapshots:     snapshots,
 		selectedIndex: 0,
 		viewingEmail:  false,
+		search:        search,
 	}
 }
 
+// emails returns the emails listed, most recent first
+func (m HistoryModel) emails() []storage.SentEmail {
+	return m.history.Search(m.search.Value())
+}
+
 // Init initializes the history model
 func (m HistoryModel) Init() tea.Cmd {
 	return nil
@@ -37,13 +61,19 @@ func (m HistoryModel) Init() tea.Cmd {
 func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		emails := m.history.GetAll()
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+
+		emails := m.emails()
 
 		if m.viewingEmail {
 			// Viewing an email, go back on any key
 			switch msg.String() {
 			case "esc", "q", "enter":
 				m.viewingEmail = false
+			case "r":
+				return m, m.resend(emails)
 			}
 			return m, nil
 		}
@@ -62,6 +92,17 @@ func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 			if len(emails) > 0 {
 				m.viewingEmail = true
 			}
+		case "r":
+			return m, m.resend(emails)
+		case "/":
+			m.searching = true
+			m.search.Focus()
+			return m, textinput.Blink
+		case "esc":
+			if m.search.Value() != "" {
+				m.search.SetValue("")
+				m.selectedIndex = 0
+			}
 		case "g":
 			m.selectedIndex = 0
 		case "G":
@@ -79,7 +120,7 @@ func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 		if h, err := storage.Load(); err == nil {
 			m.history = h
 			// Adjust selected index if needed
-			emails := m.history.GetAll()
+			emails := m.emails()
 			if m.selectedIndex >= len(emails) {
 				m.selectedIndex = len(emails) - 1
 			}
@@ -87,14 +128,55 @@ func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 				m.selectedIndex = 0
 			}
 		}
+
+	default:
+		if m.searching {
+			var cmd tea.Cmd
+			m.search, cmd = m.search.Update(msg)
+			return m, cmd
+		}
 	}
 
 	return m, nil
 }
 
+// updateSearch handles keys while the search query is being typed
+func (m HistoryModel) updateSearch(msg tea.KeyMsg) (HistoryModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.search.SetValue("")
+		m.selectedIndex = 0
+		m.searching = false
+		m.search.Blur()
+		return m, nil
+	case "enter":
+		m.searching = false
+		m.search.Blur()
+		return m, nil
+	case "up":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return m, nil
+	case "down":
+		if m.selectedIndex < len(m.emails())-1 {
+			m.selectedIndex++
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	query := m.search.Value()
+	m.search, cmd = m.search.Update(msg)
+	if m.search.Value() != query {
+		m.selectedIndex = 0
+	}
+	return m, cmd
+}
+
 // View renders the history model
 func (m HistoryModel) View() string {
-	emails := m.history.GetAll()
+	emails := m.emails()
 
 	if m.viewingEmail && len(emails) > 0 && m.selectedIndex < len(emails) {
 		return m.viewEmail(emails[m.selectedIndex])
@@ -110,7 +192,7 @@ func (m HistoryModel) viewList(emails []storage.SentEmail) string {
 	b.WriteString(ui.TitleStyle.Render("Sent Email History"))
 	b.WriteString("\n\n")
 
-	if len(emails) == 0 {
+	if len(emails) == 0 && m.search.Value() == "" && !m.searching {
 		emptyState := ui.InfoStyle.Render("📭 No emails sent yet")
 		b.WriteString(emptyState)
 		b.WriteString("\n\n")
@@ -118,7 +200,7 @@ func (m HistoryModel) viewList(emails []storage.SentEmail) string {
 		helpText := ui.SubtitleStyle.Render(
 			"Your email history will appear here once you send emails.\n\n" +
 				"Quick tips:\n" +
-				"  • All sent emails are saved with their status (success/failed)\n" +
+				"  • All sent emails are saved with their status (success/partial/failed)\n" +
 				"  • Press Enter on any email to view full details\n" +
 				"  • Use ↑/↓ or j/k to navigate the list\n" +
 				"  • Press g/G to jump to top/bottom\n\n" +
@@ -130,9 +212,20 @@ func (m HistoryModel) viewList(emails []storage.SentEmail) string {
 		return b.String()
 	}
 
-	b.WriteString(ui.SubtitleStyle.Render(fmt.Sprintf("Total: %d emails", len(emails))))
+	if m.searching || m.search.Value() != "" {
+		b.WriteString(m.search.View())
+		b.WriteString("\n")
+		b.WriteString(ui.SubtitleStyle.Render(fmt.Sprintf("Showing %d of %d emails", len(emails), len(m.history.Emails))))
+	} else {
+		b.WriteString(ui.SubtitleStyle.Render(fmt.Sprintf("Total: %d emails", len(emails))))
+	}
 	b.WriteString("\n")
 
+	if len(emails) == 0 {
+		b.WriteString(ui.MutedTextStyle.Render("No emails match the search"))
+		b.WriteString("\n")
+	}
+
 	// Show list of emails
 	for i, email := range emails {
 		var line string
@@ -157,7 +250,11 @@ func (m HistoryModel) viewList(emails []storage.SentEmail) string {
 
 		// Status indicator
 		statusIcon := "✓"
-		if email.Status != "success" {
+		switch email.Status {
+		case "success":
+		case "partial":
+			statusIcon = "◐"
+		default:
 			statusIcon = "✗"
 		}
 
@@ -168,6 +265,11 @@ func (m HistoryModel) viewList(emails []storage.SentEmail) string {
 			subject,
 		)
 
+		// Latest delivery event reported by the provider
+		if len(email.Events) > 0 {
+			line += " (" + email.Events[len(email.Events)-1].Type + ")"
+		}
+
 		if i == m.selectedIndex {
 			b.WriteString(ui.SelectedItemStyle.Render("→ " + line))
 		} else {
@@ -177,10 +279,16 @@ func (m HistoryModel) viewList(emails []storage.SentEmail) string {
 	}
 
 	b.WriteString("\n")
+	if m.searching {
+		b.WriteString(ui.RenderHelp("↑/↓", "navigate", "Enter", "done", "Esc", "clear search"))
+		return b.String()
+	}
 	b.WriteString(ui.RenderHelp(
 		"↑/k", "up",
 		"↓/j", "down",
 		"Enter", "view",
+		"r", "resend",
+		"/", "search",
 		"g/G", "top/bottom",
 	))
 
@@ -195,9 +303,16 @@ func (m HistoryModel) viewEmail(email storage.SentEmail) string {
 	b.WriteString("\n\n")
 
 	// Status
-	if email.Status == "success" {
+	switch email.Status {
+	case "success":
 		b.WriteString(ui.SuccessStyle.Render("✓ Sent Successfully"))
-	} else {
+	case "partial":
+		b.WriteString(ui.WarningStyle.Render("◐ Partially Sent"))
+		if email.Error != "" {
+			b.WriteString("\n")
+			b.WriteString(ui.ErrorStyle.Render("Error: " + email.Error))
+		}
+	default:
 		b.WriteString(ui.ErrorStyle.Render("✗ Failed to Send"))
 		if email.Error != "" {
 			b.WriteString("\n")
@@ -217,7 +332,71 @@ func (m HistoryModel) viewEmail(email storage.SentEmail) string {
 	b.WriteString(" " + email.Provider + "\n")
 
 	b.WriteString(ui.DisplayLabelStyle.Render("Provider Config:"))
-	b.WriteString(" " + email.ProviderName + "\n\n")
+	b.WriteString(" " + email.ProviderName + "\n")
+
+	if email.DeliveredBy != "" && email.DeliveredBy != email.ProviderName {
+		b.WriteString(ui.DisplayLabelStyle.Render("Delivered By:"))
+		b.WriteString(" " + email.DeliveredBy + "\n")
+	}
+
+	// Provider's reply, to find the email in its dashboard
+	if email.MessageID != "" {
+		b.WriteString(ui.DisplayLabelStyle.Render("Message ID:"))
+		b.WriteString(" " + email.MessageID + "\n")
+	}
+
+	if email.StatusCode != 0 || email.Response != "" {
+		b.WriteString(ui.DisplayLabelStyle.Render("Response:"))
+		if email.StatusCode != 0 {
+			b.WriteString(fmt.Sprintf(" %d", email.StatusCode))
+		}
+		b.WriteString(" " + email.Response + "\n")
+	}
+
+	// Failover attempts
+	if len(email.Attempts) > 0 {
+		b.WriteString(ui.DisplayLabelStyle.Render("Attempts:"))
+		b.WriteString("\n")
+		for i, attempt := range email.Attempts {
+			if attempt.Error == "" && attempt.MessageID != "" {
+				b.WriteString(fmt.Sprintf("  %d. %s ✓ %s\n", i+1, attempt.ProviderName, attempt.MessageID))
+			} else if attempt.Error == "" {
+				b.WriteString(fmt.Sprintf("  %d. %s ✓\n", i+1, attempt.ProviderName))
+			} else {
+				b.WriteString(fmt.Sprintf("  %d. %s ✗ %s\n", i+1, attempt.ProviderName, attempt.Error))
+			}
+		}
+	}
+
+	// Batches, when the recipients were split over several messages
+	if len(email.Batches) > 0 {
+		b.WriteString(ui.DisplayLabelStyle.Render("Batches:"))
+		b.WriteString("\n")
+		for i, batch := range email.Batches {
+			if batch.Error == "" && batch.MessageID != "" {
+				b.WriteString(fmt.Sprintf("  %d. %d recipients via %s ✓ %s\n", i+1, batch.Recipients, batch.ProviderName, batch.MessageID))
+			} else if batch.Error == "" {
+				b.WriteString(fmt.Sprintf("  %d. %d recipients via %s ✓\n", i+1, batch.Recipients, batch.ProviderName))
+			} else {
+				b.WriteString(fmt.Sprintf("  %d. %d recipients via %s ✗ %s\n", i+1, batch.Recipients, batch.ProviderName, batch.Error))
+			}
+		}
+	}
+
+	// Delivery timeline reported by provider webhooks
+	if len(email.Events) > 0 {
+		b.WriteString(ui.DisplayLabelStyle.Render("Delivery:"))
+		b.WriteString("\n")
+		for _, event := range email.Events {
+			line := fmt.Sprintf("  %s  %-12s %s", event.Time.Local().Format("2006-01-02 15:04:05"), event.Type, event.Recipient)
+			if event.Detail != "" {
+				line += " - " + event.Detail
+			}
+			b.WriteString(eventStyle(event.Type).Render(line))
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
 
 	// Recipients
 	b.WriteString(ui.DisplayLabelStyle.Render("To:"))
@@ -233,6 +412,59 @@ func (m HistoryModel) viewEmail(email storage.SentEmail) string {
 		b.WriteString(" " + strings.Join(email.BCC, ", ") + "\n")
 	}
 
+	// Reply-To, priority, read receipt, PGP, S/MIME and custom headers
+	if email.ReplyTo != "" {
+		b.WriteString(ui.DisplayLabelStyle.Render("Reply-To:"))
+		b.WriteString(" " + email.ReplyTo + "\n")
+	}
+
+	if email.Priority != "" {
+		b.WriteString(ui.DisplayLabelStyle.Render("Priority:"))
+		b.WriteString(" " + email.Priority + "\n")
+	}
+
+	if email.ReadReceipt {
+		b.WriteString(ui.DisplayLabelStyle.Render("Read Receipt:"))
+		b.WriteString(" requested\n")
+	}
+
+	if email.PGPSigned || email.PGPEncrypted {
+		var modes []string
+		if email.PGPSigned {
+			modes = append(modes, "signed")
+		}
+		if email.PGPEncrypted {
+			modes = append(modes, "encrypted")
+		}
+		b.WriteString(ui.DisplayLabelStyle.Render("PGP:"))
+		b.WriteString(" " + strings.Join(modes, ", ") + "\n")
+	}
+
+	if email.SMIMESigned || email.SMIMEEncrypted {
+		var modes []string
+		if email.SMIMESigned {
+			modes = append(modes, "signed")
+		}
+		if email.SMIMEEncrypted {
+			modes = append(modes, "encrypted")
+		}
+		b.WriteString(ui.DisplayLabelStyle.Render("S/MIME:"))
+		b.WriteString(" " + strings.Join(modes, ", ") + "\n")
+	}
+
+	if len(email.Headers) > 0 {
+		b.WriteString(ui.DisplayLabelStyle.Render("Headers:"))
+		b.WriteString("\n")
+		names := make([]string, 0, len(email.Headers))
+		for name := range email.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.WriteString("  " + name + ": " + email.Headers[name] + "\n")
+		}
+	}
+
 	b.WriteString("\n")
 
 	// Subject
@@ -245,6 +477,27 @@ func (m HistoryModel) viewEmail(email storage.SentEmail) string {
 		b.WriteString("\n")
 		for _, att := range email.Attachments {
 			b.WriteString("  • " + att + "\n")
+			b.WriteString(m.renderSnapshot(email, att, false))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(email.Bundle) > 0 {
+		b.WriteString(ui.DisplayLabelStyle.Render("Zipped as " + bundleName + ":"))
+		b.WriteString("\n")
+		for _, path := range email.Bundle {
+			b.WriteString("  • " + path + "\n")
+			b.WriteString(m.renderSnapshot(email, path, false))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(email.InlineImages) > 0 {
+		b.WriteString(ui.DisplayLabelStyle.Render("Inline Images:"))
+		b.WriteString("\n")
+		for _, img := range email.InlineImages {
+			b.WriteString("  • " + img + "\n")
+			b.WriteString(m.renderSnapshot(email, img, true))
 		}
 		b.WriteString("\n")
 	}
@@ -259,10 +512,63 @@ func (m HistoryModel) viewEmail(email storage.SentEmail) string {
 	b.WriteString(ui.DividerStyle.Render(strings.Repeat("─", 60)))
 	b.WriteString("\n\n")
 
-	b.WriteString(ui.RenderHelp("Esc/Enter", "back to list"))
+	b.WriteString(ui.RenderHelp("Esc/Enter", "back to list", "r", "resend"))
 
 	return b.String()
 }
 
+// eventStyle returns the style of a delivery event in the timeline
+func eventStyle(eventType string) lipgloss.Style {
+	switch eventType {
+	case events.TypeDelivered, events.TypeOpened, events.TypeClicked:
+		return ui.SuccessTextStyle
+	case events.TypeDeferred:
+		return ui.WarningTextStyle
+	case events.TypeBounced, events.TypeDropped, events.TypeComplained:
+		return ui.ErrorTextStyle
+	}
+	return ui.MutedTextStyle
+}
+
+// renderSnapshot renders the size, type and checksum of the copy of a sent
+// file, and whether the copy and the original are still available
+func (m HistoryModel) renderSnapshot(email storage.SentEmail, path string, inline bool) string {
+	for _, snap := range email.Snapshots {
+		if snap.Path != path || snap.Inline != inline {
+			continue
+		}
+
+		details := fmt.Sprintf("    %s, %s, sha256 %s", snap.ContentType, mailer.FormatSize(snap.Size), snap.SHA256)
+		var notes []string
+		if m.snapshots == nil || !m.snapshots.Exists(snap) {
+			notes = append(notes, "copy no longer stored")
+		}
+		if _, err := os.Stat(path); err != nil {
+			notes = append(notes, "original missing")
+		}
+		if len(notes) > 0 {
+			return ui.MutedTextStyle.Render(details) + " " + ui.WarningTextStyle.Render("("+strings.Join(notes, ", ")+")") + "\n"
+		}
+		return ui.MutedTextStyle.Render(details) + "\n"
+	}
+	return ""
+}
+
 // RefreshHistoryMsg signals the history should be reloaded
 type RefreshHistoryMsg struct{}
+
+// ResendEmailMsg asks compose to load an email from history
+type ResendEmailMsg struct {
+	Email storage.SentEmail
+}
+
+// resend returns a command that loads the selected email into compose
+func (m HistoryModel) resend(emails []storage.SentEmail) tea.Cmd {
+	if m.selectedIndex < 0 || m.selectedIndex >= len(emails) {
+		return nil
+	}
+	email := emails[m.selectedIndex]
+	return func() tea.Msg {
+		return ResendEmailMsg{Email: email}
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mailgloss/config"
+	"mailgloss/mailer"
+)
+
+// hasGlob reports whether a path contains glob metacharacters
+func hasGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// completePath completes the last element of a typed path like a shell
+// does. It returns the input extended by the longest common prefix of the
+// matching names, and the names themselves when more than one matches.
+// The ~ prefix is kept as typed.
+func completePath(input string) (string, []string) {
+	dir, prefix := "", input
+	if i := strings.LastIndex(input, "/"); i != -1 {
+		dir, prefix = input[:i+1], input[i+1:]
+	} else if input == "~" {
+		dir, prefix = "~/", ""
+	}
+
+	lookup := config.ExpandHome(dir)
+	if lookup == "" {
+		lookup = "."
+	}
+	entries, err := os.ReadDir(lookup)
+	if err != nil {
+		return input, nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// Hidden files only complete when asked for
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if isDirEntry(filepath.Join(lookup, name)) {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch len(names) {
+	case 0:
+		return input, nil
+	case 1:
+		return dir + names[0], nil
+	}
+	return dir + commonPrefix(names), names
+}
+
+// isDirEntry reports whether a path is a directory, following symlinks
+func isDirEntry(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// commonPrefix returns the longest common prefix of names
+func commonPrefix(names []string) string {
+	prefix := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// expandAttachments expands ~ and glob patterns in a typed attachment path
+// and validates each file. Valid files are returned even if others fail,
+// along with an error describing the files that were skipped.
+func expandAttachments(input string, maxAttachmentMB int) ([]string, error) {
+	pattern := config.ExpandHome(input)
+	paths := []string{pattern}
+	if hasGlob(pattern) {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", input, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match '%s'", input)
+		}
+		paths = matches
+	}
+
+	var valid []string
+	var problems []string
+	for _, path := range paths {
+		if err := validateAttachmentPath(path, maxAttachmentMB); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		valid = append(valid, path)
+	}
+
+	if len(problems) == 0 {
+		return valid, nil
+	}
+	if len(problems) == 1 {
+		return valid, fmt.Errorf("%s", problems[0])
+	}
+	return valid, fmt.Errorf("%d files skipped, first %s", len(problems), problems[0])
+}
+
+// validateAttachmentPath checks a file with the mailer's attachment rules.
+// Folders only need to be readable since they are zipped when sending.
+func validateAttachmentPath(path string, maxAttachmentMB int) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if _, err := os.ReadDir(path); err != nil {
+			return fmt.Errorf("folder not readable: %w", err)
+		}
+		return nil
+	}
+	return mailer.ValidateAttachment(path, maxAttachmentMB)
+}
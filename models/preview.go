@@ -0,0 +1,345 @@
+package models
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"mailgloss/logger"
+	"mailgloss/mailer"
+	"mailgloss/ui"
+)
+
+var (
+	htmlSkipPattern   = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>|<!--.*?-->`)
+	htmlTagPattern    = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>`)
+	htmlHrefPattern   = regexp.MustCompile(`(?i)\bhref\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	htmlAltPattern    = regexp.MustCompile(`(?i)\balt\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+	blankRunPattern   = regexp.MustCompile(`\n{3,}`)
+)
+
+// PreviewModel shows a message as recipients will get it before sending
+type PreviewModel struct {
+	preview  *mailer.Preview
+	lines    []string
+	offset   int
+	width    int
+	height   int
+	htmlFile string // Temporary file opened in the browser
+	err      string
+}
+
+// PreviewClosedMsg is sent when the preview is closed without sending
+type PreviewClosedMsg struct{}
+
+// PreviewSendMsg is sent when the message is sent from the preview
+type PreviewSendMsg struct{}
+
+// NewPreviewModel creates a preview of a built message
+func NewPreviewModel(preview *mailer.Preview, width, height int) PreviewModel {
+	m := PreviewModel{preview: preview}
+	m.SetSize(width, height)
+	return m
+}
+
+// SetSize sets the size available to the preview and re-wraps the content
+func (m *PreviewModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height - 10 // Reserve space for header and footer
+	if m.height < 5 {
+		m.height = 5
+	}
+	m.lines = strings.Split(m.render(), "\n")
+	m.scroll(0)
+}
+
+// Close removes the temporary HTML file, if one was written
+func (m *PreviewModel) Close() {
+	if m.htmlFile != "" {
+		os.Remove(m.htmlFile)
+		m.htmlFile = ""
+	}
+}
+
+// Update handles messages for the preview model
+func (m PreviewModel) Update(msg tea.Msg) (PreviewModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			m.scroll(-1)
+		case "down", "j":
+			m.scroll(1)
+		case "pgup", "b":
+			m.scroll(-m.height)
+		case "pgdown", " ", "f":
+			m.scroll(m.height)
+		case "home", "g":
+			m.offset = 0
+		case "end", "G":
+			m.scroll(len(m.lines))
+		case "o":
+			// Open the HTML part in a browser
+			m.err = ""
+			if m.htmlFile == "" {
+				path, err := m.preview.WriteHTMLFile()
+				if err != nil {
+					m.err = err.Error()
+					return m, nil
+				}
+				m.htmlFile = path
+			}
+			if err := openInBrowser(m.htmlFile); err != nil {
+				m.err = fmt.Sprintf("Failed to open browser: %v", err)
+			}
+		case "s":
+			return m, func() tea.Msg { return PreviewSendMsg{} }
+		case "esc", "q":
+			return m, func() tea.Msg { return PreviewClosedMsg{} }
+		}
+
+	case tea.WindowSizeMsg:
+		m.SetSize(msg.Width, msg.Height)
+	}
+
+	return m, nil
+}
+
+// scroll moves the view by delta lines, keeping it within the content
+func (m *PreviewModel) scroll(delta int) {
+	m.offset += delta
+	if m.offset > len(m.lines)-m.height {
+		m.offset = len(m.lines) - m.height
+	}
+	if m.offset < 0 {
+		m.offset = 0
+	}
+}
+
+// View renders the preview model
+func (m PreviewModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(ui.TitleStyle.Render("Preview"))
+	b.WriteString("\n")
+
+	end := min(m.offset+m.height, len(m.lines))
+	for _, line := range m.lines[m.offset:end] {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	if len(m.lines) > m.height {
+		b.WriteString(ui.MutedTextStyle.Render(fmt.Sprintf("\n[%d-%d of %d lines]", m.offset+1, end, len(m.lines))))
+		b.WriteString("\n")
+	}
+	if m.err != "" {
+		b.WriteString("\n")
+		b.WriteString(ui.ErrorTextStyle.Render("✗ " + m.err))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(ui.RenderHelp(
+		"↑/↓", "scroll",
+		"Space/b", "page",
+		"o", "open HTML in browser",
+		"s", "send",
+		"Esc/q", "back",
+	))
+
+	return b.String()
+}
+
+// render lays out the headers, attachments and both body parts
+func (m PreviewModel) render() string {
+	p := m.preview
+	width := m.textWidth()
+	var b strings.Builder
+
+	b.WriteString(ui.DisplayLabelStyle.Render("Headers") + ui.MutedTextStyle.Render(" (via "+p.ProviderName+")"))
+	b.WriteString("\n")
+	for _, h := range p.Headers {
+		b.WriteString(ui.InputStyle.Width(width).Render(ui.MutedTextStyle.Render(h.Name+": ") + h.Value))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(ui.DisplayLabelStyle.Render("Attachments"))
+	b.WriteString(ui.MutedTextStyle.Render(fmt.Sprintf(" (message about %s)", mailer.FormatSize(p.Size))))
+	b.WriteString("\n")
+	if len(p.Attachments) == 0 {
+		b.WriteString(ui.MutedTextStyle.Render("None"))
+		b.WriteString("\n")
+	}
+	for _, file := range p.Attachments {
+		b.WriteString("📎 " + file.Name + " " + ui.MutedTextStyle.Render(describePreviewFile(file)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(ui.DisplayLabelStyle.Render("Plain text"))
+	b.WriteString("\n")
+	b.WriteString(ui.InputStyle.Width(width).Render(p.PlainText))
+	b.WriteString("\n\n")
+
+	b.WriteString(ui.DisplayLabelStyle.Render("HTML"))
+	b.WriteString("\n")
+	b.WriteString(lipgloss.NewStyle().Width(width).Render(renderHTML(p.HTML, width)))
+
+	return b.String()
+}
+
+// textWidth returns the width to wrap the content at
+func (m PreviewModel) textWidth() int {
+	if m.width <= 0 {
+		return 80
+	}
+	return max(min(m.width-4, 100), 20)
+}
+
+// describePreviewFile renders the type and size of a previewed file
+func describePreviewFile(file mailer.PreviewFile) string {
+	switch {
+	case file.Inline:
+		return fmt.Sprintf("(inline, %s, %s)", file.ContentType, mailer.FormatSize(file.Size))
+	case file.Files > 0:
+		return fmt.Sprintf("(zip of %d files, %s before compression)", file.Files, mailer.FormatSize(file.Size))
+	}
+	return fmt.Sprintf("(%s, %s)", file.ContentType, mailer.FormatSize(file.Size))
+}
+
+// renderHTML renders an HTML body for the terminal: block elements break
+// lines, emphasis is styled, list items get bullets and link targets follow
+// their text
+func renderHTML(body string, width int) string {
+	body = htmlSkipPattern.ReplaceAllString(body, "")
+
+	var b strings.Builder
+	var bold, italic, underline int
+	var links []string
+	space := true // Whether the output ends in whitespace
+	write := func(s string) {
+		b.WriteString(s)
+		space = strings.HasSuffix(s, " ") || strings.HasSuffix(s, "\n")
+	}
+	text := func(s string) {
+		s = html.UnescapeString(whitespacePattern.ReplaceAllString(s, " "))
+		if space {
+			s = strings.TrimLeft(s, " ")
+		}
+		if s == "" {
+			return
+		}
+		style := lipgloss.NewStyle().Bold(bold > 0).Italic(italic > 0).Underline(underline > 0)
+		b.WriteString(style.Render(s))
+		space = strings.HasSuffix(s, " ")
+	}
+	step := func(closing bool) int {
+		if closing {
+			return -1
+		}
+		return 1
+	}
+
+	last := 0
+	for _, loc := range htmlTagPattern.FindAllStringSubmatchIndex(body, -1) {
+		text(body[last:loc[0]])
+		last = loc[1]
+		closing := loc[3] > loc[2]
+		attrs := body[loc[6]:loc[7]]
+
+		switch strings.ToLower(body[loc[4]:loc[5]]) {
+		case "b", "strong":
+			bold += step(closing)
+		case "i", "em":
+			italic += step(closing)
+		case "u":
+			underline += step(closing)
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			bold += step(closing)
+			write("\n\n")
+		case "br":
+			write("\n")
+		case "p", "div", "table", "tr", "blockquote", "ul", "ol":
+			write("\n")
+		case "li":
+			if !closing {
+				write("\n  • ")
+			}
+		case "td", "th":
+			if !closing && !space {
+				write(" ")
+			}
+		case "hr":
+			write("\n" + ui.MutedTextStyle.Render(strings.Repeat("─", min(width, 40))) + "\n")
+		case "img":
+			if alt := attrValue(htmlAltPattern, attrs); alt != "" {
+				write(ui.MutedTextStyle.Render("[image: "+alt+"]") + " ")
+			} else {
+				write(ui.MutedTextStyle.Render("[image]") + " ")
+			}
+		case "a":
+			if !closing {
+				links = append(links, attrValue(htmlHrefPattern, attrs))
+				underline++
+				continue
+			}
+			if len(links) == 0 {
+				continue
+			}
+			href := links[len(links)-1]
+			links = links[:len(links)-1]
+			underline--
+			if href != "" && !strings.HasPrefix(href, "#") {
+				write(ui.MutedTextStyle.Render(" (" + strings.TrimPrefix(href, "mailto:") + ")"))
+			}
+		}
+	}
+	text(body[last:])
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimSpace(blankRunPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// attrValue returns the unquoted value of an attribute matched by pattern
+func attrValue(pattern *regexp.Regexp, attrs string) string {
+	m := pattern.FindStringSubmatch(attrs)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(strings.Trim(m[1], `"'`))
+}
+
+// openInBrowser opens a file with the system's default handler
+func openInBrowser(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			logger.Warn("Browser exited with an error", "path", path, "error", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"mailgloss/mailer"
+	"mailgloss/storage"
+	"mailgloss/ui"
+)
+
+// sendJob is a message waiting in the send queue or being sent
+type sendJob struct {
+	id      int
+	send    SendEmailMsg
+	draft   ComposeModel // Compose state to return to when cancelled or failed
+	sendAt  time.Time
+	sending bool
+	held    bool // Cancelled while Compose had another message, not sent
+}
+
+// sendQueue holds messages for the undo delay and sends them in the
+// background, so the UI stays responsive while a provider is slow
+type sendQueue struct {
+	jobs    []*sendJob
+	nextID  int
+	ticking bool
+}
+
+// sendTickMsg advances the countdown of queued messages
+type sendTickMsg struct{}
+
+// sendDoneMsg is sent when a queued message has been sent or has failed
+type sendDoneMsg struct {
+	id        int
+	result    *mailer.Result
+	err       error
+	snapshots []storage.AttachmentSnapshot // Copies of the sent files
+}
+
+// add queues a message to be sent after delay
+func (q *sendQueue) add(send SendEmailMsg, draft ComposeModel, delay time.Duration) *sendJob {
+	q.nextID++
+	job := &sendJob{id: q.nextID, send: send, draft: draft, sendAt: time.Now().Add(delay)}
+	q.jobs = append(q.jobs, job)
+	return job
+}
+
+// remove takes a job out of the queue
+func (q *sendQueue) remove(id int) *sendJob {
+	for i, job := range q.jobs {
+		if job.id == id {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			return job
+		}
+	}
+	return nil
+}
+
+// lastWaiting returns the most recently queued message that is still
+// waiting for its delay, or nil
+func (q *sendQueue) lastWaiting() *sendJob {
+	for i := len(q.jobs) - 1; i >= 0; i-- {
+		if !q.jobs[i].sending && !q.jobs[i].held {
+			return q.jobs[i]
+		}
+	}
+	return nil
+}
+
+// lastHeld returns the most recently cancelled message that is held for
+// Compose, or nil
+func (q *sendQueue) lastHeld() *sendJob {
+	for i := len(q.jobs) - 1; i >= 0; i-- {
+		if q.jobs[i].held {
+			return q.jobs[i]
+		}
+	}
+	return nil
+}
+
+// active reports whether messages are waiting or sending
+func (q *sendQueue) active() bool {
+	for _, job := range q.jobs {
+		if !job.held {
+			return true
+		}
+	}
+	return false
+}
+
+// due returns the waiting messages whose delay has passed
+func (q *sendQueue) due(now time.Time) []*sendJob {
+	var jobs []*sendJob
+	for _, job := range q.jobs {
+		if !job.sending && !job.held && !now.Before(job.sendAt) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// tick schedules the next countdown update unless one is already scheduled.
+// Ticks go on while messages are sending to update their place in the
+// rate limit queue.
+func (q *sendQueue) tick() tea.Cmd {
+	if q.ticking || !q.active() {
+		return nil
+	}
+	q.ticking = true
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return sendTickMsg{}
+	})
+}
+
+// View renders the queued messages with their countdown
+func (q *sendQueue) View() string {
+	var lines []string
+	for _, job := range q.jobs {
+		subject := job.send.Data.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		if job.held {
+			lines = append(lines, ui.MutedTextStyle.Render(fmt.Sprintf("✋ %q was cancelled and is kept until Compose is empty (Ctrl+X to edit it then)", subject)))
+			continue
+		}
+		if job.sending {
+			if status, ok := mailer.DefaultScheduler.Status(job.id); ok {
+				lines = append(lines, ui.StatusInfoStyle.Render(fmt.Sprintf("⏸ %q is #%d in the %s queue, sending in about %s",
+					subject, status.Position, status.ProviderName, status.ETA.Round(time.Second))))
+				continue
+			}
+			lines = append(lines, ui.StatusInfoStyle.Render(fmt.Sprintf("➤ Sending %q...", subject)))
+			continue
+		}
+		seconds := int(time.Until(job.sendAt).Round(time.Second) / time.Second)
+		lines = append(lines, ui.WarningTextStyle.Render(fmt.Sprintf("⏳ Sending %q in %ds", subject, max(seconds, 0)))+
+			ui.MutedTextStyle.Render(" (Ctrl+X to cancel)"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// queueSend puts a message in the send queue, clearing Compose for the next
+// one while it waits
+func (m *AppModel) queueSend(msg SendEmailMsg) tea.Cmd {
+	delay := time.Duration(m.config.UndoSendSeconds) * time.Second
+	m.sendQueue.add(msg, m.composeModel.clone(), delay)
+	m.composeModel.Clear()
+	m.composeModel.isSending = false
+	if delay == 0 {
+		return m.startDueSends()
+	}
+	return m.sendQueue.tick()
+}
+
+// cancelSend takes the most recent waiting message out of the queue and
+// returns it to Compose. When Compose has another message in progress the
+// cancelled one is held in the queue instead, and returned by a later
+// cancel once Compose is empty.
+func (m *AppModel) cancelSend() bool {
+	job := m.sendQueue.lastWaiting()
+	if job == nil {
+		job = m.sendQueue.lastHeld()
+	}
+	if job == nil {
+		return false
+	}
+
+	m.errorMsg = ""
+	if !m.composeModel.isEmpty() {
+		if job.held {
+			m.statusMsg = ""
+			m.errorMsg = "Send or clear the message in Compose to edit the cancelled one"
+			return true
+		}
+		job.held = true
+		m.statusMsg = "Sending cancelled, the message is kept until Compose is empty"
+		return true
+	}
+
+	m.sendQueue.remove(job.id)
+	m.restoreDraft(job.draft)
+	m.activeTab = TabCompose
+	m.statusMsg = "Sending cancelled, the message is back in Compose"
+	if job.held {
+		m.statusMsg = "The cancelled message is back in Compose"
+	}
+	return true
+}
+
+// restoreDraft puts a queued message back into Compose
+func (m *AppModel) restoreDraft(draft ComposeModel) {
+	draft.width, draft.height = m.composeModel.width, m.composeModel.height
+	m.composeModel = draft
+}
+
+// startDueSends starts sending the messages whose delay has passed
+func (m *AppModel) startDueSends() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, job := range m.sendQueue.due(time.Now()) {
+		ml, err := m.newMailer(job.send.ProviderName, job.send.Data)
+		if err != nil {
+			m.sendQueue.remove(job.id)
+			m.errorMsg = fmt.Sprintf("Failed to initialize mailer: %v", err)
+			m.statusMsg = ""
+			if m.composeModel.isEmpty() {
+				m.restoreDraft(job.draft)
+			}
+			continue
+		}
+
+		job.sending = true
+		id, data := job.id, mailerData(job.send.Data)
+		data.QueueID = id
+		store, email := m.snapshots, job.send.Data
+		cmds = append(cmds, func() tea.Msg {
+			snapshots := snapshotAttachments(store, email)
+			result, err := ml.Send(data)
+			return sendDoneMsg{id: id, result: result, err: err, snapshots: snapshots}
+		})
+	}
+	cmds = append(cmds, m.sendQueue.tick())
+	return tea.Batch(cmds...)
+}
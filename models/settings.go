@@ -9,6 +9,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"mailgloss/config"
+	"mailgloss/mailer"
 	"mailgloss/ui"
 )
 
@@ -44,6 +45,10 @@ type SettingsModel struct {
 	appInputs     []textinput.Model
 	appFocusIndex int
 
+	// Connection test
+	testing     bool
+	testResults []mailer.Diagnostic
+
 	// Status
 	saved     bool
 	saveError string
@@ -76,9 +81,26 @@ const (
 	// Postal fields
 	settingsPostalURL
 	settingsPostalAPIKey
+	// Webhook fields
+	settingsWebhookURL
+	settingsWebhookMethod
+	settingsWebhookUsername
+	settingsWebhookPassword
+	settingsWebhookToken
+	// SES fields
+	settingsSESRegion
+	settingsSESAccessKeyID
+	settingsSESSecretAccessKey
+	settingsSESProfile
+	settingsSESConfigurationSet
+	settingsSESEndpoint
+	// Sendmail fields
+	settingsSendmailPath
 	// Actions
 	settingsSaveButton
 	settingsCancelButton
+	settingsTestButton
+	settingsSendTestButton
 )
 
 // NewSettingsModel creates a new settings model
@@ -96,6 +118,9 @@ func NewSettingsModel(cfg *config.Config) SettingsModel {
 			config.ProviderPostmark,
 			config.ProviderSparkPost,
 			config.ProviderPostal,
+			config.ProviderWebhook,
+			config.ProviderSES,
+			config.ProviderSendmail,
 		},
 	}
 	m.refreshProviderList()
@@ -157,6 +182,13 @@ func (m SettingsModel) Update(msg tea.Msg) (SettingsModel, tea.Cmd) {
 	case ConfigErrorMsg:
 		m.saved = false
 		m.saveError = msg.Error
+
+	case ConnectionTestMsg:
+		m.testing = false
+		m.testResults = msg.Diagnostics
+		if msg.Err != nil && len(msg.Diagnostics) == 0 {
+			m.testResults = []mailer.Diagnostic{{Step: "Test", Detail: msg.Err.Error()}}
+		}
 	}
 
 	// Update focused input if in form view
@@ -300,7 +332,7 @@ func (m SettingsModel) updateFormView(msg tea.KeyMsg) (SettingsModel, tea.Cmd) {
 		} else {
 			m.FocusIndex++
 			// Skip fields that aren't part of current provider
-			for !m.isValidField(m.FocusIndex) && m.FocusIndex <= settingsCancelButton {
+			for !m.isValidField(m.FocusIndex) && m.FocusIndex <= settingsSendTestButton {
 				m.FocusIndex++
 			}
 		}
@@ -310,7 +342,7 @@ func (m SettingsModel) updateFormView(msg tea.KeyMsg) (SettingsModel, tea.Cmd) {
 		if m.isEditing {
 			minFocus = settingsName // Skip provider type in edit mode
 		}
-		maxFocus := settingsCancelButton
+		maxFocus := settingsSendTestButton
 
 		if m.FocusIndex > maxFocus {
 			m.FocusIndex = minFocus
@@ -357,6 +389,10 @@ func (m SettingsModel) updateFormView(msg tea.KeyMsg) (SettingsModel, tea.Cmd) {
 			m.currentView = SettingsViewList
 			m.saved = false
 			m.saveError = ""
+		} else if (m.FocusIndex == settingsTestButton || m.FocusIndex == settingsSendTestButton) && !m.testing {
+			m.testing = true
+			m.testResults = nil
+			return m, m.testConnection(m.FocusIndex == settingsSendTestButton)
 		}
 	}
 
@@ -479,6 +515,27 @@ func (m *SettingsModel) initializeForm(pc *config.ProviderConfig) {
 	m.inputs[settingsPostalAPIKey-1] = createInput("Postal API Key", 500, 60)
 	m.inputs[settingsPostalAPIKey-1].EchoMode = textinput.EchoPassword
 
+	// Webhook fields
+	m.inputs[settingsWebhookURL-1] = createInput("https://mail-gateway.internal/send", 500, 60)
+	m.inputs[settingsWebhookMethod-1] = createInput("POST", 10, 60)
+	m.inputs[settingsWebhookUsername-1] = createInput("username (basic auth, optional)", 500, 60)
+	m.inputs[settingsWebhookPassword-1] = createInput("password (basic auth, optional)", 500, 60)
+	m.inputs[settingsWebhookPassword-1].EchoMode = textinput.EchoPassword
+	m.inputs[settingsWebhookToken-1] = createInput("bearer token (optional)", 500, 60)
+	m.inputs[settingsWebhookToken-1].EchoMode = textinput.EchoPassword
+
+	// SES fields
+	m.inputs[settingsSESRegion-1] = createInput("us-east-1", 50, 60)
+	m.inputs[settingsSESAccessKeyID-1] = createInput("Access Key ID (optional with profile)", 200, 60)
+	m.inputs[settingsSESSecretAccessKey-1] = createInput("Secret Access Key", 200, 60)
+	m.inputs[settingsSESSecretAccessKey-1].EchoMode = textinput.EchoPassword
+	m.inputs[settingsSESProfile-1] = createInput("default", 200, 60)
+	m.inputs[settingsSESConfigurationSet-1] = createInput("configuration set (optional)", 200, 60)
+	m.inputs[settingsSESEndpoint-1] = createInput("https://email.us-east-1.amazonaws.com", 500, 60)
+
+	// Sendmail fields
+	m.inputs[settingsSendmailPath-1] = createInput(config.DefaultSendmailPath, 500, 60)
+
 	// If editing, populate provider-specific fields
 	if pc != nil {
 		m.providerTypeIdx = m.getProviderTypeIndex(pc.Type)
@@ -515,9 +572,33 @@ func (m *SettingsModel) initializeForm(pc *config.ProviderConfig) {
 				m.inputs[settingsPostalURL-1].SetValue(pc.Postal.URL)
 				m.inputs[settingsPostalAPIKey-1].SetValue(pc.Postal.APIKey)
 			}
+		case config.ProviderWebhook:
+			if pc.Webhook != nil {
+				m.inputs[settingsWebhookURL-1].SetValue(pc.Webhook.URL)
+				m.inputs[settingsWebhookMethod-1].SetValue(pc.Webhook.Method)
+				m.inputs[settingsWebhookUsername-1].SetValue(pc.Webhook.Username)
+				m.inputs[settingsWebhookPassword-1].SetValue(pc.Webhook.Password)
+				m.inputs[settingsWebhookToken-1].SetValue(pc.Webhook.Token)
+			}
+		case config.ProviderSES:
+			if pc.SES != nil {
+				m.inputs[settingsSESRegion-1].SetValue(pc.SES.Region)
+				m.inputs[settingsSESAccessKeyID-1].SetValue(pc.SES.AccessKeyID)
+				m.inputs[settingsSESSecretAccessKey-1].SetValue(pc.SES.SecretAccessKey)
+				m.inputs[settingsSESProfile-1].SetValue(pc.SES.Profile)
+				m.inputs[settingsSESConfigurationSet-1].SetValue(pc.SES.ConfigurationSet)
+				m.inputs[settingsSESEndpoint-1].SetValue(pc.SES.Endpoint)
+			}
+		case config.ProviderSendmail:
+			if pc.Sendmail != nil {
+				m.inputs[settingsSendmailPath-1].SetValue(pc.Sendmail.Path)
+			}
 		}
 	}
 
+	m.testing = false
+	m.testResults = nil
+
 	// Focus first field - provider type in add mode, name in edit mode
 	if m.isEditing {
 		m.FocusIndex = settingsName
@@ -551,7 +632,7 @@ func (m *SettingsModel) isValidField(fieldIndex int) bool {
 	}
 
 	// Buttons
-	if fieldIndex == settingsSaveButton || fieldIndex == settingsCancelButton {
+	if fieldIndex >= settingsSaveButton && fieldIndex <= settingsSendTestButton {
 		return true
 	}
 
@@ -570,6 +651,12 @@ func (m *SettingsModel) isValidField(fieldIndex int) bool {
 		return fieldIndex >= settingsSparkPostAPIKey && fieldIndex <= settingsSparkPostURL
 	case config.ProviderPostal:
 		return fieldIndex >= settingsPostalURL && fieldIndex <= settingsPostalAPIKey
+	case config.ProviderWebhook:
+		return fieldIndex >= settingsWebhookURL && fieldIndex <= settingsWebhookToken
+	case config.ProviderSES:
+		return fieldIndex >= settingsSESRegion && fieldIndex <= settingsSESEndpoint
+	case config.ProviderSendmail:
+		return fieldIndex == settingsSendmailPath
 	}
 
 	return false
@@ -679,6 +766,22 @@ func (m SettingsModel) renderListView() string {
 		}
 	}
 
+	// Failover chains are edited in config.yaml and listed here for reference
+	if chains := m.config.ListChains(); len(chains) > 0 {
+		b.WriteString("\n")
+		b.WriteString(ui.SubtitleStyle.Render(fmt.Sprintf("Failover Chains (%d)", len(chains))))
+		b.WriteString("\n\n")
+		for _, name := range chains {
+			chain, _ := m.config.GetChain(name)
+			display := "  " + name + ui.DividerStyle.Render(" "+strings.Join(chain.Providers, " → "))
+			if name == m.config.DefaultProvider {
+				display += ui.SuccessStyle.Render(" (default)")
+			}
+			b.WriteString(ui.DisplayLabelStyle.Render(display))
+			b.WriteString("\n")
+		}
+	}
+
 	b.WriteString("\n")
 	b.WriteString(ui.RenderHelp(
 		"↑/↓", "navigate",
@@ -767,6 +870,32 @@ func (m SettingsModel) renderFormView(title string) string {
 		b.WriteString("\n")
 		m.renderField(&b, "URL", settingsPostalURL, true)
 		m.renderField(&b, "API Key", settingsPostalAPIKey, true)
+
+	case config.ProviderWebhook:
+		b.WriteString(ui.SubtitleStyle.Render("Webhook Configuration"))
+		b.WriteString("\n")
+		m.renderField(&b, "URL", settingsWebhookURL, true)
+		m.renderField(&b, "Method", settingsWebhookMethod, true)
+		m.renderField(&b, "Username", settingsWebhookUsername, true)
+		m.renderField(&b, "Password", settingsWebhookPassword, true)
+		m.renderField(&b, "Token", settingsWebhookToken, true)
+		b.WriteString(ui.HelpStyle.Render("Headers and body template can be set in config.yaml"))
+		b.WriteString("\n")
+
+	case config.ProviderSES:
+		b.WriteString(ui.SubtitleStyle.Render("Amazon SES Configuration"))
+		b.WriteString("\n")
+		m.renderField(&b, "Region", settingsSESRegion, true)
+		m.renderField(&b, "Access Key ID", settingsSESAccessKeyID, true)
+		m.renderField(&b, "Secret Key", settingsSESSecretAccessKey, true)
+		m.renderField(&b, "Profile", settingsSESProfile, true)
+		m.renderField(&b, "Config Set", settingsSESConfigurationSet, true)
+		m.renderField(&b, "Endpoint", settingsSESEndpoint, true)
+
+	case config.ProviderSendmail:
+		b.WriteString(ui.SubtitleStyle.Render("Sendmail Configuration"))
+		b.WriteString("\n")
+		m.renderField(&b, "Path", settingsSendmailPath, true)
 	}
 
 	b.WriteString("\n")
@@ -789,8 +918,46 @@ func (m SettingsModel) renderFormView(title string) string {
 		b.WriteString(ui.ButtonStyle.Render(cancelText))
 	}
 
+	b.WriteString("  ")
+
+	// Connection test buttons
+	testText := "[ Test Connection ]"
+	if m.FocusIndex == settingsTestButton {
+		b.WriteString(ui.ButtonFocusedStyle.Render(testText))
+	} else {
+		b.WriteString(ui.ButtonStyle.Render(testText))
+	}
+
+	b.WriteString("  ")
+
+	sendTestText := "[ Send Test Email ]"
+	if m.FocusIndex == settingsSendTestButton {
+		b.WriteString(ui.ButtonFocusedStyle.Render(sendTestText))
+	} else {
+		b.WriteString(ui.ButtonStyle.Render(sendTestText))
+	}
+
 	b.WriteString("\n")
 
+	// Connection test results
+	if m.testing {
+		b.WriteString("\n")
+		b.WriteString(ui.InfoStyle.Render("Testing connection..."))
+	} else if len(m.testResults) > 0 {
+		b.WriteString("\n")
+		b.WriteString(ui.SubtitleStyle.Render("Connection Test"))
+		b.WriteString("\n")
+		for _, d := range m.testResults {
+			if d.OK {
+				b.WriteString(ui.SuccessStyle.UnsetPadding().Render("✓ " + d.Step + ": "))
+			} else {
+				b.WriteString(ui.ErrorStyle.UnsetPadding().Render("✗ " + d.Step + ": "))
+			}
+			b.WriteString(d.Detail)
+			b.WriteString("\n")
+		}
+	}
+
 	// Status messages
 	if m.saved {
 		b.WriteString("\n")
@@ -804,7 +971,7 @@ func (m SettingsModel) renderFormView(title string) string {
 	b.WriteString(ui.RenderHelp(
 		"Tab", "next field",
 		"←/→", "change type",
-		"Enter", "save",
+		"Enter", "save/test",
 		"Esc", "cancel",
 	))
 
@@ -836,71 +1003,125 @@ func (m *SettingsModel) renderField(b *strings.Builder, label string, fieldIndex
 	b.WriteString("\n")
 }
 
-func (m *SettingsModel) saveProviderConfig() tea.Cmd {
-	return func() tea.Msg {
-		// Build provider config from inputs (subtract 1 for settingsProviderType offset)
-		pc := &config.ProviderConfig{
-			Name:        m.inputs[settingsName-1].Value(),
-			Type:        m.providerTypes[m.providerTypeIdx],
-			FromAddress: m.inputs[settingsFromAddress-1].Value(),
-			FromName:    m.inputs[settingsFromName-1].Value(),
-		}
+// buildProviderConfig creates a provider config from the form inputs
+func (m *SettingsModel) buildProviderConfig() (*config.ProviderConfig, error) {
+	// Build provider config from inputs (subtract 1 for settingsProviderType offset)
+	pc := &config.ProviderConfig{
+		Name:        m.inputs[settingsName-1].Value(),
+		Type:        m.providerTypes[m.providerTypeIdx],
+		FromAddress: m.inputs[settingsFromAddress-1].Value(),
+		FromName:    m.inputs[settingsFromName-1].Value(),
+	}
 
-		// If editing, delete the old entry first (in case name changed)
-		if m.isEditing && m.editingName != pc.Name {
-			m.config.DeleteProvider(m.editingName)
-		}
+	// Remember the existing entry so settings not shown in the form survive
+	var existing *config.ProviderConfig
+	if m.isEditing {
+		existing, _ = m.config.GetProvider(m.editingName)
+	}
+	if existing != nil {
+		pc.Identities = existing.Identities
+		pc.AllowedDomains = existing.AllowedDomains
+		pc.Signature = existing.Signature
+		pc.MaxMessageSizeMB = existing.MaxMessageSizeMB
+		pc.RateLimit = existing.RateLimit
+		pc.MaxRecipients = existing.MaxRecipients
+		pc.DKIM = existing.DKIM
+		pc.PGP = existing.PGP
+		pc.SMIME = existing.SMIME
+	}
 
-		// Set provider-specific config
-		switch pc.Type {
-		case config.ProviderMailgun:
-			pc.Mailgun = &config.MailgunConfig{
-				APIKey: m.inputs[settingsMailgunAPIKey-1].Value(),
-				Domain: m.inputs[settingsMailgunDomain-1].Value(),
-				URL:    m.inputs[settingsMailgunURL-1].Value(),
-			}
+	// Set provider-specific config
+	switch pc.Type {
+	case config.ProviderMailgun:
+		pc.Mailgun = &config.MailgunConfig{
+			APIKey: m.inputs[settingsMailgunAPIKey-1].Value(),
+			Domain: m.inputs[settingsMailgunDomain-1].Value(),
+			URL:    m.inputs[settingsMailgunURL-1].Value(),
+		}
 
-		case config.ProviderSMTP:
-			port := 587
-			portStr := m.inputs[settingsSMTPPort-1].Value()
-			if portStr != "" {
-				parsedPort, err := strconv.Atoi(portStr)
-				if err != nil {
-					return ConfigErrorMsg{Error: fmt.Sprintf("invalid port number: %s", portStr)}
-				}
-				if parsedPort < 1 || parsedPort > 65535 {
-					return ConfigErrorMsg{Error: fmt.Sprintf("port must be between 1 and 65535, got %d", parsedPort)}
-				}
-				port = parsedPort
+	case config.ProviderSMTP:
+		port := 587
+		portStr := m.inputs[settingsSMTPPort-1].Value()
+		if portStr != "" {
+			parsedPort, err := strconv.Atoi(portStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port number: %s", portStr)
 			}
-			pc.SMTP = &config.SMTPConfig{
-				Host:     m.inputs[settingsSMTPHost-1].Value(),
-				Port:     port,
-				Username: m.inputs[settingsSMTPUsername-1].Value(),
-				Password: m.inputs[settingsSMTPPassword-1].Value(),
+			if parsedPort < 1 || parsedPort > 65535 {
+				return nil, fmt.Errorf("port must be between 1 and 65535, got %d", parsedPort)
 			}
+			port = parsedPort
+		}
+		pc.SMTP = &config.SMTPConfig{
+			Host:     m.inputs[settingsSMTPHost-1].Value(),
+			Port:     port,
+			Username: m.inputs[settingsSMTPUsername-1].Value(),
+			Password: m.inputs[settingsSMTPPassword-1].Value(),
+		}
 
-		case config.ProviderSendGrid:
-			pc.SendGrid = &config.SendGridConfig{
-				APIKey: m.inputs[settingsSendGridAPIKey-1].Value(),
-			}
+	case config.ProviderSendGrid:
+		pc.SendGrid = &config.SendGridConfig{
+			APIKey: m.inputs[settingsSendGridAPIKey-1].Value(),
+		}
 
-		case config.ProviderPostmark:
-			pc.Postmark = &config.PostmarkConfig{
-				APIKey: m.inputs[settingsPostmarkAPIKey-1].Value(),
-			}
+	case config.ProviderPostmark:
+		pc.Postmark = &config.PostmarkConfig{
+			APIKey: m.inputs[settingsPostmarkAPIKey-1].Value(),
+		}
 
-		case config.ProviderSparkPost:
-			pc.SparkPost = &config.SparkPostConfig{
-				APIKey: m.inputs[settingsSparkPostAPIKey-1].Value(),
-				URL:    m.inputs[settingsSparkPostURL-1].Value(),
-			}
+	case config.ProviderSparkPost:
+		pc.SparkPost = &config.SparkPostConfig{
+			APIKey: m.inputs[settingsSparkPostAPIKey-1].Value(),
+			URL:    m.inputs[settingsSparkPostURL-1].Value(),
+		}
 
-		case config.ProviderPostal:
-			pc.Postal = &config.PostalConfig{
-				URL:    m.inputs[settingsPostalURL-1].Value(),
-				APIKey: m.inputs[settingsPostalAPIKey-1].Value(),
-			}
+	case config.ProviderPostal:
+		pc.Postal = &config.PostalConfig{
+			URL:    m.inputs[settingsPostalURL-1].Value(),
+			APIKey: m.inputs[settingsPostalAPIKey-1].Value(),
+		}
+
+	case config.ProviderWebhook:
+		pc.Webhook = &config.WebhookConfig{}
+		// Keep headers and body template, which are only editable in config.yaml
+		if existing != nil && existing.Webhook != nil {
+			*pc.Webhook = *existing.Webhook
+		}
+		pc.Webhook.URL = m.inputs[settingsWebhookURL-1].Value()
+		pc.Webhook.Method = m.inputs[settingsWebhookMethod-1].Value()
+		pc.Webhook.Username = m.inputs[settingsWebhookUsername-1].Value()
+		pc.Webhook.Password = m.inputs[settingsWebhookPassword-1].Value()
+		pc.Webhook.Token = m.inputs[settingsWebhookToken-1].Value()
+
+	case config.ProviderSES:
+		pc.SES = &config.SESConfig{
+			Region:           m.inputs[settingsSESRegion-1].Value(),
+			AccessKeyID:      m.inputs[settingsSESAccessKeyID-1].Value(),
+			SecretAccessKey:  m.inputs[settingsSESSecretAccessKey-1].Value(),
+			Profile:          m.inputs[settingsSESProfile-1].Value(),
+			ConfigurationSet: m.inputs[settingsSESConfigurationSet-1].Value(),
+			Endpoint:         m.inputs[settingsSESEndpoint-1].Value(),
+		}
+
+	case config.ProviderSendmail:
+		pc.Sendmail = &config.SendmailConfig{
+			Path: m.inputs[settingsSendmailPath-1].Value(),
+		}
+	}
+
+	return pc, nil
+}
+
+func (m *SettingsModel) saveProviderConfig() tea.Cmd {
+	return func() tea.Msg {
+		pc, err := m.buildProviderConfig()
+		if err != nil {
+			return ConfigErrorMsg{Error: err.Error()}
+		}
+
+		// If editing, delete the old entry first (in case name changed)
+		if m.isEditing && m.editingName != pc.Name {
+			m.config.DeleteProvider(m.editingName)
 		}
 
 		// Add to config
@@ -923,6 +1144,26 @@ func (m *SettingsModel) saveProviderConfig() tea.Cmd {
 	}
 }
 
+// testConnection verifies the provider in the form without saving it,
+// optionally sending a test email to its From address
+func (m *SettingsModel) testConnection(sendTest bool) tea.Cmd {
+	pc, err := m.buildProviderConfig()
+	if err != nil {
+		return func() tea.Msg { return ConnectionTestMsg{Err: err} }
+	}
+	maxAttachmentMB := m.config.GetLimits().MaxAttachmentSizeMB
+
+	return func() tea.Msg {
+		diagnostics, err := mailer.Verify(pc)
+		if err == nil && sendTest {
+			var d mailer.Diagnostic
+			d, err = mailer.SendTestMessage(pc, maxAttachmentMB)
+			diagnostics = append(diagnostics, d)
+		}
+		return ConnectionTestMsg{Diagnostics: diagnostics, Err: err}
+	}
+}
+
 func createInput(placeholder string, charLimit, width int) textinput.Model {
 	input := textinput.New()
 	input.Placeholder = placeholder
@@ -938,3 +1179,9 @@ type ConfigSavedMsg struct{}
 type ConfigErrorMsg struct {
 	Error string
 }
+
+// ConnectionTestMsg carries the result of a provider connection test
+type ConnectionTestMsg struct {
+	Diagnostics []mailer.Diagnostic
+	Err         error
+}
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"mailgloss/config"
+	"mailgloss/mailer"
+	"mailgloss/storage"
+	"mailgloss/ui"
+)
+
+// statsRanges are the periods the statistics can cover, 0 days for all
+var statsRanges = []struct {
+	name string
+	days int
+}{
+	{"Last 7 days", 7},
+	{"Last 30 days", 30},
+	{"Last 90 days", 90},
+	{"Last year", 365},
+	{"All time", 0},
+}
+
+const (
+	statsChartHeight = 6 // Rows of the daily chart
+	statsTopItems    = 5 // Entries shown in each top list
+)
+
+// chartBlocks draw a column in eighths of a row
+var chartBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// StatsModel represents the statistics tab, summarizing the history
+type StatsModel struct {
+	history   *storage.History
+	rangeIdx  int  // Index in statsRanges
+	weekly    bool // Chart sends per week rather than per day
+	exported  string
+	exportErr string
+	width     int
+	height    int
+}
+
+// NewStatsModel creates a new statistics model
+func NewStatsModel(history *storage.History) StatsModel {
+	return StatsModel{
+		history:  history,
+		rangeIdx: 1,
+	}
+}
+
+// Init initializes the statistics model
+func (m StatsModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages for the statistics model
+func (m StatsModel) Update(msg tea.Msg) (StatsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "left", "h":
+			m.rangeIdx = (m.rangeIdx + len(statsRanges) - 1) % len(statsRanges)
+			m.exported, m.exportErr = "", ""
+		case "right", "l":
+			m.rangeIdx = (m.rangeIdx + 1) % len(statsRanges)
+			m.exported, m.exportErr = "", ""
+		case "w":
+			m.weekly = !m.weekly
+		case "e":
+			m.exported, m.exportErr = "", ""
+			path, err := m.export()
+			if err != nil {
+				m.exportErr = err.Error()
+			} else {
+				m.exported = path
+			}
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	}
+
+	return m, nil
+}
+
+// stats summarizes the history over the selected range
+func (m StatsModel) stats() storage.Stats {
+	now := time.Now()
+	var from time.Time
+	if days := statsRanges[m.rangeIdx].days; days > 0 {
+		from = now.AddDate(0, 0, -(days - 1))
+	}
+	return storage.Summarize(m.history.Emails, from, now)
+}
+
+// View renders the statistics model
+func (m StatsModel) View() string {
+	var b strings.Builder
+	s := m.stats()
+
+	b.WriteString(ui.TitleStyle.Render("Statistics"))
+	b.WriteString("\n\n")
+
+	var ranges []string
+	for i, r := range statsRanges {
+		if i == m.rangeIdx {
+			ranges = append(ranges, ui.SelectedItemStyle.Render("["+r.name+"]"))
+		} else {
+			ranges = append(ranges, ui.MutedTextStyle.Render(r.name))
+		}
+	}
+	b.WriteString(strings.Join(ranges, "  "))
+	b.WriteString("\n\n")
+
+	if s.Total == 0 {
+		b.WriteString(ui.InfoStyle.Render("📭 No emails sent in this period"))
+		b.WriteString("\n\n")
+		b.WriteString(ui.RenderHelp("←/→", "range"))
+		return b.String()
+	}
+
+	b.WriteString(ui.SubtitleStyle.Render(fmt.Sprintf("%d emails: %d sent, %d partially sent, %d failed (%s success)",
+		s.Total, s.Succeeded, s.Partial, s.Failed, percent(s.Succeeded, s.Total))))
+	b.WriteString("\n\n")
+
+	// Sends over time
+	if m.weekly {
+		b.WriteString(ui.DisplayLabelStyle.Render("Sends per week:"))
+		b.WriteString("\n")
+		b.WriteString(renderWeekChart(s.PerWeek(), m.chartWidth()))
+	} else {
+		b.WriteString(ui.DisplayLabelStyle.Render("Sends per day:"))
+		b.WriteString("\n")
+		b.WriteString(renderDayChart(s.PerDay, m.chartWidth()))
+	}
+	b.WriteString("\n")
+
+	// Providers
+	b.WriteString(ui.DisplayLabelStyle.Render("Providers:"))
+	b.WriteString("\n")
+	for _, p := range s.Providers {
+		line := fmt.Sprintf("  %-20s %5d sent  %6s success", p.Name, p.Total(), percent(p.Succeeded, p.Total()))
+		b.WriteString(line)
+		if p.Partial+p.Failed > 0 {
+			b.WriteString(ui.ErrorTextStyle.Render(fmt.Sprintf("  %d partial, %d failed", p.Partial, p.Failed)))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	// Top recipients, domains and templates
+	for _, top := range []struct {
+		label  string
+		counts []storage.NameCount
+	}{
+		{"Top recipients:", s.TopRecipients},
+		{"Top domains:", s.TopDomains},
+		{"Top templates:", s.TopTemplates},
+	} {
+		if len(top.counts) == 0 {
+			continue
+		}
+		b.WriteString(ui.DisplayLabelStyle.Render(top.label))
+		b.WriteString("\n")
+		for _, c := range top.counts[:min(statsTopItems, len(top.counts))] {
+			b.WriteString(fmt.Sprintf("  %5d  %s\n", c.Count, c.Name))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(ui.DisplayLabelStyle.Render("Attachments:"))
+	if s.Attachments == 0 {
+		b.WriteString(" none\n")
+	} else {
+		b.WriteString(fmt.Sprintf(" %d, %s on average\n", s.Attachments, mailer.FormatSize(s.AverageAttachmentSize())))
+	}
+	b.WriteString("\n")
+
+	if m.exported != "" {
+		b.WriteString(ui.SuccessStyle.Render("✓ Summary exported to " + m.exported))
+		b.WriteString("\n\n")
+	}
+	if m.exportErr != "" {
+		b.WriteString(ui.ErrorStyle.Render("Export failed: " + m.exportErr))
+		b.WriteString("\n\n")
+	}
+
+	chart := "per week"
+	if m.weekly {
+		chart = "per day"
+	}
+	b.WriteString(ui.RenderHelp("←/→", "range", "w", chart, "e", "export summary"))
+	return b.String()
+}
+
+// chartWidth returns the columns available to the charts
+func (m StatsModel) chartWidth() int {
+	if m.width <= 0 {
+		return 60
+	}
+	return max(m.width-16, 20)
+}
+
+// renderDayChart draws the daily counts as columns, the most recent days
+// that fit in width
+func renderDayChart(days []storage.PeriodCount, width int) string {
+	if len(days) > width {
+		days = days[len(days)-width:]
+	}
+	peak := 1
+	for _, d := range days {
+		peak = max(peak, d.Count)
+	}
+
+	var b strings.Builder
+	for row := statsChartHeight - 1; row >= 0; row-- {
+		switch row {
+		case statsChartHeight - 1:
+			b.WriteString(fmt.Sprintf("%5d ┤", peak))
+		case 0:
+			b.WriteString(fmt.Sprintf("%5d ┤", 0))
+		default:
+			b.WriteString("      │")
+		}
+		for _, d := range days {
+			eighths := d.Count * statsChartHeight * 8 / peak
+			fill := min(max(eighths-row*8, 0), 8)
+			if d.Count > 0 && row == 0 && fill == 0 {
+				fill = 1 // Keep small counts visible
+			}
+			b.WriteRune(chartBlocks[fill])
+		}
+		b.WriteString("\n")
+	}
+
+	first := days[0].Start.Format("Jan 02")
+	last := days[len(days)-1].Start.Format("Jan 02")
+	gap := max(len(days)-len(first)-len(last), 1)
+	b.WriteString(ui.MutedTextStyle.Render("       " + first + strings.Repeat(" ", gap) + last))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderWeekChart draws the weekly counts as horizontal bars
+func renderWeekChart(weeks []storage.PeriodCount, width int) string {
+	peak := 1
+	for _, w := range weeks {
+		peak = max(peak, w.Count)
+	}
+	barWidth := max(width-16, 10)
+
+	var b strings.Builder
+	for _, w := range weeks {
+		bar := strings.Repeat("█", w.Count*barWidth/peak)
+		if w.Count > 0 && bar == "" {
+			bar = "▏"
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s %d\n", w.Start.Format("Jan 02"), bar, w.Count))
+	}
+	return b.String()
+}
+
+// percent formats a share as a percentage
+func percent(part, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
+
+// export writes the summary as Markdown next to the config file and
+// returns its path
+func (m StatsModel) export() (string, error) {
+	configPath, err := config.GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(filepath.Dir(configPath), "stats-"+time.Now().Format("2006-01-02")+".md")
+	if err := os.WriteFile(path, []byte(statsMarkdown(m.stats(), statsRanges[m.rangeIdx].name)), 0600); err != nil {
+		return "", fmt.Errorf("failed to write summary: %w", err)
+	}
+	return path, nil
+}
+
+// statsMarkdown renders the stats as a Markdown summary
+func statsMarkdown(s storage.Stats, rangeName string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# MailGloss statistics: %s\n\n", rangeName)
+	fmt.Fprintf(&b, "%s to %s\n\n", s.From.Format("2006-01-02"), s.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Fprintf(&b, "- Emails: %d\n- Sent: %d\n- Partially sent: %d\n- Failed: %d\n- Success rate: %s\n",
+		s.Total, s.Succeeded, s.Partial, s.Failed, percent(s.Succeeded, s.Total))
+	if s.Attachments > 0 {
+		fmt.Fprintf(&b, "- Attachments: %d, %s on average\n", s.Attachments, mailer.FormatSize(s.AverageAttachmentSize()))
+	}
+
+	b.WriteString("\n## Sends per week\n\n| Week of | Emails |\n|---|---:|\n")
+	for _, w := range s.PerWeek() {
+		fmt.Fprintf(&b, "| %s | %d |\n", w.Start.Format("2006-01-02"), w.Count)
+	}
+
+	b.WriteString("\n## Providers\n\n| Provider | Emails | Sent | Partial | Failed | Success rate |\n|---|---:|---:|---:|---:|---:|\n")
+	for _, p := range s.Providers {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %s |\n", p.Name, p.Total(), p.Succeeded, p.Partial, p.Failed, percent(p.Succeeded, p.Total()))
+	}
+
+	for _, top := range []struct {
+		title  string
+		counts []storage.NameCount
+	}{
+		{"Top recipients", s.TopRecipients},
+		{"Top domains", s.TopDomains},
+		{"Top templates", s.TopTemplates},
+	} {
+		if len(top.counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n| Name | Emails |\n|---|---:|\n", top.title)
+		for _, c := range top.counts[:min(statsTopItems*2, len(top.counts))] {
+			fmt.Fprintf(&b, "| %s | %d |\n", c.Name, c.Count)
+		}
+	}
+	return b.String()
+}
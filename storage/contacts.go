@@ -11,13 +11,15 @@ import (
 
 // Contact represents a contact in the address book
 type Contact struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Notes     string    `json:"notes,omitempty"`
-	Tags      []string  `json:"tags,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Email            string    `json:"email"`
+	Notes            string    `json:"notes,omitempty"`
+	Tags             []string  `json:"tags,omitempty"`
+	PGPKey           string    `json:"pgp_key,omitempty"`           // ASCII armored public key
+	SMIMECertificate string    `json:"smime_certificate,omitempty"` // PEM encoded
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // Contacts manages the contact storage
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"mailgloss/logger"
@@ -12,19 +14,155 @@ import (
 
 // SentEmail represents a sent email in history
 type SentEmail struct {
-	ID           string    `json:"id"`
-	From         string    `json:"from"`
-	To           []string  `json:"to"`
-	CC           []string  `json:"cc,omitempty"`
-	BCC          []string  `json:"bcc,omitempty"`
-	Subject      string    `json:"subject"`
-	Body         string    `json:"body"`
-	Attachments  []string  `json:"attachments,omitempty"`
-	SentAt       time.Time `json:"sent_at"`
-	Provider     string    `json:"provider"`
-	ProviderName string    `json:"provider_name"`
-	Status       string    `json:"status"` // "success" or "failed"
-	Error        string    `json:"error,omitempty"`
+	ID             string            `json:"id"`
+	From           string            `json:"from"`
+	To             []string          `json:"to"`
+	CC             []string          `json:"cc,omitempty"`
+	BCC            []string          `json:"bcc,omitempty"`
+	Subject        string            `json:"subject"`
+	Body           string            `json:"body"`
+	Attachments    []string          `json:"attachments,omitempty"`
+	InlineImages   []string          `json:"inline_images,omitempty"`
+	Bundle         []string          `json:"bundle,omitempty"` // Files sent together as attachments.zip
+	ReplyTo        string            `json:"reply_to,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Priority       string            `json:"priority,omitempty"` // "high", "low" or empty for normal
+	ReadReceipt    bool              `json:"read_receipt,omitempty"`
+	PGPSigned      bool              `json:"pgp_signed,omitempty"`
+	PGPEncrypted   bool              `json:"pgp_encrypted,omitempty"`
+	SMIMESigned    bool              `json:"smime_signed,omitempty"`
+	SMIMEEncrypted bool              `json:"smime_encrypted,omitempty"`
+	Template       string            `json:"template,omitempty"` // Name of the template the email started from
+	SentAt         time.Time         `json:"sent_at"`
+	Provider       string            `json:"provider"`
+	ProviderName   string            `json:"provider_name"`
+	Status         string            `json:"status"` // "success", "partial" or "failed"
+	Error          string            `json:"error,omitempty"`
+	// DeliveredBy is the provider that handled the final attempt, which
+	// differs from ProviderName when sending through a failover chain
+	DeliveredBy string            `json:"delivered_by,omitempty"`
+	Attempts    []DeliveryAttempt `json:"attempts,omitempty"`
+	// MessageID, StatusCode and Response are the provider's reply to the
+	// final attempt, to find the email in the provider's dashboard
+	MessageID  string `json:"message_id,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Response   string `json:"response,omitempty"`
+	// Snapshots describe the stored copies of Attachments and InlineImages
+	Snapshots []AttachmentSnapshot `json:"snapshots,omitempty"`
+	// Batches are the messages the email was split into to stay within the
+	// provider's recipient limit, the first one including To and CC
+	Batches []DeliveryBatch `json:"batches,omitempty"`
+	// Events are the delivery events reported by provider webhooks after
+	// sending, oldest first
+	Events []DeliveryEvent `json:"events,omitempty"`
+}
+
+// DeliveryEvent records an event reported by the provider after sending,
+// such as a delivery, bounce or open
+type DeliveryEvent struct {
+	Type      string    `json:"type"`
+	Recipient string    `json:"recipient,omitempty"`
+	Time      time.Time `json:"time"`
+	Detail    string    `json:"detail,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+}
+
+// DeliveryBatch records one of the messages an email was split into
+type DeliveryBatch struct {
+	Recipients   int    `json:"recipients"`
+	ProviderName string `json:"provider_name,omitempty"`
+	Error        string `json:"error,omitempty"`
+	MessageID    string `json:"message_id,omitempty"`
+	// FailedBCC lists the BCC recipients of a batch that failed
+	FailedBCC []string `json:"failed_bcc,omitempty"`
+}
+
+// RetryRecipients returns a copy of a partially sent email addressed only
+// to the recipients of its failed batches
+func (e SentEmail) RetryRecipients() SentEmail {
+	if e.Status != "partial" || len(e.Batches) == 0 {
+		return e
+	}
+	if e.Batches[0].Error == "" {
+		e.To, e.CC = nil, nil
+	}
+	e.BCC = nil
+	for _, batch := range e.Batches {
+		e.BCC = append(e.BCC, batch.FailedBCC...)
+	}
+	return e
+}
+
+// DeliveryAttempt records one provider tried while sending an email
+type DeliveryAttempt struct {
+	ProviderName string `json:"provider_name"`
+	Error        string `json:"error,omitempty"`
+	MessageID    string `json:"message_id,omitempty"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	Response     string `json:"response,omitempty"`
+}
+
+// MessageIDs returns every provider message ID recorded for an email
+func (e SentEmail) MessageIDs() []string {
+	var ids []string
+	if e.MessageID != "" {
+		ids = append(ids, e.MessageID)
+	}
+	for _, attempt := range e.Attempts {
+		if attempt.MessageID != "" && attempt.MessageID != e.MessageID {
+			ids = append(ids, attempt.MessageID)
+		}
+	}
+	for _, batch := range e.Batches {
+		if batch.MessageID != "" {
+			ids = append(ids, batch.MessageID)
+		}
+	}
+	return ids
+}
+
+// HasMessageID reports whether a message ID reported with a delivery event
+// belongs to the email. Angle brackets are ignored, and SendGrid's event
+// IDs extend the sent ID with a dot and a suffix.
+func (e SentEmail) HasMessageID(id string) bool {
+	id = strings.Trim(id, "<>")
+	if id == "" {
+		return false
+	}
+	for _, sent := range e.MessageIDs() {
+		sent = strings.Trim(sent, "<>")
+		if id == sent || strings.HasPrefix(id, sent+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether an email's subject, addresses, provider, message
+// IDs or provider response contain the query, ignoring case
+func (e SentEmail) Matches(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+
+	fields := []string{e.Subject, e.From, e.ReplyTo, e.ProviderName, e.DeliveredBy, e.Response, e.Error}
+	fields = append(fields, e.To...)
+	fields = append(fields, e.CC...)
+	fields = append(fields, e.BCC...)
+	fields = append(fields, e.MessageIDs()...)
+	for _, attempt := range e.Attempts {
+		fields = append(fields, attempt.Response)
+	}
+	for _, event := range e.Events {
+		fields = append(fields, event.Type, event.Detail)
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
 }
 
 // History manages the email history
@@ -158,6 +296,55 @@ func (h *History) GetAll() []SentEmail {
 	return h.GetRecent(len(h.Emails))
 }
 
+// AddEvents records delivery events with the emails carrying their message
+// ID and saves the history. Events recorded before, as when a provider
+// retries a webhook, are skipped. Returns the number of events added.
+func (h *History) AddEvents(events []DeliveryEvent) (int, error) {
+	added := 0
+	for _, event := range events {
+		for i := range h.Emails {
+			email := &h.Emails[i]
+			if !email.HasMessageID(event.MessageID) {
+				continue
+			}
+			if !hasEvent(email.Events, event) {
+				email.Events = append(email.Events, event)
+				sort.SliceStable(email.Events, func(a, b int) bool {
+					return email.Events[a].Time.Before(email.Events[b].Time)
+				})
+				added++
+			}
+			break
+		}
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, h.Save()
+}
+
+// hasEvent reports whether an event was recorded already
+func hasEvent(events []DeliveryEvent, event DeliveryEvent) bool {
+	for _, e := range events {
+		if e.Type == event.Type && e.Recipient == event.Recipient && e.Time.Equal(event.Time) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns the emails matching a query in reverse chronological
+// order, see SentEmail.Matches
+func (h *History) Search(query string) []SentEmail {
+	var result []SentEmail
+	for _, email := range h.GetAll() {
+		if email.Matches(query) {
+			result = append(result, email)
+		}
+	}
+	return result
+}
+
 // Clear removes all emails from history
 func (h *History) Clear() error {
 	h.Emails = []SentEmail{}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistorySearch(t *testing.T) {
+	h := NewHistory(10)
+	h.Emails = []SentEmail{
+		{ID: "1", Subject: "Invoice", To: []string{"alice@example.com"}, MessageID: "<20240101.abc@mg.example.com>"},
+		{ID: "2", Subject: "Welcome", To: []string{"bob@example.com"}, Batches: []DeliveryBatch{{MessageID: "batch-42"}}},
+		{ID: "3", Subject: "Reminder", To: []string{"carol@example.com"}, Response: "550 5.1.1 User unknown"},
+	}
+
+	tests := map[string][]string{
+		"":              {"3", "2", "1"},
+		"ABC@mg":        {"1"},
+		"batch-42":      {"2"},
+		"user unknown":  {"3"},
+		"bob@":          {"2"},
+		"no such thing": nil,
+	}
+	for query, want := range tests {
+		var got []string
+		for _, email := range h.Search(query) {
+			got = append(got, email.ID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestAddEvents(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	sent := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	h := NewHistory(10)
+	h.Emails = []SentEmail{
+		{ID: "1", MessageID: "<20240102.abc@mg.example.com>"},
+		{ID: "2", MessageID: "sg-XYZ"},
+	}
+
+	delivered := DeliveryEvent{Type: "delivered", Recipient: "bob@example.com", Time: sent.Add(time.Minute), MessageID: "20240102.abc@mg.example.com"}
+	events := []DeliveryEvent{
+		{Type: "opened", Recipient: "bob@example.com", Time: sent.Add(time.Hour), MessageID: "sg-XYZ.filter0001.16648.0"},
+		delivered,
+		delivered, // Retried webhook
+		{Type: "bounced", Time: sent, MessageID: "unknown"},
+	}
+	added, err := h.AddEvents(events)
+	if err != nil {
+		t.Fatalf("AddEvents() error = %v", err)
+	}
+	if added != 2 {
+		t.Errorf("AddEvents() = %d, want 2", added)
+	}
+	if len(h.Emails[0].Events) != 1 || h.Emails[0].Events[0].Type != "delivered" {
+		t.Errorf("first email events = %+v, want delivered", h.Emails[0].Events)
+	}
+	if len(h.Emails[1].Events) != 1 || h.Emails[1].Events[0].Type != "opened" {
+		t.Errorf("second email events = %+v, want opened", h.Emails[1].Events)
+	}
+}
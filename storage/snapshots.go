@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mailgloss/logger"
+)
+
+// AttachmentSnapshot describes a copy of a sent attachment or inline image
+type AttachmentSnapshot struct {
+	Path        string `json:"path"` // Original path at send time
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content_type"`
+	Inline      bool   `json:"inline,omitempty"`
+}
+
+// Snapshots stores content-addressed copies of sent files under the data
+// dir, so history shows what was actually sent and resends don't depend on
+// the original files. Copies are removed after the retention period, and
+// the least recently sent ones first when over the size budget. Snapshots
+// are taken by concurrent sends, so storing and pruning are serialized.
+type Snapshots struct {
+	mu        sync.Mutex
+	dir       string
+	maxBytes  int64
+	retention time.Duration
+}
+
+// NewSnapshots creates a snapshot store in configDir/attachments
+func NewSnapshots(configDir string, maxSizeMB, retentionDays int) (*Snapshots, error) {
+	dir := filepath.Join(configDir, "attachments")
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create attachments directory: %w", err)
+	}
+	return &Snapshots{
+		dir:       dir,
+		maxBytes:  int64(maxSizeMB) * 1024 * 1024,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+	}, nil
+}
+
+// Store copies a file into the store, reusing an existing copy with the
+// same content
+func (s *Snapshots) Store(path string, inline bool) (AttachmentSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := os.Open(path)
+	if err != nil {
+		return AttachmentSnapshot{}, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "objects"), ".tmp-*")
+	if err != nil {
+		return AttachmentSnapshot{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return AttachmentSnapshot{}, fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	object := s.objectPath(sum)
+	if _, err := os.Stat(object); err == nil {
+		// Already stored, mark it as recently sent
+		now := time.Now()
+		if err := os.Chtimes(object, now, now); err != nil {
+			return AttachmentSnapshot{}, err
+		}
+	} else if err := os.Rename(tmp.Name(), object); err != nil {
+		return AttachmentSnapshot{}, err
+	}
+
+	contentType, err := detectContentType(object, path)
+	if err != nil {
+		return AttachmentSnapshot{}, err
+	}
+
+	logger.Debug("Stored attachment snapshot", "path", path, "sha256", sum, "size", size)
+	return AttachmentSnapshot{
+		Path:        path,
+		Filename:    filepath.Base(path),
+		Size:        size,
+		SHA256:      sum,
+		ContentType: contentType,
+		Inline:      inline,
+	}, nil
+}
+
+// Exists reports whether the snapshot's copy is still stored
+func (s *Snapshots) Exists(snap AttachmentSnapshot) bool {
+	_, err := os.Stat(s.objectPath(snap.SHA256))
+	return err == nil
+}
+
+// Restore returns a path to the snapshot's content under its original file
+// name, for attaching it again
+func (s *Snapshots) Restore(snap AttachmentSnapshot) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object := s.objectPath(snap.SHA256)
+	if _, err := os.Stat(object); err != nil {
+		return "", fmt.Errorf("snapshot of %s is no longer stored", snap.Filename)
+	}
+
+	dir := filepath.Join(s.dir, "restored", snap.SHA256)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Base(snap.Filename))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	// Hard link where possible to avoid a second copy
+	if err := os.Link(object, path); err != nil {
+		if err := copyFile(object, path); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// Matches reports whether the file at the snapshot's original path still
+// has the content that was sent
+func (s *Snapshots) Matches(snap AttachmentSnapshot) bool {
+	f, err := os.Open(snap.Path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == snap.SHA256
+}
+
+// Prune removes copies older than the retention period, then the least
+// recently sent copies until the store fits its size budget
+func (s *Snapshots) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objectsDir := filepath.Join(s.dir, "objects")
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return err
+	}
+
+	type object struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var objects []object
+	var total int64
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, object{name: entry.Name(), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].modTime.Before(objects[j].modTime)
+	})
+
+	cutoff := time.Now().Add(-s.retention)
+	for _, obj := range objects {
+		expired := s.retention > 0 && obj.modTime.Before(cutoff)
+		overBudget := s.maxBytes > 0 && total > s.maxBytes
+		if !expired && !overBudget {
+			break
+		}
+		if err := os.Remove(filepath.Join(objectsDir, obj.name)); err != nil {
+			return err
+		}
+		os.RemoveAll(filepath.Join(s.dir, "restored", obj.name))
+		total -= obj.size
+		logger.Debug("Removed attachment snapshot", "sha256", obj.name, "expired", expired)
+	}
+	return nil
+}
+
+// objectPath returns the path of the copy with the given SHA-256
+func (s *Snapshots) objectPath(sum string) string {
+	return filepath.Join(s.dir, "objects", filepath.Base(sum))
+}
+
+// detectContentType determines a MIME type from the original file name,
+// falling back to sniffing the stored content
+func detectContentType(object, name string) (string, error) {
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); contentType != "" {
+		return contentType, nil
+	}
+
+	f, err := os.Open(object)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// copyFile copies src to a new file at dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
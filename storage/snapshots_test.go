@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	snapshots, err := NewSnapshots(filepath.Join(dir, "config"), 1, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(report, []byte("%PDF-1.4 report"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := snapshots.Store(report, false)
+	if err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if snap.Size != 15 || snap.ContentType != "application/pdf" || len(snap.SHA256) != 64 {
+		t.Errorf("snapshot = %+v", snap)
+	}
+
+	// Identical content is stored once
+	copied := filepath.Join(dir, "copy.pdf")
+	if err := os.WriteFile(copied, []byte("%PDF-1.4 report"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := snapshots.Store(copied, false); err != nil || again.SHA256 != snap.SHA256 {
+		t.Errorf("Store() of identical content = %+v, %v", again, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "config", "attachments", "objects"))
+	if len(entries) != 1 {
+		t.Errorf("stored %d objects, want 1", len(entries))
+	}
+
+	// Changed originals are restored from the copy under their file name
+	if err := os.WriteFile(report, []byte("edited"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots.Matches(snap) {
+		t.Error("Matches() = true after the original changed")
+	}
+	restored, err := snapshots.Restore(snap)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if data, _ := os.ReadFile(restored); string(data) != "%PDF-1.4 report" || filepath.Base(restored) != "report.pdf" {
+		t.Errorf("Restore() = %s with %q", restored, data)
+	}
+
+	// Copies past the retention period are pruned
+	old := time.Now().Add(-31 * 24 * time.Hour)
+	if err := os.Chtimes(snapshots.objectPath(snap.SHA256), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshots.Prune(); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if snapshots.Exists(snap) {
+		t.Error("Exists() = true after retention expired")
+	}
+}
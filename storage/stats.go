@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats summarizes the emails sent in a period
+type Stats struct {
+	From, To  time.Time // Start of the first day and end of the last
+	Total     int
+	Succeeded int
+	Partial   int
+	Failed    int
+	// PerDay counts the emails sent on each day of the period, oldest first
+	PerDay    []PeriodCount
+	Providers []ProviderStats // Most used first
+	// TopRecipients and TopDomains count each recipient of an email once,
+	// across To, Cc and Bcc
+	TopRecipients []NameCount
+	TopDomains    []NameCount
+	TopTemplates  []NameCount
+	// Attachments and AttachmentBytes cover the attachments with a
+	// snapshot, which records their size
+	Attachments     int
+	AttachmentBytes int64
+}
+
+// PeriodCount is the number of emails sent in the period starting at Start
+type PeriodCount struct {
+	Start time.Time
+	Count int
+}
+
+// ProviderStats counts the outcomes of the emails sent through a provider
+type ProviderStats struct {
+	Name      string
+	Succeeded int
+	Partial   int
+	Failed    int
+}
+
+// Total returns the number of emails sent through the provider
+func (p ProviderStats) Total() int {
+	return p.Succeeded + p.Partial + p.Failed
+}
+
+// SuccessRate returns the share of emails fully sent, from 0 to 1
+func (p ProviderStats) SuccessRate() float64 {
+	if p.Total() == 0 {
+		return 0
+	}
+	return float64(p.Succeeded) / float64(p.Total())
+}
+
+// NameCount is how often a name occurs
+type NameCount struct {
+	Name  string
+	Count int
+}
+
+// Summarize computes the stats of the emails sent on the days from one
+// time to another, inclusive. A zero from starts at the first email.
+func Summarize(emails []SentEmail, from, to time.Time) Stats {
+	if from.IsZero() {
+		from = to
+		for _, email := range emails {
+			if email.SentAt.Before(from) {
+				from = email.SentAt
+			}
+		}
+	}
+	start := startOfDay(from)
+	end := startOfDay(to).AddDate(0, 0, 1)
+	s := Stats{From: start, To: end}
+
+	days := map[string]int{} // Index in PerDay by date
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days[day.Format(time.DateOnly)] = len(s.PerDay)
+		s.PerDay = append(s.PerDay, PeriodCount{Start: day})
+	}
+
+	providers := map[string]*ProviderStats{}
+	recipients := map[string]int{}
+	domains := map[string]int{}
+	templates := map[string]int{}
+
+	for _, email := range emails {
+		if email.SentAt.Before(start) || !email.SentAt.Before(end) {
+			continue
+		}
+		s.Total++
+
+		s.PerDay[days[email.SentAt.Local().Format(time.DateOnly)]].Count++
+
+		name := email.DeliveredBy
+		if name == "" {
+			name = email.ProviderName
+		}
+		p := providers[name]
+		if p == nil {
+			p = &ProviderStats{Name: name}
+			providers[name] = p
+		}
+		switch email.Status {
+		case "success":
+			s.Succeeded++
+			p.Succeeded++
+		case "partial":
+			s.Partial++
+			p.Partial++
+		default:
+			s.Failed++
+			p.Failed++
+		}
+
+		seen := map[string]bool{}
+		for _, list := range [][]string{email.To, email.CC, email.BCC} {
+			for _, addr := range list {
+				addr = normalizeAddress(addr)
+				if addr == "" || seen[addr] {
+					continue
+				}
+				seen[addr] = true
+				recipients[addr]++
+				if at := strings.LastIndex(addr, "@"); at >= 0 {
+					domains[addr[at+1:]]++
+				}
+			}
+		}
+
+		if email.Template != "" {
+			templates[email.Template]++
+		}
+
+		for _, snap := range email.Snapshots {
+			if !snap.Inline {
+				s.Attachments++
+				s.AttachmentBytes += snap.Size
+			}
+		}
+	}
+
+	for _, p := range providers {
+		s.Providers = append(s.Providers, *p)
+	}
+	sort.Slice(s.Providers, func(i, j int) bool {
+		if s.Providers[i].Total() != s.Providers[j].Total() {
+			return s.Providers[i].Total() > s.Providers[j].Total()
+		}
+		return s.Providers[i].Name < s.Providers[j].Name
+	})
+	s.TopRecipients = topCounts(recipients)
+	s.TopDomains = topCounts(domains)
+	s.TopTemplates = topCounts(templates)
+	return s
+}
+
+// PerWeek counts the emails sent in each week of the period, starting on
+// Mondays, oldest first
+func (s Stats) PerWeek() []PeriodCount {
+	var weeks []PeriodCount
+	for _, day := range s.PerDay {
+		start := day.Start.AddDate(0, 0, -(int(day.Start.Weekday())+6)%7)
+		if len(weeks) == 0 || !weeks[len(weeks)-1].Start.Equal(start) {
+			weeks = append(weeks, PeriodCount{Start: start})
+		}
+		weeks[len(weeks)-1].Count += day.Count
+	}
+	return weeks
+}
+
+// AverageAttachmentSize returns the average size of the attachments in
+// bytes, or 0 without attachments
+func (s Stats) AverageAttachmentSize() int64 {
+	if s.Attachments == 0 {
+		return 0
+	}
+	return s.AttachmentBytes / int64(s.Attachments)
+}
+
+// startOfDay returns midnight of a time's day in local time
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// normalizeAddress returns the lowercase address of a recipient
+func normalizeAddress(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return strings.ToLower(parsed.Address)
+	}
+	return strings.ToLower(strings.TrimSpace(addr))
+}
+
+// topCounts sorts counts from most to least frequent, then by name
+func topCounts(counts map[string]int) []NameCount {
+	result := make([]NameCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, NameCount{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2024, 3, d, hour, 0, 0, 0, time.Local)
+	}
+	emails := []SentEmail{
+		{SentAt: day(1, 9), Status: "success", ProviderName: "mailgun", To: []string{"Alice <alice@example.com>"}, Template: "welcome",
+			Snapshots: []AttachmentSnapshot{{Size: 1000}, {Size: 50, Inline: true}}},
+		{SentAt: day(4, 23), Status: "failed", ProviderName: "mailgun", To: []string{"alice@example.com"}, CC: []string{"ALICE@example.com", "bob@other.org"}},
+		{SentAt: day(5, 8), Status: "partial", ProviderName: "chain", DeliveredBy: "sendgrid", BCC: []string{"carol@example.com"}, Template: "welcome",
+			Snapshots: []AttachmentSnapshot{{Size: 3000}}},
+		{SentAt: day(9, 12), Status: "success", ProviderName: "mailgun"}, // Outside the range
+	}
+
+	s := Summarize(emails, day(1, 0), day(5, 0))
+
+	if s.Total != 3 || s.Succeeded != 1 || s.Failed != 1 || s.Partial != 1 {
+		t.Errorf("totals = %d (%d/%d/%d), want 3 (1/1/1)", s.Total, s.Succeeded, s.Failed, s.Partial)
+	}
+
+	var perDay []int
+	for _, d := range s.PerDay {
+		perDay = append(perDay, d.Count)
+	}
+	if want := []int{1, 0, 0, 1, 1}; !reflect.DeepEqual(perDay, want) {
+		t.Errorf("PerDay = %v, want %v", perDay, want)
+	}
+
+	// March 1st 2024 is a Friday, the 4th a Monday
+	weeks := s.PerWeek()
+	if len(weeks) != 2 || weeks[0].Count != 1 || weeks[1].Count != 2 || !weeks[1].Start.Equal(day(4, 0)) {
+		t.Errorf("PerWeek = %+v, want 1 in the week of Feb 26 and 2 from Mar 4", weeks)
+	}
+
+	wantProviders := []ProviderStats{{Name: "mailgun", Succeeded: 1, Failed: 1}, {Name: "sendgrid", Partial: 1}}
+	if !reflect.DeepEqual(s.Providers, wantProviders) {
+		t.Errorf("Providers = %+v, want %+v", s.Providers, wantProviders)
+	}
+
+	wantRecipients := []NameCount{{"alice@example.com", 2}, {"bob@other.org", 1}, {"carol@example.com", 1}}
+	if !reflect.DeepEqual(s.TopRecipients, wantRecipients) {
+		t.Errorf("TopRecipients = %v, want %v", s.TopRecipients, wantRecipients)
+	}
+	wantDomains := []NameCount{{"example.com", 3}, {"other.org", 1}}
+	if !reflect.DeepEqual(s.TopDomains, wantDomains) {
+		t.Errorf("TopDomains = %v, want %v", s.TopDomains, wantDomains)
+	}
+	if want := []NameCount{{"welcome", 2}}; !reflect.DeepEqual(s.TopTemplates, want) {
+		t.Errorf("TopTemplates = %v, want %v", s.TopTemplates, want)
+	}
+	if s.Attachments != 2 || s.AverageAttachmentSize() != 2000 {
+		t.Errorf("attachments = %d averaging %d, want 2 averaging 2000", s.Attachments, s.AverageAttachmentSize())
+	}
+}
@@ -163,20 +163,21 @@ func (t *Templates) GetByTag(tag string) []Template {
 // Variables in templates are in the format {{variable_name}}
 // Handles both {{name}} and {{ name }} (with or without spaces)
 func RenderTemplate(template Template, variables map[string]string) (subject string, body string) {
-	subject = template.Subject
-	body = template.Body
+	return RenderText(template.Subject, variables), RenderText(template.Body, variables)
+}
 
+// RenderText replaces {{variable}} placeholders in text with their values
+func RenderText(text string, variables map[string]string) string {
 	for key, value := range variables {
 		// Use regex to match {{key}} with any amount of whitespace around the key
 		// This handles {{name}}, {{ name }}, {{  name  }}, etc.
 		pattern := `\{\{\s*` + regexp.QuoteMeta(key) + `\s*\}\}`
 		re := regexp.MustCompile(pattern)
 
-		subject = re.ReplaceAllString(subject, value)
-		body = re.ReplaceAllString(body, value)
+		text = re.ReplaceAllLiteralString(text, value)
 	}
 
-	return subject, body
+	return text
 }
 
 // extractVariables finds all {{variable}} placeholders in text
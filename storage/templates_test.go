@@ -149,3 +149,39 @@ func TestRenderTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		variables map[string]string
+		expected  string
+	}{
+		{
+			name:      "variables with spaces",
+			text:      "Hi {{ name }}, from {{company}}",
+			variables: map[string]string{"name": "Ada", "company": "Acme"},
+			expected:  "Hi Ada, from Acme",
+		},
+		{
+			name:      "unknown variable kept",
+			text:      "Hi {{name}}",
+			variables: map[string]string{},
+			expected:  "Hi {{name}}",
+		},
+		{
+			name:      "dollar signs kept literally",
+			text:      "Total: {{price}}\n{{signature}}",
+			variables: map[string]string{"price": "$1", "signature": "-- ${x} $$"},
+			expected:  "Total: $1\n-- ${x} $$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderText(tt.text, tt.variables); got != tt.expected {
+				t.Errorf("RenderText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
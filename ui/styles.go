@@ -168,6 +168,19 @@ var (
 	// Divider
 	DividerStyle = lipgloss.NewStyle().
 			Foreground(Muted)
+
+	// Inline text styles, for notes next to other content
+	MutedTextStyle = lipgloss.NewStyle().
+			Foreground(Muted)
+
+	ErrorTextStyle = lipgloss.NewStyle().
+			Foreground(Error)
+
+	WarningTextStyle = lipgloss.NewStyle().
+				Foreground(Warning)
+
+	SuccessTextStyle = lipgloss.NewStyle().
+				Foreground(Success)
 )
 
 // RenderTabs renders the tab bar